@@ -0,0 +1,420 @@
+/*
+Package analysis statically analyzes a ROM without running it.
+
+Analyze follows the control flow from the ROM start address through jumps,
+calls and skips, splitting the reachable instructions into basic blocks and
+grouping them into subroutines. Along the way it tracks the value of I loaded
+by LD I, addr to find the sprite data drawn by DRW, and the memory written by
+LD B, Vx and LD [I], Vx to find self-modifying code.
+
+Computed jumps (JP V0, addr) cannot be followed statically and are reported
+instead, so code only reachable through them is missing from the graph.
+*/
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/odsod/chip8"
+)
+
+const (
+	romStartAddress = 0x200
+	memorySize      = 4096
+)
+
+// EdgeKind is the kind of control transfer between two blocks
+type EdgeKind string
+
+const (
+	EdgeFallthrough EdgeKind = "fallthrough"
+	EdgeJump        EdgeKind = "jump"
+	EdgeSkip        EdgeKind = "skip"
+	EdgeCall        EdgeKind = "call"
+)
+
+type Instruction struct {
+	Address uint16 `json:"address"`
+	Op      uint16 `json:"op"`
+	Asm     string `json:"asm"`
+}
+
+type Edge struct {
+	From uint16   `json:"from"`
+	To   uint16   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// Block is a basic block, a run of instructions only entered at the first and
+// only left at the last
+type Block struct {
+	Start        uint16        `json:"start"`
+	Instructions []Instruction `json:"instructions"`
+}
+
+// End is the address after the last instruction of the block
+func (b *Block) End() uint16 {
+	return b.Instructions[len(b.Instructions)-1].Address + 2
+}
+
+// Subroutine is a CALL target, or the main program at the ROM start address,
+// with the blocks reachable from it without following CALL
+type Subroutine struct {
+	Address uint16   `json:"address"`
+	Name    string   `json:"name"`
+	Blocks  []uint16 `json:"blocks"`
+	// Callers are the addresses of the CALL instructions calling the subroutine
+	Callers []uint16 `json:"callers"`
+}
+
+// Sprite is a region of memory drawn by DRW with I loaded by LD I, addr
+type Sprite struct {
+	Address uint16 `json:"address"`
+	Height  uint8  `json:"height"`
+	// DrawnBy are the addresses of the DRW instructions drawing the sprite
+	DrawnBy []uint16 `json:"drawnBy"`
+}
+
+// Write is a memory write by LD B, Vx or LD [I], Vx overlapping reachable code
+type Write struct {
+	Address uint16 `json:"address"`
+	Start   uint16 `json:"start"`
+	End     uint16 `json:"end"`
+}
+
+type Analysis struct {
+	Blocks      []*Block      `json:"blocks"`
+	Edges       []Edge        `json:"edges"`
+	Subroutines []*Subroutine `json:"subroutines"`
+	Sprites     []*Sprite     `json:"sprites"`
+	// ComputedJumps are the addresses of JP V0, addr instructions
+	ComputedJumps []uint16 `json:"computedJumps"`
+	// InvalidTargets are the addresses of instructions jumping, calling or
+	// falling through to an address that is not a valid instruction
+	InvalidTargets []uint16 `json:"invalidTargets"`
+	// SelfModifying are the memory writes overlapping reachable code
+	SelfModifying []Write `json:"selfModifying"`
+
+	memory       [memorySize]uint8
+	instructions map[uint16]Instruction
+}
+
+// Block returns the block starting at an address, or nil
+func (a *Analysis) Block(address uint16) *Block {
+	i := sort.Search(len(a.Blocks), func(i int) bool {
+		return a.Blocks[i].Start >= address
+	})
+	if i < len(a.Blocks) && a.Blocks[i].Start == address {
+		return a.Blocks[i]
+	}
+	return nil
+}
+
+// IsCode reports whether an address is the first or second byte of a
+// reachable instruction
+func (a *Analysis) IsCode(address uint16) bool {
+	_, ok := a.instructions[address]
+	_, okPrevious := a.instructions[address-1]
+	return ok || okPrevious
+}
+
+// Name returns the name of the subroutine at an address, main for the ROM
+// start address
+func Name(address uint16) string {
+	if address == romStartAddress {
+		return "main"
+	}
+	return fmt.Sprintf("sub_%03X", address)
+}
+
+// unknownI is the value of I when it was not loaded by LD I, addr
+const unknownI = 0xFFFF
+
+type state struct {
+	pc, i uint16
+}
+
+type write struct {
+	pc         uint16
+	start, end int
+}
+
+type analyzer struct {
+	*Analysis
+	leaders  map[uint16]bool
+	edges    map[Edge]bool
+	calls    map[uint16][]uint16
+	sprites  map[uint16]*Sprite
+	computed map[uint16]bool
+	invalid  map[uint16]bool
+	writes   []write
+}
+
+func (a *analyzer) fetch(address uint16) (chip8.EncodedOp, bool) {
+	if int(address)+1 >= memorySize || address < romStartAddress {
+		return 0, false
+	}
+	op := chip8.EncodedOp(uint16(a.memory[address])<<8 | uint16(a.memory[address+1]))
+	return op, op.IsValid()
+}
+
+func (a *analyzer) addSprite(address uint16, height uint8, pc uint16) {
+	sprite, ok := a.sprites[address]
+	if !ok {
+		sprite = &Sprite{Address: address}
+		a.sprites[address] = sprite
+	}
+	if height > sprite.Height {
+		sprite.Height = height
+	}
+	if !contains(sprite.DrawnBy, pc) {
+		sprite.DrawnBy = append(sprite.DrawnBy, pc)
+	}
+}
+
+// successors returns the states following the instruction at s.pc, and
+// records what the instruction reveals about the ROM on the way
+func (a *analyzer) successors(s state, op chip8.EncodedOp) []state {
+	pc, i := s.pc, s.i
+	next := state{pc + 2, i}
+	x, n, nnn := uint16(op>>8&0xF), uint8(op&0xF), uint16(op&0xFFF)
+	switch {
+	case op == 0x00EE:
+		return nil
+	case op&0xF000 == 0x1000:
+		a.edges[Edge{pc, nnn, EdgeJump}] = true
+		a.leaders[nnn] = true
+		return []state{{nnn, i}}
+	case op&0xF000 == 0x2000:
+		a.edges[Edge{pc, nnn, EdgeCall}] = true
+		a.leaders[nnn] = true
+		if !contains(a.calls[nnn], pc) {
+			a.calls[nnn] = append(a.calls[nnn], pc)
+		}
+		a.edges[Edge{pc, next.pc, EdgeFallthrough}] = true
+		a.leaders[next.pc] = true
+		// The subroutine may load I, so it is unknown after the call
+		next.i = unknownI
+		return []state{{nnn, i}, next}
+	case op&0xF000 == 0x3000, op&0xF000 == 0x4000, op&0xF00F == 0x5000, op&0xF00F == 0x9000,
+		op&0xF0FF == 0xE09E, op&0xF0FF == 0xE0A1:
+		skip := state{pc + 4, i}
+		a.edges[Edge{pc, skip.pc, EdgeSkip}] = true
+		a.edges[Edge{pc, next.pc, EdgeFallthrough}] = true
+		a.leaders[next.pc] = true
+		a.leaders[skip.pc] = true
+		return []state{next, skip}
+	case op&0xF000 == 0xA000:
+		next.i = nnn
+	case op&0xF000 == 0xB000:
+		a.computed[pc] = true
+		return nil
+	case op&0xF000 == 0xD000:
+		if i != unknownI {
+			a.addSprite(i, n, pc)
+		}
+	case op&0xF0FF == 0xF01E, op&0xF0FF == 0xF029:
+		next.i = unknownI
+	case op&0xF0FF == 0xF033:
+		if i != unknownI {
+			a.writes = append(a.writes, write{pc, int(i), int(i) + 3})
+		}
+	case op&0xF0FF == 0xF055:
+		if i != unknownI {
+			a.writes = append(a.writes, write{pc, int(i), int(i) + int(x) + 1})
+		}
+	}
+	return []state{next}
+}
+
+// Analyze builds the control-flow graph of a ROM and finds its subroutines,
+// sprites and self-modifying code
+func Analyze(rom []uint8) *Analysis {
+	a := &analyzer{
+		Analysis: &Analysis{
+			instructions: make(map[uint16]Instruction),
+		},
+		leaders:  map[uint16]bool{romStartAddress: true},
+		edges:    make(map[Edge]bool),
+		calls:    make(map[uint16][]uint16),
+		sprites:  make(map[uint16]*Sprite),
+		computed: make(map[uint16]bool),
+		invalid:  make(map[uint16]bool),
+	}
+	copy(a.memory[romStartAddress:], rom)
+
+	visited := make(map[state]bool)
+	work := []state{{romStartAddress, unknownI}}
+	terminators := make(map[uint16]bool)
+	for len(work) > 0 {
+		s := work[len(work)-1]
+		work = work[:len(work)-1]
+		if visited[s] {
+			continue
+		}
+		visited[s] = true
+		op, ok := a.fetch(s.pc)
+		if !ok {
+			continue
+		}
+		a.instructions[s.pc] = Instruction{s.pc, uint16(op), op.Disassemble()}
+		next := a.successors(s, op)
+		if len(next) == 0 || op&0xF000 == 0x1000 {
+			terminators[s.pc] = true
+		}
+		for _, n := range next {
+			if _, ok := a.fetch(n.pc); !ok {
+				a.invalid[s.pc] = true
+				continue
+			}
+			work = append(work, n)
+		}
+	}
+
+	a.buildBlocks(terminators)
+	a.buildSubroutines()
+	a.collect()
+	return a.Analysis
+}
+
+func (a *analyzer) buildBlocks(terminators map[uint16]bool) {
+	isStart := make(map[uint16]bool)
+	var starts []uint16
+	for address := range a.instructions {
+		previous, hasPrevious := a.instructions[address-2]
+		if a.leaders[address] || !hasPrevious || terminators[previous.Address] || isBranch(previous.Op) {
+			isStart[address] = true
+			starts = append(starts, address)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for _, start := range starts {
+		block := &Block{Start: start}
+		for address := start; ; address += 2 {
+			instruction := a.instructions[address]
+			block.Instructions = append(block.Instructions, instruction)
+			if _, ok := a.instructions[address+2]; !ok || isStart[address+2] {
+				break
+			}
+		}
+		a.Blocks = append(a.Blocks, block)
+		last := block.Instructions[len(block.Instructions)-1]
+		if _, ok := a.instructions[block.End()]; ok && !terminators[last.Address] && !isBranch(last.Op) {
+			a.edges[Edge{last.Address, block.End(), EdgeFallthrough}] = true
+		}
+	}
+}
+
+// isBranch reports whether an op continues at more than one address
+func isBranch(op uint16) bool {
+	switch {
+	case op&0xF000 == 0x2000, op&0xF000 == 0x3000, op&0xF000 == 0x4000,
+		op&0xF00F == 0x5000, op&0xF00F == 0x9000, op&0xF0FF == 0xE09E, op&0xF0FF == 0xE0A1:
+		return true
+	}
+	return false
+}
+
+func (a *analyzer) buildSubroutines() {
+	entries := []uint16{romStartAddress}
+	for address := range a.calls {
+		if address != romStartAddress {
+			entries = append(entries, address)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+
+	successors := make(map[uint16][]uint16)
+	for edge := range a.edges {
+		if edge.Kind != EdgeCall {
+			successors[edge.From] = append(successors[edge.From], edge.To)
+		}
+	}
+	for _, entry := range entries {
+		if a.Block(entry) == nil {
+			continue
+		}
+		subroutine := &Subroutine{Address: entry, Name: Name(entry), Callers: a.calls[entry]}
+		sort.Slice(subroutine.Callers, func(i, j int) bool {
+			return subroutine.Callers[i] < subroutine.Callers[j]
+		})
+		visited := map[uint16]bool{entry: true}
+		work := []uint16{entry}
+		for len(work) > 0 {
+			block := a.Block(work[len(work)-1])
+			work = work[:len(work)-1]
+			subroutine.Blocks = append(subroutine.Blocks, block.Start)
+			last := block.Instructions[len(block.Instructions)-1]
+			for _, to := range successors[last.Address] {
+				if !visited[to] && a.Block(to) != nil {
+					visited[to] = true
+					work = append(work, to)
+				}
+			}
+		}
+		sort.Slice(subroutine.Blocks, func(i, j int) bool {
+			return subroutine.Blocks[i] < subroutine.Blocks[j]
+		})
+		a.Subroutines = append(a.Subroutines, subroutine)
+	}
+}
+
+func (a *analyzer) collect() {
+	for edge := range a.edges {
+		a.Edges = append(a.Edges, edge)
+	}
+	sort.Slice(a.Edges, func(i, j int) bool {
+		if a.Edges[i].From != a.Edges[j].From {
+			return a.Edges[i].From < a.Edges[j].From
+		}
+		if a.Edges[i].To != a.Edges[j].To {
+			return a.Edges[i].To < a.Edges[j].To
+		}
+		return a.Edges[i].Kind < a.Edges[j].Kind
+	})
+	for _, sprite := range a.sprites {
+		sort.Slice(sprite.DrawnBy, func(i, j int) bool { return sprite.DrawnBy[i] < sprite.DrawnBy[j] })
+		a.Sprites = append(a.Sprites, sprite)
+	}
+	sort.Slice(a.Sprites, func(i, j int) bool { return a.Sprites[i].Address < a.Sprites[j].Address })
+	a.ComputedJumps = sortedKeys(a.computed)
+	a.InvalidTargets = sortedKeys(a.invalid)
+	seen := make(map[write]bool)
+	for _, w := range a.writes {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		for address := w.start; address < w.end && address < memorySize; address++ {
+			if a.IsCode(uint16(address)) {
+				a.SelfModifying = append(a.SelfModifying, Write{w.pc, uint16(w.start), uint16(w.end)})
+				break
+			}
+		}
+	}
+	sort.Slice(a.SelfModifying, func(i, j int) bool {
+		if a.SelfModifying[i].Address != a.SelfModifying[j].Address {
+			return a.SelfModifying[i].Address < a.SelfModifying[j].Address
+		}
+		return a.SelfModifying[i].Start < a.SelfModifying[j].Start
+	})
+}
+
+func contains(addresses []uint16, address uint16) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[uint16]bool) []uint16 {
+	keys := make([]uint16, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testROM = []uint8{
+	0xA2, 0x14, // 0x200 LD I, 0x214
+	0x22, 0x0C, // 0x202 CALL 0x20C
+	0x30, 0x00, // 0x204 SE V0, 0x00
+	0xB2, 0x00, // 0x206 JP V0, 0x200
+	0xA2, 0x03, // 0x208 LD I, 0x203
+	0xF0, 0x55, // 0x20A LD [I], V0
+	0xD0, 0x14, // 0x20C DRW V0, V1, 4
+	0x00, 0xEE, // 0x20E RET
+	0x00, 0x00, // 0x210
+	0x00, 0x00, // 0x212
+	0xF0, 0x90, // 0x214 sprite
+	0x90, 0xF0, // 0x216 sprite
+}
+
+func TestAnalyze(t *testing.T) {
+	a := Analyze(testROM)
+	var starts []uint16
+	for _, block := range a.Blocks {
+		starts = append(starts, block.Start)
+	}
+	if expected := []uint16{0x200, 0x204, 0x206, 0x208, 0x20C}; !reflect.DeepEqual(starts, expected) {
+		t.Errorf("Blocks: Expected %#x, Actual %#x", expected, starts)
+	}
+	if expected := []Edge{
+		{0x202, 0x204, EdgeFallthrough},
+		{0x202, 0x20C, EdgeCall},
+		{0x204, 0x206, EdgeFallthrough},
+		{0x204, 0x208, EdgeSkip},
+		{0x20A, 0x20C, EdgeFallthrough},
+	}; !reflect.DeepEqual(a.Edges, expected) {
+		t.Errorf("Edges: Expected %v, Actual %v", expected, a.Edges)
+	}
+	if len(a.Subroutines) != 2 || a.Subroutines[1].Name != "sub_20C" ||
+		!reflect.DeepEqual(a.Subroutines[1].Callers, []uint16{0x202}) {
+		t.Errorf("Unexpected subroutines: %+v", a.Subroutines)
+	}
+	if !reflect.DeepEqual(a.ComputedJumps, []uint16{0x206}) {
+		t.Errorf("ComputedJumps: Expected [0x206], Actual %#x", a.ComputedJumps)
+	}
+	if expected := []*Sprite{
+		{Address: 0x203, Height: 4, DrawnBy: []uint16{0x20C}},
+		{Address: 0x214, Height: 4, DrawnBy: []uint16{0x20C}},
+	}; !reflect.DeepEqual(a.Sprites, expected) {
+		t.Errorf("Unexpected sprites: %+v", a.Sprites)
+	}
+	if expected := []Write{{0x20A, 0x203, 0x204}}; !reflect.DeepEqual(a.SelfModifying, expected) {
+		t.Errorf("SelfModifying: Expected %v, Actual %v", expected, a.SelfModifying)
+	}
+}
+
+func TestAnalyze_SelfModifying(t *testing.T) {
+	a := Analyze([]uint8{
+		0xA2, 0x07, // 0x200 LD I, 0x207
+		0xF0, 0x55, // 0x202 LD [I], V0
+		0x12, 0x06, // 0x204 JP 0x206
+		0x60, 0x00, // 0x206 LD V0, 0x00
+		0x12, 0x00, // 0x208 JP 0x200
+	})
+	if expected := []Write{{0x202, 0x207, 0x208}}; !reflect.DeepEqual(a.SelfModifying, expected) {
+		t.Errorf("SelfModifying: Expected %v, Actual %v", expected, a.SelfModifying)
+	}
+}
+
+func TestAnalyze_InvalidTarget(t *testing.T) {
+	a := Analyze([]uint8{
+		0x12, 0x04, // 0x200 JP 0x204
+		0x00, 0x00, // 0x202
+		0xFF, 0xFF, // 0x204 invalid
+	})
+	if !reflect.DeepEqual(a.InvalidTargets, []uint16{0x200}) {
+		t.Errorf("InvalidTargets: Expected [0x200], Actual %#x", a.InvalidTargets)
+	}
+}
+
+func TestExport(t *testing.T) {
+	a := Analyze(testROM)
+	var dot bytes.Buffer
+	if err := a.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"subgraph cluster_20C",
+		`block_200 -> block_20C [style="dashed", label="call"];`,
+		`block_206 -> computed_206`,
+		`0x20C  DRW V0, V1, 4\l`,
+	} {
+		if !strings.Contains(dot.String(), expected) {
+			t.Errorf("Expected %q in DOT:\n%s", expected, dot.String())
+		}
+	}
+	var buf bytes.Buffer
+	if err := a.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Analysis
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Edges, a.Edges) || len(decoded.Blocks) != len(a.Blocks) {
+		t.Errorf("Unexpected JSON round trip: %s", buf.String())
+	}
+}
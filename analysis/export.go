@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes the analysis as indented JSON
+func (a *Analysis) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(a)
+}
+
+var edgeStyles = map[EdgeKind]string{
+	EdgeFallthrough: "",
+	EdgeJump:        ` [color="blue"]`,
+	EdgeSkip:        ` [color="darkgreen", label="skip"]`,
+	EdgeCall:        ` [style="dashed", label="call"]`,
+}
+
+func blockID(address uint16) string {
+	return fmt.Sprintf("block_%03X", address)
+}
+
+func (a *Analysis) blockOf(address uint16) *Block {
+	for _, block := range a.Blocks {
+		if address >= block.Start && address < block.End() {
+			return block
+		}
+	}
+	return nil
+}
+
+// WriteDOT writes the control-flow graph in Graphviz DOT format, with a
+// cluster per subroutine and a node per block. Computed jumps are drawn as
+// edges to a node of their own.
+func (a *Analysis) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph chip8 {\n")
+	b.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+	clustered := make(map[uint16]bool)
+	for _, subroutine := range a.Subroutines {
+		fmt.Fprintf(&b, "  subgraph cluster_%03X {\n", subroutine.Address)
+		fmt.Fprintf(&b, "    label=%q;\n", subroutine.Name)
+		for _, start := range subroutine.Blocks {
+			if clustered[start] {
+				continue
+			}
+			clustered[start] = true
+			a.writeBlock(&b, "    ", a.Block(start))
+		}
+		b.WriteString("  }\n")
+	}
+	for _, block := range a.Blocks {
+		if !clustered[block.Start] {
+			a.writeBlock(&b, "  ", block)
+		}
+	}
+	for _, edge := range a.Edges {
+		from, to := a.blockOf(edge.From), a.Block(edge.To)
+		if from == nil || to == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -> %s%s;\n", blockID(from.Start), blockID(to.Start), edgeStyles[edge.Kind])
+	}
+	for _, address := range a.ComputedJumps {
+		if from := a.blockOf(address); from != nil {
+			fmt.Fprintf(&b, "  computed_%03X [label=\"?\", shape=diamond, color=\"red\"];\n", address)
+			fmt.Fprintf(&b, "  %s -> computed_%03X [color=\"red\", label=\"JP V0\"];\n", blockID(from.Start), address)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (a *Analysis) writeBlock(b *strings.Builder, indent string, block *Block) {
+	var label strings.Builder
+	for _, instruction := range block.Instructions {
+		fmt.Fprintf(&label, "0x%03X  %s\\l", instruction.Address, instruction.Asm)
+	}
+	fmt.Fprintf(b, "%s%s [label=\"%s\"];\n", indent, blockID(block.Start), label.String())
+}
+
+// WriteSummary writes the subroutines, sprites, computed jumps and
+// self-modifying code found in the ROM as text
+func (a *Analysis) WriteSummary(w io.Writer) error {
+	var b strings.Builder
+	instructions := 0
+	for _, block := range a.Blocks {
+		instructions += len(block.Instructions)
+	}
+	fmt.Fprintf(&b, "Reachable instructions: %d in %d blocks\n", instructions, len(a.Blocks))
+
+	fmt.Fprintf(&b, "\nSubroutines:\n")
+	for _, subroutine := range a.Subroutines {
+		fmt.Fprintf(&b, "  %-8s 0x%03X  %3d blocks", subroutine.Name, subroutine.Address, len(subroutine.Blocks))
+		if len(subroutine.Callers) > 0 {
+			fmt.Fprintf(&b, "  called from %s", addresses(subroutine.Callers))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\nSprites:\n")
+	for _, sprite := range a.Sprites {
+		fmt.Fprintf(&b, "  0x%03X  %2d rows  drawn at %s\n", sprite.Address, sprite.Height, addresses(sprite.DrawnBy))
+	}
+
+	if len(a.ComputedJumps) > 0 {
+		fmt.Fprintf(&b, "\nComputed jumps: %s\n", addresses(a.ComputedJumps))
+	}
+	if len(a.InvalidTargets) > 0 {
+		fmt.Fprintf(&b, "\nInvalid targets from: %s\n", addresses(a.InvalidTargets))
+	}
+	if len(a.SelfModifying) > 0 {
+		fmt.Fprintf(&b, "\nSelf-modifying code:\n")
+		for _, write := range a.SelfModifying {
+			fmt.Fprintf(&b, "  0x%03X writes 0x%03X-0x%03X\n", write.Address, write.Start, write.End-1)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func addresses(as []uint16) string {
+	s := make([]string, len(as))
+	for i, a := range as {
+		s[i] = fmt.Sprintf("0x%03X", a)
+	}
+	return strings.Join(s, ", ")
+}
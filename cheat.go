@@ -0,0 +1,348 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Location is a memory address or a register, for searching and cheating
+type Location struct {
+	// IsRegister is true for Register and false for Address
+	IsRegister bool
+	Register   Register
+	Address    uint16
+}
+
+func MemoryLocation(address uint16) Location {
+	return Location{Address: address}
+}
+
+func RegisterLocation(register Register) Location {
+	return Location{IsRegister: true, Register: register}
+}
+
+// ParseLocation parses a register name, e.g. VE or DT, or a memory address,
+// e.g. 0x314
+func ParseLocation(s string) (Location, error) {
+	for r := RegisterV0; r <= RegisterST; r++ {
+		if strings.EqualFold(s, r.String()) {
+			return RegisterLocation(r), nil
+		}
+	}
+	address, err := strconv.ParseUint(s, 0, 16)
+	if err != nil || address >= uint64(len(VM{}.Memory)) {
+		return Location{}, fmt.Errorf("invalid location: %s", s)
+	}
+	return MemoryLocation(uint16(address)), nil
+}
+
+func (l Location) String() string {
+	if l.IsRegister {
+		return l.Register.String()
+	}
+	return fmt.Sprintf("0x%03X", l.Address)
+}
+
+// Read returns the value at a location
+func (vm *VM) Read(l Location) uint16 {
+	if l.IsRegister {
+		return vm.registerValues()[l.Register]
+	}
+	return uint16(vm.Memory[l.Address])
+}
+
+// Write sets the value at a location, truncated to 8 bits for all but I
+func (vm *VM) Write(l Location, value uint16) {
+	if !l.IsRegister {
+		vm.Memory[l.Address] = uint8(value)
+		return
+	}
+	switch {
+	case l.Register <= RegisterVF:
+		vm.V[l.Register-RegisterV0] = uint8(value)
+	case l.Register == RegisterI:
+		vm.I = value
+	case l.Register == RegisterSP:
+		vm.SP = uint8(value)
+	case l.Register == RegisterDT:
+		vm.DT = uint8(value)
+	case l.Register == RegisterST:
+		vm.ST = uint8(value)
+	}
+}
+
+// Search finds the locations holding a value, e.g. the number of lives, by
+// narrowing down the candidates between frames of a running VM
+type Search struct {
+	vm         *VM
+	candidates []Location
+	values     []uint16
+}
+
+// NewSearch starts a search with every register and memory address as
+// candidate
+func NewSearch(vm *VM) *Search {
+	s := &Search{vm: vm}
+	for r := RegisterV0; r <= RegisterST; r++ {
+		s.candidates = append(s.candidates, RegisterLocation(r))
+	}
+	for address := range vm.Memory {
+		s.candidates = append(s.candidates, MemoryLocation(uint16(address)))
+	}
+	s.snapshot()
+	return s
+}
+
+func (s *Search) snapshot() {
+	s.values = s.values[:0]
+	for _, l := range s.candidates {
+		s.values = append(s.values, s.vm.Read(l))
+	}
+}
+
+func (s *Search) narrow(keep func(previous, current uint16) bool) {
+	var candidates []Location
+	for i, l := range s.candidates {
+		if keep(s.values[i], s.vm.Read(l)) {
+			candidates = append(candidates, l)
+		}
+	}
+	s.candidates = candidates
+	s.snapshot()
+}
+
+// Equal keeps the candidates holding a value
+func (s *Search) Equal(value uint16) {
+	s.narrow(func(_, current uint16) bool { return current == value })
+}
+
+// Changed keeps the candidates changed since the last narrowing
+func (s *Search) Changed() {
+	s.narrow(func(previous, current uint16) bool { return current != previous })
+}
+
+// Unchanged keeps the candidates not changed since the last narrowing
+func (s *Search) Unchanged() {
+	s.narrow(func(previous, current uint16) bool { return current == previous })
+}
+
+// Increased keeps the candidates increased since the last narrowing
+func (s *Search) Increased() {
+	s.narrow(func(previous, current uint16) bool { return current > previous })
+}
+
+// Decreased keeps the candidates decreased since the last narrowing
+func (s *Search) Decreased() {
+	s.narrow(func(previous, current uint16) bool { return current < previous })
+}
+
+// Candidates returns the remaining candidates, registers first
+func (s *Search) Candidates() []Location {
+	return s.candidates
+}
+
+type CheatKind string
+
+const (
+	// CheatFreeze writes its values every frame
+	CheatFreeze CheatKind = "freeze"
+	// CheatPatch writes its values every frame, and restores the values from
+	// before it was enabled when disabled
+	CheatPatch CheatKind = "patch"
+)
+
+type CheatWrite struct {
+	Location Location
+	Value    uint16
+}
+
+// Cheat is a named set of writes applied every frame while enabled
+type Cheat struct {
+	Name    string
+	Kind    CheatKind
+	Writes  []CheatWrite
+	Enabled bool
+
+	original []uint16
+}
+
+// SetEnabled enables or disables a cheat, applying it immediately when enabled
+func (c *Cheat) SetEnabled(vm *VM, enabled bool) {
+	if enabled == c.Enabled {
+		return
+	}
+	c.Enabled = enabled
+	if enabled {
+		c.capture(vm)
+		c.apply(vm)
+		return
+	}
+	if c.Kind == CheatPatch {
+		for i, w := range c.Writes {
+			vm.Write(w.Location, c.original[i])
+		}
+	}
+}
+
+// capture keeps the values a patch restores when disabled
+func (c *Cheat) capture(vm *VM) {
+	c.original = c.original[:0]
+	for _, w := range c.Writes {
+		c.original = append(c.original, vm.Read(w.Location))
+	}
+}
+
+func (c *Cheat) apply(vm *VM) {
+	for _, w := range c.Writes {
+		vm.Write(w.Location, w.Value)
+	}
+}
+
+func (c *Cheat) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", c.Name, c.Kind)
+	for _, w := range c.Writes {
+		fmt.Fprintf(&b, " %s=0x%02X", w.Location, w.Value)
+	}
+	return b.String()
+}
+
+// cheating holds the cheats applied by TickTimers
+type cheating struct {
+	cheats []*Cheat
+}
+
+// SetCheats makes TickTimers apply the enabled cheats every frame, nil
+// removes them. Cheats that are already enabled, e.g. when a ROM is reset,
+// are applied right away, patches restoring the values of this VM when
+// disabled.
+func (vm *VM) SetCheats(cheats []*Cheat) {
+	if cheats == nil {
+		vm.cheating = nil
+		return
+	}
+	vm.cheating = &cheating{cheats: cheats}
+	for _, c := range cheats {
+		if c.Enabled {
+			c.capture(vm)
+			c.apply(vm)
+		}
+	}
+}
+
+// ToggleCheat toggles the i:th cheat, if any, and returns it
+func (vm *VM) ToggleCheat(i int) *Cheat {
+	if vm.cheating == nil || i < 0 || i >= len(vm.cheating.cheats) {
+		return nil
+	}
+	c := vm.cheating.cheats[i]
+	c.SetEnabled(vm, !c.Enabled)
+	return c
+}
+
+func (vm *VM) applyCheats() {
+	if vm.cheating == nil {
+		return
+	}
+	for _, c := range vm.cheating.cheats {
+		if c.Enabled {
+			c.apply(vm)
+		}
+	}
+}
+
+/*
+ParseCheats parses a cheat file, with a cheat per line on the form
+
+	NAME: KIND LOCATION=VALUE...
+
+where KIND is freeze or patch, LOCATION is a register or memory address and
+VALUE a number, e.g.
+
+	# BRIX
+	Infinite lives: freeze VE=0x05
+	Wide paddle: patch 0x30E=0xFF
+
+Empty lines and lines starting with # are ignored. Cheats are disabled
+until enabled, by the frontends with the function keys in file order.
+*/
+func ParseCheats(r io.Reader) ([]*Cheat, error) {
+	var cheats []*Cheat
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		c, err := parseCheat(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		cheats = append(cheats, c)
+	}
+	return cheats, scanner.Err()
+}
+
+func parseCheat(text string) (*Cheat, error) {
+	colon := strings.LastIndex(text, ":")
+	if colon == -1 {
+		return nil, fmt.Errorf("missing name: %s", text)
+	}
+	c := &Cheat{Name: strings.TrimSpace(text[:colon])}
+	fields := strings.Fields(text[colon+1:])
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("missing writes: %s", text)
+	}
+	switch kind := CheatKind(fields[0]); kind {
+	case CheatFreeze, CheatPatch:
+		c.Kind = kind
+	default:
+		return nil, fmt.Errorf("invalid kind: %s", fields[0])
+	}
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid write: %s", field)
+		}
+		location, err := ParseLocation(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseUint(parts[1], 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %s", parts[1])
+		}
+		if location == RegisterLocation(RegisterSP) && value > uint64(len(VM{}.Stack)) {
+			return nil, fmt.Errorf("stack pointer out of range: %s", parts[1])
+		}
+		c.Writes = append(c.Writes, CheatWrite{location, uint16(value)})
+	}
+	return c, nil
+}
+
+// LoadCheats reads a cheat file, no file name meaning no cheats
+func LoadCheats(name string) ([]*Cheat, error) {
+	if name == "" {
+		return nil, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCheats(f)
+}
+
+// WriteCheats writes cheats in the format read by ParseCheats
+func WriteCheats(w io.Writer, cheats []*Cheat) error {
+	for _, c := range cheats {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
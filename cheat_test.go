@@ -0,0 +1,150 @@
+package chip8
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	vm := New([]uint8{})
+	vm.V[0x3] = 3
+	vm.Memory[0x300] = 3
+	vm.Memory[0x301] = 3
+	s := NewSearch(vm)
+	s.Equal(3)
+	expected := []Location{RegisterLocation(0x3), MemoryLocation(0x300), MemoryLocation(0x301)}
+	if !reflect.DeepEqual(s.Candidates(), expected) {
+		t.Fatalf("Equal(3): Expected %v, Actual %v", expected, s.Candidates())
+	}
+	vm.V[0x3] = 2
+	vm.Memory[0x300] = 4
+	s.Changed()
+	expected = []Location{RegisterLocation(0x3), MemoryLocation(0x300)}
+	if !reflect.DeepEqual(s.Candidates(), expected) {
+		t.Fatalf("Changed(): Expected %v, Actual %v", expected, s.Candidates())
+	}
+	vm.V[0x3] = 1
+	vm.Memory[0x300] = 5
+	s.Increased()
+	expected = []Location{MemoryLocation(0x300)}
+	if !reflect.DeepEqual(s.Candidates(), expected) {
+		t.Fatalf("Increased(): Expected %v, Actual %v", expected, s.Candidates())
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	for _, tt := range []struct {
+		s        string
+		expected Location
+	}{
+		{"VE", RegisterLocation(RegisterV0 + 0xE)},
+		{"ve", RegisterLocation(RegisterV0 + 0xE)},
+		{"DT", RegisterLocation(RegisterDT)},
+		{"0x314", MemoryLocation(0x314)},
+		{"788", MemoryLocation(788)},
+	} {
+		if actual, err := ParseLocation(tt.s); err != nil || actual != tt.expected {
+			t.Errorf("ParseLocation(%q): Expected %v, Actual %v (%v)", tt.s, tt.expected, actual, err)
+		}
+	}
+	for _, s := range []string{"", "VG", "0x1000"} {
+		if _, err := ParseLocation(s); err == nil {
+			t.Errorf("ParseLocation(%q): Expected error", s)
+		}
+	}
+}
+
+const testCheats = `# BRIX
+Infinite lives: freeze VE=0x05
+
+Wide paddle: patch 0x30E=0xFF 0x30F=0xFF
+`
+
+func TestParseCheats(t *testing.T) {
+	cheats, err := ParseCheats(strings.NewReader(testCheats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Cheat{
+		{Name: "Infinite lives", Kind: CheatFreeze, Writes: []CheatWrite{
+			{RegisterLocation(RegisterV0 + 0xE), 0x05},
+		}},
+		{Name: "Wide paddle", Kind: CheatPatch, Writes: []CheatWrite{
+			{MemoryLocation(0x30E), 0xFF},
+			{MemoryLocation(0x30F), 0xFF},
+		}},
+	}
+	if !reflect.DeepEqual(cheats, expected) {
+		t.Errorf("Expected %v, Actual %v", expected, cheats)
+	}
+	var buf bytes.Buffer
+	if err := WriteCheats(&buf, cheats); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Infinite lives: freeze VE=0x05\nWide paddle: patch 0x30E=0xFF 0x30F=0xFF\n"; buf.String() != expected {
+		t.Errorf("WriteCheats: Expected %q, Actual %q", expected, buf.String())
+	}
+	for _, s := range []string{"no colon", "Name: freeze", "Name: poke VE=1", "Name: freeze VE", "Name: freeze VE=x", "Name: freeze SP=0x20"} {
+		if _, err := ParseCheats(strings.NewReader(s)); err == nil {
+			t.Errorf("ParseCheats(%q): Expected error", s)
+		}
+	}
+}
+
+func TestCheats(t *testing.T) {
+	cheats, err := ParseCheats(strings.NewReader(testCheats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := New([]uint8{})
+	vm.SetCheats(cheats)
+	vm.V[0xE] = 1
+	vm.Memory[0x30E] = 0x11
+	vm.TickTimers()
+	if vm.V[0xE] != 1 || vm.Memory[0x30E] != 0x11 {
+		t.Fatalf("Expected disabled cheats to not be applied")
+	}
+	vm.ToggleCheat(0)
+	vm.ToggleCheat(1)
+	if vm.V[0xE] != 5 || vm.Memory[0x30E] != 0xFF {
+		t.Fatalf("Expected cheats to be applied when enabled")
+	}
+	vm.V[0xE] = 4
+	vm.Memory[0x30E] = 0x22
+	vm.TickTimers()
+	if vm.V[0xE] != 5 || vm.Memory[0x30E] != 0xFF {
+		t.Fatalf("Expected cheats to be applied every frame")
+	}
+	vm.ToggleCheat(0)
+	vm.ToggleCheat(1)
+	if vm.V[0xE] != 5 || vm.Memory[0x30E] != 0x11 {
+		t.Errorf("Expected only patches to be restored when disabled")
+	}
+	if vm.ToggleCheat(2) != nil {
+		t.Errorf("Expected no third cheat")
+	}
+}
+
+func TestCheatsReset(t *testing.T) {
+	cheats, err := ParseCheats(strings.NewReader(testCheats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := New([]uint8{})
+	vm.SetCheats(cheats)
+	vm.Memory[0x30E] = 0x11
+	vm.ToggleCheat(1)
+
+	// the ROM is reset with the patch enabled
+	vm = New([]uint8{})
+	vm.SetCheats(cheats)
+	if vm.Memory[0x30E] != 0xFF {
+		t.Fatalf("Expected the enabled patch to be applied to the new VM")
+	}
+	vm.ToggleCheat(1)
+	if vm.Memory[0x30E] != 0x00 {
+		t.Errorf("Expected the value of the new VM to be restored, Actual %#x", vm.Memory[0x30E])
+	}
+}
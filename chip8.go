@@ -8,6 +8,8 @@ import (
 const (
 	ScreenWidth  = 64
 	ScreenHeight = 32
+	// AllScanLines has a bit set for every scan line, as in DirtyScanLines
+	AllScanLines = 1<<ScreenHeight - 1
 )
 
 type Random interface {
@@ -48,6 +50,10 @@ type VM struct {
 	// VideoMemory represents the 64x32 pixel screen as 64-bit scan lines
 	VideoMemory [ScreenHeight]uint64
 
+	// DirtyScanLines has a bit set for each scan line changed since the last
+	// TakeDirtyScanLines, bit 0 being the top one
+	DirtyScanLines uint32
+
 	// Keys are a list of flags (0x0 - 0xF) signfying if a key is held down or not
 	Keys [16]bool
 
@@ -57,8 +63,21 @@ type VM struct {
 	// K is the register (0x0 - 0xF) waiting for a key to be pressed
 	K uint8
 
+	// DisplayWait makes DRW wait for the next timer tick, as the original
+	// interpreters waited for the display interrupt before drawing
+	DisplayWait bool
+
+	// IsWaitingForDisplay is true when a DRW is waiting for the next timer tick
+	IsWaitingForDisplay bool
+
 	// random provides a random byte value
 	random Random
+
+	// tracing is non-nil when a Tracer is set
+	tracing *tracing
+
+	// cheating is non-nil when cheats are set
+	cheating *cheating
 }
 
 const (
@@ -86,14 +105,40 @@ var digitSprites = []uint8{
 	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
 }
 
+// Error is returned when the op at PC cannot be executed, e.g. an unsupported
+// op or a RET with an empty stack
+type Error struct {
+	PC     uint16
+	Op     EncodedOp
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at 0x%03X (%04X)", e.Reason, e.PC, uint16(e.Op))
+}
+
+// New returns a VM with a ROM loaded, and panics if the ROM does not fit
 func New(rom []uint8) *VM {
+	vm, err := TryNew(rom)
+	if err != nil {
+		panic(err.Error())
+	}
+	return vm
+}
+
+// TryNew returns a VM with a ROM loaded, or an error if the ROM does not fit
+func TryNew(rom []uint8) (*VM, error) {
 	vm := VM{random: defaultRandom{}, PC: romStartAddress}
 	copy(vm.Memory[0:len(digitSprites)], digitSprites)
-	bytesCopied := copy(vm.Memory[romStartAddress:romStartAddress+len(rom)], rom)
-	if bytesCopied < len(rom) {
-		panic(fmt.Sprintf("Not enough memory to fit ROM of size %d bytes", len(rom)))
+	if len(rom) > len(vm.Memory)-romStartAddress {
+		return nil, fmt.Errorf("Not enough memory to fit ROM of size %d bytes", len(rom))
 	}
-	return &vm
+	copy(vm.Memory[romStartAddress:], rom)
+	return &vm, nil
+}
+
+func (vm *VM) SetRandom(random Random) {
+	vm.random = random
 }
 
 func (vm *VM) SetKeys(keys [16]bool) {
@@ -101,7 +146,7 @@ func (vm *VM) SetKeys(keys [16]bool) {
 }
 
 func (vm *VM) SetKeyDown(key uint8) {
-	if key > 0xff {
+	if key > 0xf {
 		panic(fmt.Sprintf("Unsupported key: %#x", key))
 	}
 	vm.Keys[key] = true
@@ -112,26 +157,87 @@ func (vm *VM) SetKeyDown(key uint8) {
 }
 
 func (vm *VM) SetKeyUp(key uint8) {
-	if key > 0xff {
+	if key > 0xf {
 		panic(fmt.Sprintf("Unsupported key: %#x", key))
 	}
 	vm.Keys[key] = false
 }
 
 func (vm *VM) TickTimers() {
+	vm.IsWaitingForDisplay = false
 	if vm.DT > 0 {
 		vm.DT--
 	}
 	if vm.ST > 0 {
 		vm.ST--
 	}
+	vm.applyCheats()
+}
+
+// TakeDirtyScanLines returns the scan lines changed since the last call, for
+// renderers to redraw only those
+func (vm *VM) TakeDirtyScanLines() uint32 {
+	dirty := vm.DirtyScanLines
+	vm.DirtyScanLines = 0
+	return dirty
 }
 
+// Step executes the op at PC, and panics with an *Error if it cannot
 func (vm *VM) Step() {
-	if vm.IsWaitingForKeyPress {
-		return
+	if err := vm.TryStep(); err != nil {
+		panic(err)
 	}
-	vm.fetch().decode().execute(vm)
+}
+
+// TryStep executes the op at PC, or returns an *Error without changing the VM
+// if it cannot
+func (vm *VM) TryStep() error {
+	if vm.IsWaitingForKeyPress || vm.IsWaitingForDisplay {
+		return nil
+	}
+	op, err := vm.check()
+	if err != nil {
+		return err
+	}
+	if vm.tracing != nil {
+		vm.traceStep()
+		return nil
+	}
+	vm.PC += 2
+	op.execute(vm)
+	return nil
+}
+
+// check decodes the op at PC and checks that it can be executed
+func (vm *VM) check() (Op, error) {
+	encoded, ok := vm.peek()
+	if !ok {
+		return nil, &Error{PC: vm.PC, Reason: "PC outside memory"}
+	}
+	op, ok := encoded.tryDecode()
+	if !ok {
+		return nil, &Error{PC: vm.PC, Op: encoded, Reason: "Unsupported op"}
+	}
+	if c, ok := op.(checker); ok {
+		if reason := c.check(vm); reason != "" {
+			return nil, &Error{PC: vm.PC, Op: encoded, Reason: reason}
+		}
+	}
+	return op, nil
+}
+
+// checker is implemented by ops which cannot always be executed, returning
+// the reason when they cannot
+type checker interface {
+	check(vm *VM) string
+}
+
+// checkMemory returns a reason if n bytes starting at I are outside memory
+func checkMemory(vm *VM, n int) string {
+	if int(vm.I)+n > len(vm.Memory) {
+		return "I outside memory"
+	}
+	return ""
 }
 
 func (vm *VM) fetch() EncodedOp {
@@ -168,95 +274,30 @@ func (op EncodedOp) y() uint8 {
 }
 
 func (op EncodedOp) decode() Op {
-	switch op >> 12 {
-	case 0x0:
-		switch op {
-		case 0x00E0:
-			return op.decodeCLS()
-		case 0x00EE:
-			return op.decodeRET()
-		}
-	case 0x1:
-		return op.decodeJP()
-	case 0x2:
-		return op.decodeCALL()
-	case 0x3:
-		return op.decodeSEVx()
-	case 0x4:
-		return op.decodeSNEVx()
-	case 0x5:
-		switch op & 0x000F {
-		case 0x0:
-			return op.decodeSEVxVy()
-		}
-	case 0x6:
-		return op.decodeLDVx()
-	case 0x7:
-		return op.decodeADDVx()
-	case 0x8:
-		switch op & 0x000F {
-		case 0x0:
-			return op.decodeLDVxVy()
-		case 0x1:
-			return op.decodeORVxVy()
-		case 0x2:
-			return op.decodeANDVxVy()
-		case 0x3:
-			return op.decodeXORVxVy()
-		case 0x4:
-			return op.decodeADDVxVy()
-		case 0x5:
-			return op.decodeSUBVxVy()
-		case 0x6:
-			return op.decodeSHRVx()
-		case 0x7:
-			return op.decodeSUBNVxVy()
-		case 0xE:
-			return op.decodeSHLVx()
-		}
-	case 0x9:
-		switch op & 0x000F {
-		case 0x0:
-			return op.decodeSNEVxVy()
-		}
-	case 0xA:
-		return op.decodeLDI()
-	case 0xB:
-		return op.decodeJPV0()
-	case 0xC:
-		return op.decodeRNDVx()
-	case 0xD:
-		return op.decodeDRWVxVy()
-	case 0xE:
-		switch op & 0x00FF {
-		case 0x9E:
-			return op.decodeSKPVx()
-		case 0xA1:
-			return op.decodeSKNPVx()
-		}
-	case 0xF:
-		switch op & 0x00FF {
-		case 0x07:
-			return op.decodeLDVxDT()
-		case 0x0A:
-			return op.decodeLDVxK()
-		case 0x15:
-			return op.decodeLDDTVx()
-		case 0x18:
-			return op.decodeLDSTVx()
-		case 0x1E:
-			return op.decodeADDIVx()
-		case 0x29:
-			return op.decodeLDFVx()
-		case 0x33:
-			return op.decodeLDBVx()
-		case 0x55:
-			return op.decodeLDIVx()
-		case 0x65:
-			return op.decodeLDVxI()
-		}
+	decoded, ok := op.tryDecode()
+	if !ok {
+		panic(fmt.Sprintf("Unsupported op: %#X", op))
+	}
+	return decoded
+}
+
+// Disassemble returns the assembly for a supported op, and a data word otherwise
+func (op EncodedOp) Disassemble() string {
+	return PlatformCHIP8.Disassemble(op)
+}
+
+// IsValid reports whether an op is supported
+func (op EncodedOp) IsValid() bool {
+	_, ok := op.tryDecode()
+	return ok
+}
+
+func (op EncodedOp) tryDecode() (Op, bool) {
+	in := PlatformCHIP8.Lookup(op)
+	if in == nil || in.decode == nil {
+		return nil, false
 	}
-	panic(fmt.Sprintf("Unsupported op: %#X", op))
+	return in.decode(op), true
 }
 
 type Op interface {
@@ -276,6 +317,9 @@ func (op EncodedOp) decodeCLS() CLS {
 
 func (op CLS) execute(vm *VM) {
 	for i := range vm.VideoMemory {
+		if vm.VideoMemory[i] != 0 {
+			vm.DirtyScanLines |= 1 << uint(i)
+		}
 		vm.VideoMemory[i] = 0
 	}
 }
@@ -294,6 +338,16 @@ func (op EncodedOp) decodeRET() RET {
 	return RET{}
 }
 
+func (op RET) check(vm *VM) string {
+	if vm.SP == 0 {
+		return "Stack underflow"
+	}
+	if int(vm.SP) > len(vm.Stack) {
+		return "Stack pointer out of range"
+	}
+	return ""
+}
+
 func (op RET) execute(vm *VM) {
 	vm.SP--
 	vm.PC = vm.Stack[vm.SP]
@@ -334,10 +388,14 @@ func (op EncodedOp) decodeCALL() CALL {
 	return CALL{op.nnn()}
 }
 
-func (op CALL) execute(vm *VM) {
+func (op CALL) check(vm *VM) string {
 	if vm.SP >= uint8(len(vm.Stack)) {
-		panic("Stack overflow")
+		return "Stack overflow"
 	}
+	return ""
+}
+
+func (op CALL) execute(vm *VM) {
 	vm.Stack[vm.SP] = vm.PC
 	vm.SP += 1
 	vm.PC = op.nnn
@@ -771,6 +829,10 @@ func expandSpriteRowToScanLine(spriteRow, x uint8) uint64 {
 	}
 }
 
+func (op DRWVxVy) check(vm *VM) string {
+	return checkMemory(vm, int(op.n))
+}
+
 func (op DRWVxVy) execute(vm *VM) {
 	collision := false
 	x0 := vm.V[op.x]
@@ -784,6 +846,9 @@ func (op DRWVxVy) execute(vm *VM) {
 		oldScanLine := vm.VideoMemory[y]
 		newScanLine := oldScanLine ^ expandSpriteRowToScanLine(spriteRow, x0)
 		vm.VideoMemory[y] = newScanLine
+		if newScanLine != oldScanLine {
+			vm.DirtyScanLines |= 1 << y
+		}
 		if (oldScanLine & ^newScanLine) > 0 {
 			collision = true
 		}
@@ -793,6 +858,7 @@ func (op DRWVxVy) execute(vm *VM) {
 	} else {
 		vm.V[0xF] = 0
 	}
+	vm.IsWaitingForDisplay = vm.DisplayWait
 }
 
 /*
@@ -801,7 +867,8 @@ Ex9E - SKP Vx
 Skip next instruction if key with the value of Vx is pressed.
 
 Checks the keyboard, and if the key corresponding to the value of Vx is
-currently in the down position, PC is increased by 2.
+currently in the down position, PC is increased by 2. Only the lowest 4 bits
+of Vx are used.
 */
 type SKPVx struct {
 	x uint8
@@ -812,7 +879,7 @@ func (op EncodedOp) decodeSKPVx() SKPVx {
 }
 
 func (op SKPVx) execute(vm *VM) {
-	if vm.Keys[vm.V[op.x]] {
+	if vm.Keys[vm.V[op.x]&0xF] {
 		vm.PC += 2
 	}
 }
@@ -823,7 +890,8 @@ ExA1 - SKNP Vx
 Skip next instruction if key with the value of Vx is not pressed.
 
 Checks the keyboard, and if the key corresponding to the value of Vx is
-currently in the up position, PC is increased by 2.
+currently in the up position, PC is increased by 2. Only the lowest 4 bits of
+Vx are used.
 */
 type SKNPVx struct {
 	x uint8
@@ -834,7 +902,7 @@ func (op EncodedOp) decodeSKNPVx() SKNPVx {
 }
 
 func (op SKNPVx) execute(vm *VM) {
-	if !vm.Keys[vm.V[op.x]] {
+	if !vm.Keys[vm.V[op.x]&0xF] {
 		vm.PC += 2
 	}
 }
@@ -984,6 +1052,10 @@ func bcd(n uint8) (hundreds, tens, ones uint8) {
 	return
 }
 
+func (op LDBVx) check(vm *VM) string {
+	return checkMemory(vm, 3)
+}
+
 func (op LDBVx) execute(vm *VM) {
 	vm.Memory[vm.I], vm.Memory[vm.I+1], vm.Memory[vm.I+2] = bcd(vm.V[op.x])
 }
@@ -1004,6 +1076,10 @@ func (op EncodedOp) decodeLDIVx() LDIVx {
 	return LDIVx{op.x()}
 }
 
+func (op LDIVx) check(vm *VM) string {
+	return checkMemory(vm, int(op.x)+1)
+}
+
 func (op LDIVx) execute(vm *VM) {
 	for i := 0; i <= int(op.x); i++ {
 		vm.Memory[vm.I+uint16(i)] = vm.V[i]
@@ -1026,6 +1102,10 @@ func (op EncodedOp) decodeLDVxI() LDVxI {
 	return LDVxI{op.x()}
 }
 
+func (op LDVxI) check(vm *VM) string {
+	return checkMemory(vm, int(op.x)+1)
+}
+
 func (op LDVxI) execute(vm *VM) {
 	for i := 0; i <= int(op.x); i++ {
 		vm.V[i] = vm.Memory[vm.I+uint16(i)]
@@ -1,6 +1,7 @@
 package chip8
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -148,7 +149,7 @@ func TestOps(t *testing.T) {
 		{
 			before: VM{VideoMemory: [32]uint64{0: 0x1, 31: 0x1}},
 			op:     CLS{},
-			after:  VM{},
+			after:  VM{DirtyScanLines: 0x80000001},
 		},
 
 		/*
@@ -546,6 +547,7 @@ func TestOps(t *testing.T) {
 					0x2: 0x00F0000000000000,
 					0x3: 0x0FF0000000000000,
 				},
+				DirtyScanLines: 0xE,
 			},
 		},
 
@@ -579,6 +581,7 @@ func TestOps(t *testing.T) {
 					0x2: 0x0000000000000000,
 					0x3: 0x0FF0000000000000,
 				},
+				DirtyScanLines: 0xE,
 			},
 		},
 
@@ -612,6 +615,7 @@ func TestOps(t *testing.T) {
 					0x2: 0x0000000000000000,
 					0x3: 0x000000000000000F,
 				},
+				DirtyScanLines: 0xA,
 			},
 		},
 
@@ -645,9 +649,17 @@ func TestOps(t *testing.T) {
 					0x2: 0x0000000000000000,
 					0x3: 0x000000000000000F,
 				},
+				DirtyScanLines: 0xA,
 			},
 		},
 
+		{
+			msg:    "display wait",
+			before: VM{I: 0x5, DisplayWait: true},
+			op:     DRWVxVy{x: 0xA, y: 0xB, n: 1},
+			after:  VM{I: 0x5, DisplayWait: true, IsWaitingForDisplay: true},
+		},
+
 		/*
 			Ex9E - SKP Vx
 			Skip next instruction if key with the value of Vx is pressed.
@@ -825,3 +837,122 @@ func TestOps(t *testing.T) {
 		}
 	}
 }
+
+func TestDisplayWait(t *testing.T) {
+	vm := New([]uint8{
+		0xD0, 0x01, // DRW V0, V0, 1
+		0x70, 0x01, // ADD V0, 0x01
+		0x12, 0x00, // JP 0x200
+	})
+	vm.DisplayWait = true
+	vm.Step()
+	if !vm.IsWaitingForDisplay {
+		t.Fatal("Expected DRW to wait for the display")
+	}
+	for i := 0; i < 10; i++ {
+		vm.Step()
+	}
+	if vm.PC != 0x202 || vm.V[0] != 0 {
+		t.Errorf("Expected no steps while waiting, Actual PC 0x%03X", vm.PC)
+	}
+	vm.TickTimers()
+	if vm.IsWaitingForDisplay {
+		t.Fatal("Expected the timer tick to end the wait")
+	}
+	vm.Step()
+	if vm.PC != 0x204 || vm.V[0] != 1 {
+		t.Errorf("Expected a step after the timer tick, Actual PC 0x%03X", vm.PC)
+	}
+}
+
+func TestTakeDirtyScanLines(t *testing.T) {
+	vm := New([]uint8{
+		0x00, 0xE0, // CLS
+		0x61, 0x1E, // LD V1, 30
+		0xD0, 0x12, // DRW V0, V1, 2
+		0xD0, 0x12, // DRW V0, V1, 2
+	})
+	for _, expected := range []uint32{0, 0, 0xC0000000, 0xC0000000} {
+		vm.Step()
+		if actual := vm.TakeDirtyScanLines(); actual != expected {
+			t.Errorf("Expected 0x%08X, Actual 0x%08X", expected, actual)
+		}
+	}
+	if vm.DirtyScanLines != 0 {
+		t.Error("Expected TakeDirtyScanLines to clear the dirty scan lines")
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	for _, testCase := range []struct {
+		op       EncodedOp
+		expected string
+	}{
+		{0x00E0, "CLS"},
+		{0x00EE, "RET"},
+		{0x1123, "JP 0x123"},
+		{0x2123, "CALL 0x123"},
+		{0x3123, "SE V1, 0x23"},
+		{0x5120, "SE V1, V2"},
+		{0x6A05, "LD VA, 0x05"},
+		{0x8124, "ADD V1, V2"},
+		{0x812E, "SHL V1"},
+		{0xA123, "LD I, 0x123"},
+		{0xB123, "JP V0, 0x123"},
+		{0xD125, "DRW V1, V2, 5"},
+		{0xE19E, "SKP V1"},
+		{0xF10A, "LD V1, K"},
+		{0xF155, "LD [I], V1"},
+		{0xF165, "LD V1, [I]"},
+		{0x0000, "DW 0x0000"},
+		{0xFFFF, "DW 0xFFFF"},
+	} {
+		if actual := testCase.op.Disassemble(); actual != testCase.expected {
+			t.Errorf("(%#x).Disassemble(): Expected %q, Actual %q", testCase.op, testCase.expected, actual)
+		}
+	}
+}
+
+type recordingTracer struct {
+	records []TraceRecord
+}
+
+func (r *recordingTracer) Trace(record *TraceRecord) {
+	copied := *record
+	copied.Registers = append([]RegisterChange(nil), record.Registers...)
+	copied.MemoryWrites = append([]MemoryWrite(nil), record.MemoryWrites...)
+	r.records = append(r.records, copied)
+}
+
+func TestTrace(t *testing.T) {
+	vm := New([]uint8{
+		0x60, 0x7B, // LD V0, 123
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x33, // LD B, V0
+		0x12, 0x06, // JP 0x206
+	})
+	tracer := &recordingTracer{}
+	vm.SetTracer(tracer)
+	for i := 0; i < 4; i++ {
+		vm.Step()
+	}
+	expected := []TraceRecord{
+		{Cycle: 0, PC: 0x200, Op: 0x607B, Registers: []RegisterChange{{RegisterV0, 0, 123}}},
+		{Cycle: 1, PC: 0x202, Op: 0xA300, Registers: []RegisterChange{{RegisterI, 0, 0x300}}},
+		{Cycle: 2, PC: 0x204, Op: 0xF033, MemoryWrites: []MemoryWrite{{0x300, 0, 1}, {0x301, 0, 2}, {0x302, 0, 3}}},
+		{Cycle: 3, PC: 0x206, Op: 0x1206},
+	}
+	if len(tracer.records) != len(expected) {
+		t.Fatalf("Expected %d records, Actual %d", len(expected), len(tracer.records))
+	}
+	for i, record := range tracer.records {
+		if fmt.Sprint(record) != fmt.Sprint(expected[i]) {
+			t.Errorf("record %d: Expected %+v, Actual %+v", i, expected[i], record)
+		}
+	}
+	vm.SetTracer(nil)
+	vm.Step()
+	if len(tracer.records) != len(expected) {
+		t.Error("Expected no records after removing the tracer")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Package chip8test provides helpers for testing code built on the chip8 package.
+*/
+package chip8test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+// Diff returns a description of each difference in the state of two VMs
+func Diff(a, b *chip8.VM) []string {
+	var diffs []string
+	add := func(format string, args ...interface{}) {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+	}
+	for i := range a.V {
+		if a.V[i] != b.V[i] {
+			add("V%X: 0x%02X != 0x%02X", i, a.V[i], b.V[i])
+		}
+	}
+	if a.I != b.I {
+		add("I: 0x%03X != 0x%03X", a.I, b.I)
+	}
+	if a.PC != b.PC {
+		add("PC: 0x%03X != 0x%03X", a.PC, b.PC)
+	}
+	if a.SP != b.SP {
+		add("SP: %d != %d", a.SP, b.SP)
+	}
+	if a.DT != b.DT {
+		add("DT: %d != %d", a.DT, b.DT)
+	}
+	if a.ST != b.ST {
+		add("ST: %d != %d", a.ST, b.ST)
+	}
+	if a.Stack != b.Stack {
+		add("Stack: %v != %v", a.Stack, b.Stack)
+	}
+	if a.IsWaitingForKeyPress != b.IsWaitingForKeyPress || a.K != b.K {
+		add("waiting for key: %v V%X != %v V%X", a.IsWaitingForKeyPress, a.K, b.IsWaitingForKeyPress, b.K)
+	}
+	if a.IsWaitingForDisplay != b.IsWaitingForDisplay {
+		add("waiting for display: %v != %v", a.IsWaitingForDisplay, b.IsWaitingForDisplay)
+	}
+	for address := range a.Memory {
+		if a.Memory[address] != b.Memory[address] {
+			add("[0x%03X]: 0x%02X != 0x%02X", address, a.Memory[address], b.Memory[address])
+		}
+	}
+	for y := range a.VideoMemory {
+		if a.VideoMemory[y] != b.VideoMemory[y] {
+			add("scan line %d: %064b != %064b", y, a.VideoMemory[y], b.VideoMemory[y])
+		}
+	}
+	return diffs
+}
+
+type LockstepOptions struct {
+	// Cycles is the number of steps to run
+	Cycles int
+
+	// TimerInterval ticks the timers of both VMs every TimerInterval steps,
+	// 0 means never
+	TimerInterval int
+}
+
+// Lockstep steps two VMs side by side and fails the test at the first step
+// after which their states differ or either VM fails, reporting the
+// instruction each executed
+func Lockstep(t testing.TB, a, b *chip8.VM, opts LockstepOptions) {
+	t.Helper()
+	for cycle := 0; cycle < opts.Cycles; cycle++ {
+		if opts.TimerInterval > 0 && cycle%opts.TimerInterval == 0 {
+			a.TickTimers()
+			b.TickTimers()
+		}
+		pcA, pcB := a.PC, b.PC
+		opA, opB := peek(a), peek(b)
+		if err := a.TryStep(); err != nil {
+			t.Fatalf("VM a failed at cycle %d: %v", cycle, err)
+			return
+		}
+		if err := b.TryStep(); err != nil {
+			t.Fatalf("VM b failed at cycle %d: %v", cycle, err)
+			return
+		}
+		if diffs := Diff(a, b); len(diffs) > 0 {
+			t.Fatalf("VMs diverged at cycle %d\n"+
+				"  a: 0x%03X %04X %s\n"+
+				"  b: 0x%03X %04X %s\n"+
+				"  %s",
+				cycle,
+				pcA, uint16(opA), opA.Disassemble(),
+				pcB, uint16(opB), opB.Disassemble(),
+				strings.Join(diffs, "\n  "))
+		}
+	}
+}
+
+// peek returns the op at the PC, or 0 if the PC is outside memory, which
+// TryStep reports
+func peek(vm *chip8.VM) chip8.EncodedOp {
+	if int(vm.PC)+1 >= len(vm.Memory) {
+		return 0
+	}
+	return chip8.EncodedOp(uint16(vm.Memory[vm.PC])<<8 | uint16(vm.Memory[vm.PC+1]))
+}
@@ -0,0 +1,81 @@
+package chip8test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+// fatalRecorder captures a Fatalf instead of stopping the test
+type fatalRecorder struct {
+	testing.TB
+	message string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...interface{}) {
+	if r.message == "" {
+		r.message = fmt.Sprintf(format, args...)
+	}
+}
+
+var rom = []uint8{
+	0x60, 0x00, // 0x200 LD V0, 0
+	0x70, 0x01, // 0x202 ADD V0, 1
+	0x12, 0x02, // 0x204 JP 0x202
+}
+
+func TestLockstepAgree(t *testing.T) {
+	Lockstep(t, chip8.New(rom), chip8.New(rom), LockstepOptions{Cycles: 100, TimerInterval: 8})
+}
+
+type constant uint8
+
+func (c constant) Next() uint8 {
+	return uint8(c)
+}
+
+func TestLockstepDiverge(t *testing.T) {
+	rom := []uint8{
+		0x60, 0x00, // 0x200 LD V0, 0
+		0xC1, 0x0F, // 0x202 RND V1, 0x0F
+	}
+	a, b := chip8.New(rom), chip8.New(rom)
+	a.SetRandom(constant(1))
+	b.SetRandom(constant(2))
+	r := &fatalRecorder{TB: t}
+	Lockstep(r, a, b, LockstepOptions{Cycles: 2})
+	for _, expected := range []string{"cycle 1", "RND V1, 0x0F", "V1: 0x01 != 0x02"} {
+		if !strings.Contains(r.message, expected) {
+			t.Errorf("Expected %q in %q", expected, r.message)
+		}
+	}
+}
+
+func TestLockstepError(t *testing.T) {
+	rom := []uint8{
+		0x1F, 0xFF, // 0x200 JP 0xFFF
+	}
+	r := &fatalRecorder{TB: t}
+	Lockstep(r, chip8.New(rom), chip8.New(rom), LockstepOptions{Cycles: 2})
+	for _, expected := range []string{"VM a failed at cycle 1", "PC outside memory"} {
+		if !strings.Contains(r.message, expected) {
+			t.Errorf("Expected %q in %q", expected, r.message)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, b := chip8.New(rom), chip8.New(rom)
+	if diffs := Diff(a, b); len(diffs) != 0 {
+		t.Errorf("Expected no differences, Actual %v", diffs)
+	}
+	b.Memory[0x300] = 1
+	b.VideoMemory[0] = 1
+	if diffs := Diff(a, b); len(diffs) != 2 {
+		t.Errorf("Expected 2 differences, Actual %v", diffs)
+	}
+}
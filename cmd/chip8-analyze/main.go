@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/odsod/chip8/analysis"
+)
+
+func write(name string, f func(io.Writer) error) {
+	if name == "" {
+		return
+	}
+	out, err := os.Create(name)
+	if err != nil {
+		panic(err)
+	}
+	defer out.Close()
+	if err := f(out); err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	romFile := flag.String("rom", "roms/TETRIS", "The ROM to analyze")
+	dotFile := flag.String("dot", "", "Write the control-flow graph in Graphviz DOT format to this file")
+	jsonFile := flag.String("json", "", "Write the analysis as JSON to this file")
+	flag.Parse()
+
+	rom, err := ioutil.ReadFile(*romFile)
+	if err != nil {
+		panic(err)
+	}
+	a := analysis.Analyze(rom)
+	write(*dotFile, a.WriteDOT)
+	write(*jsonFile, a.WriteJSON)
+	if err := a.WriteSummary(os.Stdout); err != nil {
+		panic(err)
+	}
+}
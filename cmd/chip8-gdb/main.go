@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/gdbstub"
+	"github.com/odsod/chip8/sprites"
+)
+
+func main() {
+	romFile := flag.String("rom", "roms/TETRIS", "The ROM to load")
+	addr := flag.String("addr", "localhost:1234", "The address to listen on")
+	cpuFrequencyHz := flag.Int("cpuFrequency", 500, "The CPU frequency (Hz)")
+	timerFrequencyHz := flag.Int("timerFrequency", 60, "The timer frequency (Hz)")
+	flag.Parse()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	rom, err := ioutil.ReadFile(*romFile)
+	if err != nil {
+		panic(err)
+	}
+	vm := chip8.New(rom)
+	recorder := sprites.NewRecorder(vm)
+	vm.SetTracer(recorder)
+	stub := gdbstub.New(vm, gdbstub.Options{
+		CPUFrequencyHz:   *cpuFrequencyHz,
+		TimerFrequencyHz: *timerFrequencyHz,
+		Sprites:          recorder,
+	})
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("Debugging %s, attach with: target remote %s", *romFile, l.Addr())
+	panic(stub.Serve(l))
+}
@@ -2,28 +2,93 @@ package main
 
 import (
 	"flag"
+	"log"
 	"math/rand"
 	"time"
 
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/profile"
+	"github.com/odsod/chip8/trace"
 	"github.com/odsod/chip8/ui/opengl"
+	"github.com/odsod/chip8/ui/raster"
+	"github.com/odsod/chip8/ui/settings"
 )
 
 func main() {
+	// the saved settings are the defaults of the flags, black on white
+	// being the default colors of the OpenGL UI
+	defaults := settings.Default
+	defaults.Theme = "paper"
+	settingsPath := settings.DefaultPath()
+	saved, err := settings.Load(settingsPath, defaults)
+	if err != nil {
+		log.Printf("Ignoring the settings in %s: %v", settingsPath, err)
+	}
+	if _, ok := opengl.KeyboardLayouts[saved.KeyboardLayout]; !ok {
+		saved.KeyboardLayout = defaults.KeyboardLayout
+	}
+
 	romFile := flag.String("rom", "roms/TETRIS", "The ROM to load")
-	cpuFrequencyHz := flag.Int("cpuFrequency", 500, "The CPU frequency (Hz)")
-	timerFrequencyHz := flag.Int("timerFrequency", 60, "The timer frequency (Hz)")
+	keyboardLayout := flag.String("keyboard", saved.KeyboardLayout, "The keyboard layout: qwer or dvorak")
+	cpuFrequencyHz := flag.Int("cpuFrequency", saved.CPUFrequencyHz, "The CPU frequency (Hz)")
+	timerFrequencyHz := flag.Int("timerFrequency", saved.TimerFrequencyHz, "The timer frequency (Hz)")
 	scale := flag.Int("scale", 8, "The graphics upscaling coefficient")
-	pixelFadeTimeMs := flag.Int("pixelFadeTime", 90, "The pixel fade time (ms)")
+	pixelFadeTimeMs := flag.Int("pixelFadeTime", saved.PixelFadeTimeMs, "The pixel fade time (ms)")
+	themeName := flag.String("theme", saved.Theme, "The color theme without CRT shaders: paper, white, green, amber, lcd or octo")
+	vipTiming := flag.Bool("vipTiming", saved.VIPTiming, "Use COSMAC VIP instruction timing instead of the CPU and timer frequencies")
+	displayWait := flag.Bool("displayWait", saved.DisplayWait, "Make DRW wait for the next timer tick")
+	filterName := flag.String("filter", "nearest", "The upscaling filter without CRT shaders: nearest, scale2x or scale4x")
+	crtPreset := flag.String("crt", "off", "The CRT shader preset: off, scanlines, crt or green")
+	cheatFile := flag.String("cheats", "", "A cheat file, with cheats toggled by F1 - F9")
+	traceFlags := trace.RegisterFlags(flag.CommandLine)
+	profileFlags := profile.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	rand.Seed(time.Now().UTC().UnixNano())
 
+	filter, err := raster.ParseFilter(*filterName)
+	if err != nil {
+		panic(err)
+	}
+
+	crt, err := opengl.ParseCRT(*crtPreset)
+	if err != nil {
+		panic(err)
+	}
+
+	cheats, err := chip8.LoadCheats(*cheatFile)
+	if err != nil {
+		panic(err)
+	}
+
+	tracer, err := traceFlags.Open()
+	if err != nil {
+		panic(err)
+	}
+	defer traceFlags.Close()
+
+	profiler, err := profileFlags.Open(*romFile)
+	if err != nil {
+		panic(err)
+	}
+	defer profileFlags.Close()
+
 	ui := opengl.NewUI(opengl.Options{
 		RomFile:          *romFile,
 		CPUFrequencyHz:   *cpuFrequencyHz,
 		TimerFrequencyHz: *timerFrequencyHz,
 		Scale:            *scale,
 		PixelFadeTime:    time.Duration(*pixelFadeTimeMs) * time.Millisecond,
+		Tracer:           trace.Tee(tracer, profiler),
+		VIPTiming:        *vipTiming,
+		DisplayWait:      *displayWait,
+		Cheats:           cheats,
+		Filter:           filter,
+		CRT:              crt,
+		ThemeName:        *themeName,
+		Sound:            saved.Sound,
+		KeyboardLayout:   *keyboardLayout,
+		SettingsPath:     settingsPath,
 	})
 
 	ui.Run()
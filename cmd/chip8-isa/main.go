@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/odsod/chip8"
+)
+
+func main() {
+	out := flag.String("o", "", "The file to write to (default: stdout)")
+	flag.Parse()
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := chip8.WriteInstructionsMarkdown(w); err != nil {
+		panic(err)
+	}
+}
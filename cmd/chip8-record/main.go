@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/odsod/chip8/ui/raster"
+	"github.com/odsod/chip8/video"
+)
+
+func main() {
+	defaults := video.DefaultOptions
+	romFile := flag.String("rom", "roms/TETRIS", "The ROM to record")
+	scriptFile := flag.String("script", "", "The input script, with the keys held down by frame (default: no input)")
+	videoFile := flag.String("video", "chip8.y4m", "Write the Y4M video to this file, none if empty")
+	audioFile := flag.String("audio", "chip8.wav", "Write the WAV sound to this file, none if empty")
+	frames := flag.Int("frames", defaults.Frames, "The number of 60 Hz frames to record")
+	cpuFrequencyHz := flag.Int("cpuFrequency", defaults.CPUFrequencyHz, "The CPU frequency (Hz)")
+	width := flag.Int("width", defaults.Width, "The video width, which must be even")
+	height := flag.Int("height", defaults.Height, "The video height, which must be even")
+	filterName := flag.String("filter", string(defaults.Filter), "The upscaling filter: nearest, scale2x or scale4x")
+	pixelFadeTimeMs := flag.Int("pixelFadeTime", int(defaults.PixelFadeTime/time.Millisecond), "The pixel fade time (ms)")
+	seed := flag.Int64("seed", defaults.Seed, "The seed of the random source")
+	displayWait := flag.Bool("displayWait", false, "Make DRW wait for the next frame")
+	flag.Parse()
+
+	rom, err := ioutil.ReadFile(*romFile)
+	if err != nil {
+		panic(err)
+	}
+	filter, err := raster.ParseFilter(*filterName)
+	if err != nil {
+		panic(err)
+	}
+	var script video.Script
+	if *scriptFile != "" {
+		f, err := os.Open(*scriptFile)
+		if err != nil {
+			panic(err)
+		}
+		script, err = video.ParseScript(f)
+		f.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var videoOut io.Writer
+	if *videoFile != "" {
+		f, err := os.Create(*videoFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		videoOut = f
+	}
+	var audioOut io.WriteSeeker
+	if *audioFile != "" {
+		f, err := os.Create(*audioFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		audioOut = f
+	}
+
+	opts := video.Options{
+		Frames:         *frames,
+		CPUFrequencyHz: *cpuFrequencyHz,
+		Width:          *width,
+		Height:         *height,
+		Filter:         filter,
+		PixelFadeTime:  time.Duration(*pixelFadeTimeMs) * time.Millisecond,
+		Seed:           *seed,
+		DisplayWait:    *displayWait,
+	}
+	vm, err := video.Record(rom, script, videoOut, audioOut, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Recorded %d frames, ending at PC %#04x", opts.Frames, vm.PC)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/odsod/chip8/remote"
+)
+
+func main() {
+	romFile := flag.String("rom", "", "The ROM to load on startup (optional)")
+	network := flag.String("network", "tcp", "The socket type, tcp or unix")
+	addr := flag.String("addr", "localhost:8765", "The address to listen on")
+	cpuFrequencyHz := flag.Int("cpuFrequency", 500, "The CPU frequency (Hz)")
+	timerFrequencyHz := flag.Int("timerFrequency", 60, "The timer frequency (Hz)")
+	flag.Parse()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	service := remote.NewService(remote.Options{
+		CPUFrequencyHz:   *cpuFrequencyHz,
+		TimerFrequencyHz: *timerFrequencyHz,
+	})
+	if *romFile != "" {
+		rom, err := ioutil.ReadFile(*romFile)
+		if err != nil {
+			panic(err)
+		}
+		if err := service.LoadROM(remote.LoadROMArgs{ROM: rom}, &remote.Empty{}); err != nil {
+			panic(err)
+		}
+	}
+
+	l, err := net.Listen(*network, *addr)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("Serving JSON-RPC on %s %s", *network, l.Addr())
+	panic(remote.Serve(l, service))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/analysis"
+	"github.com/odsod/chip8/sprites"
+)
+
+const romStartAddress = 0x200
+
+func parseAddress(s string, fallback int) int {
+	if s == "" {
+		return fallback
+	}
+	address, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		panic(err)
+	}
+	return int(address)
+}
+
+func main() {
+	romFile := flag.String("rom", "roms/TETRIS", "The ROM to extract sprites from")
+	start := flag.String("start", "", "The first address to show (default: the start of the ROM)")
+	end := flag.String("end", "", "The address after the last to show (default: the end of the ROM)")
+	frames := flag.Int("frames", 600, "The number of frames to run the ROM for, recording the sprites it draws")
+	cpuFrequencyHz := flag.Int("cpuFrequency", 500, "The CPU frequency (Hz)")
+	static := flag.Bool("static", true, "Also highlight the sprites found by static analysis")
+	pngFile := flag.String("png", "", "Write a PNG sheet of the memory range to this file")
+	scale := flag.Int("scale", sprites.DefaultSheetOptions.Scale, "The size of a pixel in the PNG sheet")
+	rowsPerColumn := flag.Int("rows", sprites.DefaultSheetOptions.RowsPerColumn, "The number of bytes per column in the PNG sheet")
+	asmFile := flag.String("asm", "", "Write the highlighted sprites as DB directives to this file")
+	flag.Parse()
+
+	rom, err := ioutil.ReadFile(*romFile)
+	if err != nil {
+		panic(err)
+	}
+	vm := chip8.New(rom)
+	recorder := sprites.NewRecorder(vm)
+	vm.SetTracer(recorder)
+	for frame := 1; frame <= *frames; frame++ {
+		vm.TickTimers()
+		for cycles := 0; cycles < *cpuFrequencyHz/60; cycles++ {
+			vm.Step()
+		}
+	}
+
+	heights := make(map[uint16]uint8)
+	for _, r := range recorder.Regions() {
+		heights[r.Address] = r.Height
+	}
+	if *static {
+		for _, s := range analysis.Analyze(rom).Sprites {
+			if s.Height > heights[s.Address] {
+				heights[s.Address] = s.Height
+			}
+		}
+	}
+	regions := sprites.Merge(heights)
+
+	// The initial memory, as the ROM may have overwritten its sprites
+	memory := chip8.New(rom).Memory[:]
+	first := parseAddress(*start, romStartAddress)
+	last := parseAddress(*end, romStartAddress+len(rom))
+	if err := sprites.WriteText(os.Stdout, memory, first, last, regions); err != nil {
+		panic(err)
+	}
+	if *pngFile != "" {
+		f, err := os.Create(*pngFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		img := sprites.Render(memory, first, last, regions, sprites.SheetOptions{
+			RowsPerColumn: *rowsPerColumn,
+			Scale:         *scale,
+		})
+		if err := png.Encode(f, img); err != nil {
+			panic(err)
+		}
+	}
+	if *asmFile != "" {
+		f, err := os.Create(*asmFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		var shown []sprites.Region
+		for _, r := range regions {
+			if int(r.Address) >= first && int(r.Address) < last {
+				shown = append(shown, r)
+			}
+		}
+		if err := sprites.WriteDB(f, memory, shown); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"time"
+
+	"github.com/odsod/chip8/ui/ssh"
+	"github.com/odsod/chip8/ui/terminal"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:2222", "The SSH address to listen on")
+	hostKeyFile := flag.String("hostKey", "", "The host private key file, generated on startup if not set")
+	authorizedKeysFile := flag.String("authorizedKeys", "", "The authorized_keys file of the clients allowed to connect, clients not being authenticated if not set")
+	romDir := flag.String("roms", "roms", "The directory of ROMs to pick from")
+	cpuFrequencyHz := flag.Int("cpuFrequency", 500, "The CPU frequency (Hz)")
+	timerFrequencyHz := flag.Int("timerFrequency", 60, "The timer frequency (Hz)")
+	frameRateHz := flag.Int("frameRate", 30, "The frame rate (Hz)")
+	keyPressDurationMs := flag.Int("keyPressDuration", 100, "The key press duration (ms)")
+	themeName := flag.String("theme", "white", "The color theme: white, green, amber, lcd or octo")
+	pixelFadeTimeMs := flag.Int("pixelFadeTime", 90, "The pixel fade time (ms)")
+	flag.Parse()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	theme, err := terminal.ParseTheme(*themeName, "", "")
+	if err != nil {
+		panic(err)
+	}
+
+	ui := ssh.NewUI(ssh.Options{
+		Addr:               *addr,
+		HostKeyFile:        *hostKeyFile,
+		AuthorizedKeysFile: *authorizedKeysFile,
+		RomDir:             *romDir,
+		CPUFrequencyHz:     *cpuFrequencyHz,
+		TimerFrequencyHz:   *timerFrequencyHz,
+		FrameRateHz:        *frameRateHz,
+		KeyPressDuration:   time.Duration(*keyPressDurationMs) * time.Millisecond,
+		Theme:              theme,
+		PixelFadeTime:      time.Duration(*pixelFadeTimeMs) * time.Millisecond,
+	})
+
+	ui.Run()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/odsod/chip8/trace"
+)
+
+func open(name string) trace.Reader {
+	f, err := os.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	r, err := trace.NewReader(f)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func main() {
+	context := flag.Int("context", 8, "The number of preceding instructions to show")
+	instructionsOnly := flag.Bool("instructionsOnly", false, "Only compare PC and op, not register changes and memory writes")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] A B\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Reports the first divergent instruction of two execution traces.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	nameA, nameB := flag.Arg(0), flag.Arg(1)
+	divergence, err := trace.Diff(open(nameA), open(nameB), trace.DiffOptions{
+		Context:          *context,
+		InstructionsOnly: *instructionsOnly,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if divergence == nil {
+		fmt.Println("Traces are identical")
+		return
+	}
+	divergence.Report(os.Stdout, nameA, nameB)
+	os.Exit(1)
+}
@@ -0,0 +1,153 @@
+//go:build js && wasm
+
+// Command chip8-wasm exposes the CHIP-8 VM to JavaScript as a global chip8
+// object. See index.html for an example host page.
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/odsod/chip8"
+)
+
+const (
+	frameRateHz          = 60
+	defaultCPUHz         = 500
+	defaultTimerHz       = 60
+	framebufferSizeBytes = chip8.ScreenWidth * chip8.ScreenHeight
+)
+
+type emulator struct {
+	vm               *chip8.VM
+	cpuFrequencyHz   int
+	timerFrequencyHz int
+	frames           int
+	cpuCycles        int
+	timerCycles      int
+	// err is the error that stopped the VM, until another ROM is loaded
+	err error
+}
+
+func (e *emulator) loadROM(rom []uint8) error {
+	vm, err := chip8.TryNew(rom)
+	if err != nil {
+		return err
+	}
+	e.vm, e.err = vm, nil
+	e.frames, e.cpuCycles, e.timerCycles = 0, 0, 0
+	return nil
+}
+
+// stepFrame runs the VM for 1/60th of a second of emulated time, or returns
+// the error that stopped it
+func (e *emulator) stepFrame() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.frames++
+	for ; e.timerCycles < e.frames*e.timerFrequencyHz/frameRateHz; e.timerCycles++ {
+		e.vm.TickTimers()
+	}
+	for ; e.cpuCycles < e.frames*e.cpuFrequencyHz/frameRateHz; e.cpuCycles++ {
+		if e.err = e.vm.TryStep(); e.err != nil {
+			return e.err
+		}
+	}
+	return nil
+}
+
+// setKey goes through SetKeyDown so that a pending LD Vx, K sees the press
+func (e *emulator) setKey(key uint8, down bool) {
+	switch {
+	case down && !e.vm.Keys[key]:
+		e.vm.SetKeyDown(key)
+	case !down && e.vm.Keys[key]:
+		e.vm.SetKeyUp(key)
+	}
+}
+
+// framebuffer returns one byte per pixel, 0 or 1, row by row
+func (e *emulator) framebuffer() []uint8 {
+	result := make([]uint8, 0, framebufferSizeBytes)
+	for _, scanLine := range e.vm.VideoMemory {
+		for x := 0; x < chip8.ScreenWidth; x++ {
+			result = append(result, uint8(scanLine>>uint(chip8.ScreenWidth-1-x))&1)
+		}
+	}
+	return result
+}
+
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
+
+func register(e *emulator) {
+	api := js.Global().Get("Object").New()
+
+	// loadROM(bytes: Uint8Array): Error | null
+	api.Set("loadROM", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 {
+			return jsError(fmt.Errorf("loadROM: expected 1 argument, got %d", len(args)))
+		}
+		rom := make([]uint8, args[0].Get("length").Int())
+		js.CopyBytesToGo(rom, args[0])
+		if err := e.loadROM(rom); err != nil {
+			return jsError(err)
+		}
+		return nil
+	}))
+
+	// stepFrame(count?: number): Error | null
+	api.Set("stepFrame", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if e.vm == nil {
+			return jsError(fmt.Errorf("stepFrame: no ROM loaded"))
+		}
+		count := 1
+		if len(args) > 0 {
+			count = args[0].Int()
+		}
+		for i := 0; i < count; i++ {
+			if err := e.stepFrame(); err != nil {
+				return jsError(err)
+			}
+		}
+		return nil
+	}))
+
+	// setKeys(keys: ArrayLike<boolean>) where keys[0x0 - 0xF] are the CHIP-8 keys
+	api.Set("setKeys", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if e.vm == nil || len(args) != 1 {
+			return nil
+		}
+		for key := uint8(0); key <= 0xF; key++ {
+			e.setKey(key, args[0].Index(int(key)).Truthy())
+		}
+		return nil
+	}))
+
+	// readFramebuffer(): Uint8Array of 64x32 pixels, 0 or 1, row by row
+	api.Set("readFramebuffer", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		result := js.Global().Get("Uint8Array").New(framebufferSizeBytes)
+		if e.vm != nil {
+			js.CopyBytesToJS(result, e.framebuffer())
+		}
+		return result
+	}))
+
+	// isSoundOn(): boolean
+	api.Set("isSoundOn", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return e.vm != nil && e.vm.ST > 0
+	}))
+
+	js.Global().Set("chip8", api)
+}
+
+func main() {
+	register(&emulator{
+		cpuFrequencyHz:   defaultCPUHz,
+		timerFrequencyHz: defaultTimerHz,
+	})
+	// keep the Go runtime alive to serve calls from JavaScript
+	select {}
+}
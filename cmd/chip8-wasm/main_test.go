@@ -0,0 +1,89 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+	"testing"
+)
+
+func newTestAPI(t *testing.T, rom []uint8) js.Value {
+	register(&emulator{cpuFrequencyHz: 600, timerFrequencyHz: 60})
+	api := js.Global().Get("chip8")
+	bytes := js.Global().Get("Uint8Array").New(len(rom))
+	js.CopyBytesToJS(bytes, rom)
+	if err := api.Call("loadROM", bytes); !err.IsNull() {
+		t.Fatalf("loadROM(): %s", err.Get("message").String())
+	}
+	return api
+}
+
+func TestLoadROMTooLarge(t *testing.T) {
+	register(&emulator{cpuFrequencyHz: 600, timerFrequencyHz: 60})
+	api := js.Global().Get("chip8")
+	if err := api.Call("loadROM", js.Global().Get("Uint8Array").New(4096)); err.IsNull() {
+		t.Error("loadROM(): Expected error for oversized ROM")
+	}
+}
+
+func TestStepFrameBeforeLoad(t *testing.T) {
+	register(&emulator{cpuFrequencyHz: 600, timerFrequencyHz: 60})
+	if err := js.Global().Get("chip8").Call("stepFrame"); err.IsNull() {
+		t.Error("stepFrame(): Expected error without a ROM")
+	}
+}
+
+func TestStepFrameError(t *testing.T) {
+	api := newTestAPI(t, []uint8{0x00, 0xEE}) // RET with an empty stack
+	for i := 0; i < 2; i++ {
+		if err := api.Call("stepFrame"); err.IsNull() {
+			t.Errorf("stepFrame() %d: Expected the VM error", i)
+		}
+	}
+}
+
+func TestKeysAndFramebuffer(t *testing.T) {
+	api := newTestAPI(t, []uint8{
+		0xF0, 0x0A, // LD V0, K
+		0xF0, 0x29, // LD F, V0
+		0xD1, 0x15, // DRW V1, V1, 5
+		0x12, 0x06, // JP 0x206
+	})
+	api.Call("stepFrame")
+	keys := js.Global().Get("Array").New(16)
+	for i := 0; i < 16; i++ {
+		keys.SetIndex(i, i == 0x1)
+	}
+	api.Call("setKeys", keys)
+	api.Call("stepFrame", 2)
+
+	framebuffer := api.Call("readFramebuffer")
+	if n := framebuffer.Get("length").Int(); n != 64*32 {
+		t.Fatalf("readFramebuffer(): Expected length %d, Actual %d", 64*32, n)
+	}
+	// the "1" digit sprite is 0x20, 0x60, 0x20, 0x20, 0x70
+	for y, row := range []uint8{0x20, 0x60, 0x20, 0x20, 0x70} {
+		for x := 0; x < 8; x++ {
+			expected := int(row>>uint(7-x)) & 1
+			if actual := framebuffer.Index(y*64 + x).Int(); actual != expected {
+				t.Errorf("pixel (%d, %d): Expected %d, Actual %d", x, y, expected, actual)
+			}
+		}
+	}
+}
+
+func TestIsSoundOn(t *testing.T) {
+	api := newTestAPI(t, []uint8{
+		0x60, 0x02, // LD V0, 2
+		0xF0, 0x18, // LD ST, V0
+		0x12, 0x04, // JP 0x204
+	})
+	api.Call("stepFrame")
+	if !api.Call("isSoundOn").Bool() {
+		t.Error("isSoundOn(): Expected true while ST > 0")
+	}
+	api.Call("stepFrame", 3)
+	if api.Call("isSoundOn").Bool() {
+		t.Error("isSoundOn(): Expected false once ST has run out")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"time"
+
+	"github.com/odsod/chip8/ui/web"
+)
+
+func main() {
+	romFile := flag.String("rom", "roms/TETRIS", "The ROM to load")
+	addr := flag.String("addr", ":8080", "The HTTP address to listen on")
+	cpuFrequencyHz := flag.Int("cpuFrequency", 500, "The CPU frequency (Hz)")
+	timerFrequencyHz := flag.Int("timerFrequency", 60, "The timer frequency (Hz)")
+	frameRateHz := flag.Int("frameRate", 60, "The frame rate (Hz)")
+	flag.Parse()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	ui := web.NewUI(web.Options{
+		RomFile:          *romFile,
+		Addr:             *addr,
+		CPUFrequencyHz:   *cpuFrequencyHz,
+		TimerFrequencyHz: *timerFrequencyHz,
+		FrameRateHz:      *frameRateHz,
+	})
+
+	ui.Run()
+}
@@ -2,24 +2,84 @@ package main
 
 import (
 	"flag"
+	"log"
 	"math/rand"
 	"time"
 
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/profile"
+	"github.com/odsod/chip8/trace"
+	"github.com/odsod/chip8/ui/settings"
 	"github.com/odsod/chip8/ui/terminal"
 )
 
 func main() {
+	// the saved settings are the defaults of the flags
+	settingsPath := settings.DefaultPath()
+	saved, err := settings.Load(settingsPath, settings.Default)
+	if err != nil {
+		log.Printf("Ignoring the settings in %s: %v", settingsPath, err)
+	}
+	if _, ok := terminal.KeyboardLayouts[saved.KeyboardLayout]; !ok {
+		saved.KeyboardLayout = settings.Default.KeyboardLayout
+	}
+
 	romFile := flag.String("rom", "roms/TETRIS", "The ROM to load")
-	keyboardLayout := flag.String("keyboard", "qwer", "They keyboard layout")
-	cpuFrequencyHz := flag.Int("cpuFrequency", 500, "The CPU frequency (Hz)")
-	timerFrequencyHz := flag.Int("timerFrequency", 60, "The timer frequency (Hz)")
+	keyboardLayout := flag.String("keyboard", saved.KeyboardLayout, "The keyboard layout: qwer or dvorak")
+	cpuFrequencyHz := flag.Int("cpuFrequency", saved.CPUFrequencyHz, "The CPU frequency (Hz)")
+	timerFrequencyHz := flag.Int("timerFrequency", saved.TimerFrequencyHz, "The timer frequency (Hz)")
 	frameRateHz := flag.Int("frameRate", 60, "The frame rate (Hz)")
 	emulatorFrequencyHz := flag.Int("emulatorFrequency", 100, "The emulator frequency (Hz)")
 	keyPressDurationMs := flag.Int("keyPressDuration", 100, "The key press duration (ms)")
+	vipTiming := flag.Bool("vipTiming", saved.VIPTiming, "Use COSMAC VIP instruction timing instead of the CPU and timer frequencies")
+	displayWait := flag.Bool("displayWait", saved.DisplayWait, "Make DRW wait for the next timer tick")
+	renderMode := flag.String("render", "auto", "The render mode: auto, block, half, quadrant, braille, sixel or kitty")
+	graphicsScale := flag.Int("graphicsScale", 8, "The pixel size in the sixel and kitty render modes")
+	themeName := flag.String("theme", saved.Theme, "The color theme: white, paper, green, amber, lcd or octo")
+	foreground := flag.String("fg", "", "The foreground color, e.g. #33FF66, overriding the theme")
+	background := flag.String("bg", "", "The background color, e.g. #051408, overriding the theme")
+	colorMode := flag.String("colors", "auto", "The color mode: auto, 16, 256 or truecolor")
+	pixelFadeTimeMs := flag.Int("pixelFadeTime", saved.PixelFadeTimeMs, "The pixel fade time (ms)")
+	sound := flag.Bool("sound", saved.Sound, "Ring the terminal bell for sounds")
+	cheatFile := flag.String("cheats", "", "A cheat file, with cheats toggled by F1 - F9")
+	traceFlags := trace.RegisterFlags(flag.CommandLine)
+	profileFlags := profile.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	rand.Seed(time.Now().UTC().UnixNano())
 
+	mode, err := terminal.ParseRenderMode(*renderMode)
+	if err != nil {
+		panic(err)
+	}
+
+	theme, err := terminal.ParseTheme(*themeName, *foreground, *background)
+	if err != nil {
+		panic(err)
+	}
+
+	colors, err := terminal.ParseColorMode(*colorMode)
+	if err != nil {
+		panic(err)
+	}
+
+	cheats, err := chip8.LoadCheats(*cheatFile)
+	if err != nil {
+		panic(err)
+	}
+
+	tracer, err := traceFlags.Open()
+	if err != nil {
+		panic(err)
+	}
+	defer traceFlags.Close()
+
+	profiler, err := profileFlags.Open(*romFile)
+	if err != nil {
+		panic(err)
+	}
+	defer profileFlags.Close()
+
 	ui := terminal.NewUI(terminal.Conf{
 		RomFile:             *romFile,
 		KeyboardLayout:      *keyboardLayout,
@@ -28,6 +88,18 @@ func main() {
 		FrameRateHz:         *frameRateHz,
 		EmulatorFrequencyHz: *emulatorFrequencyHz,
 		KeyPressDuration:    time.Duration(*keyPressDurationMs) * time.Millisecond,
+		Tracer:              trace.Tee(tracer, profiler),
+		VIPTiming:           *vipTiming,
+		DisplayWait:         *displayWait,
+		RenderMode:          mode,
+		GraphicsScale:       *graphicsScale,
+		Theme:               theme,
+		ColorMode:           colors,
+		PixelFadeTime:       time.Duration(*pixelFadeTimeMs) * time.Millisecond,
+		ThemeName:           *themeName,
+		Sound:               *sound,
+		SettingsPath:        settingsPath,
+		Cheats:              cheats,
 	})
 
 	ui.Run()
@@ -0,0 +1,196 @@
+/*
+Package env provides a Gym-style reinforcement learning environment around the
+CHIP-8 VM.
+
+Each Env owns its own VM and random source and shares no state with other
+envs, so any number of them can be stepped from separate goroutines. See Vec
+for stepping a batch of envs in parallel.
+
+A VM error, such as a stack overflow in the ROM, ends the episode and is
+returned by Reset or Step as a *chip8.Error instead of panicking.
+*/
+package env
+
+import (
+	"math/rand"
+
+	"github.com/odsod/chip8"
+)
+
+const frameRateHz = 60
+
+// Action is a set of CHIP-8 keys held down during a step, with bit k set for
+// key k (0x0 - 0xF)
+type Action uint16
+
+// Keys returns an action holding down the given keys
+func Keys(keys ...uint8) Action {
+	var a Action
+	for _, key := range keys {
+		a |= 1 << key
+	}
+	return a
+}
+
+func (a Action) IsDown(key uint8) bool {
+	return a&(1<<key) != 0
+}
+
+// Observation is a snapshot of the VM's VideoMemory
+type Observation [chip8.ScreenHeight]uint64
+
+func (o Observation) Pixel(x, y int) bool {
+	return o[y]&(0x8000000000000000>>uint(x)) != 0
+}
+
+// Pixels returns one byte per pixel, 0 or 1, row by row
+func (o Observation) Pixels() []uint8 {
+	result := make([]uint8, 0, chip8.ScreenWidth*chip8.ScreenHeight)
+	for y := 0; y < chip8.ScreenHeight; y++ {
+		for x := 0; x < chip8.ScreenWidth; x++ {
+			if o.Pixel(x, y) {
+				result = append(result, 1)
+			} else {
+				result = append(result, 0)
+			}
+		}
+	}
+	return result
+}
+
+type Options struct {
+	// FrameSkip is the number of 60 Hz frames each Step repeats its action for
+	FrameSkip int
+
+	// CPUFrequencyHz is the number of VM steps per emulated second
+	CPUFrequencyHz int
+
+	// TimerFrequencyHz is the number of timer ticks per emulated second
+	TimerFrequencyHz int
+
+	// MaxSteps ends an episode after this many calls to Step, 0 means no limit
+	MaxSteps int
+
+	// MaxPool makes observations the union of the last two frames, hiding
+	// sprite flicker from the agent
+	MaxPool bool
+}
+
+var DefaultOptions = Options{
+	FrameSkip:        4,
+	CPUFrequencyHz:   500,
+	TimerFrequencyHz: 60,
+}
+
+type Env struct {
+	rom   []uint8
+	game  Game
+	opts  Options
+	vm    *chip8.VM
+	score int
+	steps int
+	// frames, cpuCycles and timerCycles count emulated time since Reset
+	frames      int
+	cpuCycles   int
+	timerCycles int
+}
+
+func New(rom []uint8, game Game, opts Options) *Env {
+	if opts.FrameSkip < 1 {
+		opts.FrameSkip = 1
+	}
+	return &Env{rom: rom, game: game, opts: opts}
+}
+
+// VM returns the underlying VM, for inspection
+func (e *Env) VM() *chip8.VM {
+	return e.vm
+}
+
+// Actions returns the game's set of meaningful actions
+func (e *Env) Actions() []Action {
+	return e.game.Actions
+}
+
+type seededRandom struct {
+	rand *rand.Rand
+}
+
+func (r seededRandom) Next() uint8 {
+	return uint8(r.rand.Uint32())
+}
+
+// Reset starts a new episode from a fresh VM with a deterministic random
+// source, and runs the game's reset frames
+func (e *Env) Reset(seed int64) (Observation, error) {
+	vm, err := chip8.TryNew(e.rom)
+	if err != nil {
+		return Observation{}, err
+	}
+	e.vm = vm
+	e.vm.SetRandom(seededRandom{rand.New(rand.NewSource(seed))})
+	e.steps, e.frames, e.cpuCycles, e.timerCycles = 0, 0, 0, 0
+	for i := 0; i < e.game.ResetFrames; i++ {
+		if err := e.runFrame(); err != nil {
+			return Observation(e.vm.VideoMemory), err
+		}
+	}
+	e.score = e.game.Score(e.vm)
+	return Observation(e.vm.VideoMemory), nil
+}
+
+func (e *Env) setKeys(action Action) {
+	for key := uint8(0); key <= 0xF; key++ {
+		switch down := action.IsDown(key); {
+		case down && !e.vm.Keys[key]:
+			e.vm.SetKeyDown(key)
+		case !down && e.vm.Keys[key]:
+			e.vm.SetKeyUp(key)
+		}
+	}
+}
+
+func (e *Env) runFrame() error {
+	e.frames++
+	for ; e.timerCycles < e.frames*e.opts.TimerFrequencyHz/frameRateHz; e.timerCycles++ {
+		e.vm.TickTimers()
+	}
+	for ; e.cpuCycles < e.frames*e.opts.CPUFrequencyHz/frameRateHz; e.cpuCycles++ {
+		if err := e.vm.TryStep(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Step holds down the keys of the action for FrameSkip frames and returns the
+// resulting observation, the change in score and whether the episode is over.
+// A VM error ends the episode and is returned.
+func (e *Env) Step(action Action) (observation Observation, reward float64, done bool, err error) {
+	e.setKeys(action)
+	var previous [chip8.ScreenHeight]uint64
+	for i := 0; i < e.opts.FrameSkip; i++ {
+		previous = e.vm.VideoMemory
+		if err = e.runFrame(); err != nil {
+			done = true
+			break
+		}
+		if done = e.game.Done(e.vm); done {
+			break
+		}
+	}
+	observation = Observation(e.vm.VideoMemory)
+	if e.opts.MaxPool {
+		for y := range observation {
+			observation[y] |= previous[y]
+		}
+	}
+	score := e.game.Score(e.vm)
+	reward = float64(score - e.score)
+	e.score = score
+	e.steps++
+	if e.opts.MaxSteps > 0 && e.steps >= e.opts.MaxSteps {
+		done = true
+	}
+	return
+}
@@ -0,0 +1,183 @@
+package env
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+func readROM(t *testing.T, name string) []uint8 {
+	rom, err := ioutil.ReadFile("../roms/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rom
+}
+
+func TestKeys(t *testing.T) {
+	a := Keys(0x0, 0x4, 0xF)
+	for key := uint8(0); key <= 0xF; key++ {
+		expected := key == 0x0 || key == 0x4 || key == 0xF
+		if a.IsDown(key) != expected {
+			t.Errorf("(%#x).IsDown(%#x): Expected %v", a, key, expected)
+		}
+	}
+}
+
+func TestHalted(t *testing.T) {
+	vm := chip8.New([]uint8{
+		0x12, 0x00, // 0x200 JP 0x200
+	})
+	if !Halted(vm) {
+		t.Error("Expected halted at JP 0x200")
+	}
+	vm.PC = 0xFFF
+	if Halted(vm) {
+		t.Error("Expected not halted at 0xFFF")
+	}
+}
+
+func TestObservationPixels(t *testing.T) {
+	var o Observation
+	o[1] = 0x8000000000000001
+	pixels := o.Pixels()
+	if len(pixels) != 64*32 {
+		t.Fatalf("Expected %d pixels, Actual %d", 64*32, len(pixels))
+	}
+	for i, p := range pixels {
+		expected := uint8(0)
+		if i == 64 || i == 127 {
+			expected = 1
+		}
+		if p != expected {
+			t.Errorf("pixels[%d]: Expected %d, Actual %d", i, expected, p)
+		}
+	}
+}
+
+func TestBrixEpisode(t *testing.T) {
+	e := New(readROM(t, "BRIX"), Brix, DefaultOptions)
+	if _, err := e.Reset(1); err != nil {
+		t.Fatal(err)
+	}
+	if e.VM().V[0xE] != 5 {
+		t.Fatalf("Expected 5 lives after reset, Actual %d", e.VM().V[0xE])
+	}
+	total := 0.0
+	for i := 0; i < 10000; i++ {
+		// always move right, so the paddle misses most balls
+		_, reward, done, err := e.Step(Keys(0x6))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if reward < 0 {
+			t.Fatalf("step %d: Unexpected negative reward %f", i, reward)
+		}
+		total += reward
+		if done {
+			if total != float64(Brix.Score(e.VM())) {
+				t.Errorf("Expected total reward %f to equal final score %d", total, Brix.Score(e.VM()))
+			}
+			return
+		}
+	}
+	t.Error("Expected the episode to end")
+}
+
+func TestDeterministicReset(t *testing.T) {
+	rom := readROM(t, "BRIX")
+	a := New(rom, Brix, DefaultOptions)
+	b := New(rom, Brix, DefaultOptions)
+	a.Reset(42)
+	b.Reset(42)
+	for i := 0; i < 500; i++ {
+		action := Brix.Actions[i%len(Brix.Actions)]
+		oa, ra, da, erra := a.Step(action)
+		ob, rb, db, errb := b.Step(action)
+		if erra != nil || errb != nil {
+			t.Fatalf("step %d: %v, %v", i, erra, errb)
+		}
+		if oa != ob || ra != rb || da != db {
+			t.Fatalf("step %d: Expected identical envs to agree", i)
+		}
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	opts := DefaultOptions
+	opts.MaxSteps = 3
+	e := New(readROM(t, "BRIX"), Brix, opts)
+	e.Reset(0)
+	for i := 1; i <= 3; i++ {
+		if _, _, done, _ := e.Step(0); done != (i == 3) {
+			t.Errorf("step %d: Expected done %v", i, i == 3)
+		}
+	}
+}
+
+func TestVec(t *testing.T) {
+	rom := readROM(t, "BRIX")
+	v := NewVec(64, rom, Brix, DefaultOptions)
+	observations, err := v.Reset(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := New(rom, Brix, DefaultOptions)
+	for i, observation := range observations {
+		if expected, _ := reference.Reset(100 + int64(i)); observation != expected {
+			t.Errorf("env %d: Expected Reset to match a sequential env", i)
+		}
+	}
+	actions := make([]Action, len(v.Envs))
+	for step := 0; step < 20; step++ {
+		for i := range actions {
+			actions[i] = Brix.Actions[(i+step)%len(Brix.Actions)]
+		}
+		_, rewards, dones, errs := v.Step(actions)
+		if len(rewards) != len(v.Envs) || len(dones) != len(v.Envs) || len(errs) != len(v.Envs) {
+			t.Fatal("Expected one result per env")
+		}
+	}
+}
+
+var testGame = Game{
+	Actions: []Action{0},
+	Score:   Register(0),
+	Done:    Halted,
+}
+
+func TestVMError(t *testing.T) {
+	e := New([]uint8{
+		0x22, 0x00, // 0x200 CALL 0x200
+	}, testGame, DefaultOptions)
+	if _, err := e.Reset(0); err != nil {
+		t.Fatal(err)
+	}
+	_, _, done, err := e.Step(0)
+	if vmErr, ok := err.(*chip8.Error); !ok || vmErr.Reason != "Stack overflow" {
+		t.Errorf("Expected a stack overflow, Actual %v", err)
+	}
+	if !done {
+		t.Error("Expected the episode to end")
+	}
+
+	if _, err := New(make([]uint8, 0x1000), testGame, DefaultOptions).Reset(0); err == nil {
+		t.Error("Expected an error for an oversized ROM")
+	}
+}
+
+func TestVecVMError(t *testing.T) {
+	v := NewVec(2, []uint8{
+		0x00, 0xEE, // 0x200 RET
+	}, testGame, DefaultOptions)
+	if _, err := v.Reset(0); err != nil {
+		t.Fatal(err)
+	}
+	_, _, dones, errs := v.Step([]Action{0, 0})
+	for i := range v.Envs {
+		if !dones[i] || errs[i] == nil {
+			t.Errorf("env %d: Expected done with an error, Actual %v, %v", i, dones[i], errs[i])
+		}
+	}
+}
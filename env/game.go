@@ -0,0 +1,65 @@
+package env
+
+import "github.com/odsod/chip8"
+
+// Game describes how to score and end an episode of a particular ROM
+type Game struct {
+	// Actions are the meaningful actions for the game, the first being no-op
+	Actions []Action
+
+	// ResetFrames are run by Reset to get past title screens
+	ResetFrames int
+
+	// Score reads the current score from the VM
+	Score func(vm *chip8.VM) int
+
+	// Done reports whether the game is over
+	Done func(vm *chip8.VM) bool
+}
+
+// ByteAt reads a single byte from memory
+func ByteAt(address uint16) func(vm *chip8.VM) int {
+	return func(vm *chip8.VM) int {
+		return int(vm.Memory[address])
+	}
+}
+
+// BCDAt reads a three digit number stored by LD B, Vx
+func BCDAt(address uint16) func(vm *chip8.VM) int {
+	return func(vm *chip8.VM) int {
+		return 100*int(vm.Memory[address]) + 10*int(vm.Memory[address+1]) + int(vm.Memory[address+2])
+	}
+}
+
+// Register reads a general purpose register, for games that never store a
+// value in memory
+func Register(x uint8) func(vm *chip8.VM) int {
+	return func(vm *chip8.VM) int {
+		return int(vm.V[x])
+	}
+}
+
+// Halted reports whether the VM is stuck in a jump to itself, which is how
+// most games stop at game over. A PC at the last byte of memory is not a jump.
+func Halted(vm *chip8.VM) bool {
+	if int(vm.PC)+1 >= len(vm.Memory) {
+		return false
+	}
+	return vm.Memory[vm.PC] == 0x10|uint8(vm.PC>>8) && vm.Memory[vm.PC+1] == uint8(vm.PC)
+}
+
+/*
+Brix is the BRIX ROM in roms/.
+
+The score is redrawn from a BCD copy at 0x314, the remaining lives are kept in
+VE, and the game halts when lives run out or all 96 bricks are cleared. The
+first second is spent drawing the bricks.
+*/
+var Brix = Game{
+	Actions:     []Action{0, Keys(0x4), Keys(0x6)},
+	ResetFrames: 60,
+	Score:       BCDAt(0x314),
+	Done: func(vm *chip8.VM) bool {
+		return vm.V[0xE] == 0 || Halted(vm)
+	},
+}
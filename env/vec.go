@@ -0,0 +1,69 @@
+package env
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Vec steps a batch of envs in parallel, one goroutine per CPU
+type Vec struct {
+	Envs []*Env
+}
+
+func NewVec(n int, rom []uint8, game Game, opts Options) *Vec {
+	v := &Vec{Envs: make([]*Env, n)}
+	for i := range v.Envs {
+		v.Envs[i] = New(rom, game, opts)
+	}
+	return v
+}
+
+func (v *Vec) parallel(f func(i int)) {
+	var wg sync.WaitGroup
+	indices := make(chan int)
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				f(i)
+			}
+		}()
+	}
+	for i := range v.Envs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// Reset resets env i with seed + i, returning the error of the first env
+// that failed
+func (v *Vec) Reset(seed int64) ([]Observation, error) {
+	observations := make([]Observation, len(v.Envs))
+	errs := make([]error, len(v.Envs))
+	v.parallel(func(i int) {
+		observations[i], errs[i] = v.Envs[i].Reset(seed + int64(i))
+	})
+	for i, err := range errs {
+		if err != nil {
+			return observations, fmt.Errorf("env %d: %w", i, err)
+		}
+	}
+	return observations, nil
+}
+
+// Step steps env i with actions[i]. Envs that are done are not reset
+// automatically. errs[i] is the VM error that ended the episode of env i, if
+// any.
+func (v *Vec) Step(actions []Action) (observations []Observation, rewards []float64, dones []bool, errs []error) {
+	observations = make([]Observation, len(v.Envs))
+	rewards = make([]float64, len(v.Envs))
+	dones = make([]bool, len(v.Envs))
+	errs = make([]error, len(v.Envs))
+	v.parallel(func(i int) {
+		observations[i], rewards[i], dones[i], errs[i] = v.Envs[i].Step(actions[i])
+	})
+	return
+}
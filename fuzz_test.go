@@ -0,0 +1,204 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// referenceOps is a decoder independent of the instruction table, matching ops against
+// patterns where hex digits must match and letters are operands, which are the
+// fields of the decoded op
+var referenceOps = []struct {
+	pattern  string
+	format   string
+	operands string
+	op       Op
+}{
+	{"00E0", "CLS", "", CLS{}},
+	{"00EE", "RET", "", RET{}},
+	{"1nnn", "JP 0x%03X", "nnn", JP{}},
+	{"2nnn", "CALL 0x%03X", "nnn", CALL{}},
+	{"3xkk", "SE V%X, 0x%02X", "x kk", SEVx{}},
+	{"4xkk", "SNE V%X, 0x%02X", "x kk", SNEVx{}},
+	{"5xy0", "SE V%X, V%X", "x y", SEVxVy{}},
+	{"6xkk", "LD V%X, 0x%02X", "x kk", LDVx{}},
+	{"7xkk", "ADD V%X, 0x%02X", "x kk", ADDVx{}},
+	{"8xy0", "LD V%X, V%X", "x y", LDVxVy{}},
+	{"8xy1", "OR V%X, V%X", "x y", ORVxVy{}},
+	{"8xy2", "AND V%X, V%X", "x y", ANDVxVy{}},
+	{"8xy3", "XOR V%X, V%X", "x y", XORVxVy{}},
+	{"8xy4", "ADD V%X, V%X", "x y", ADDVxVy{}},
+	{"8xy5", "SUB V%X, V%X", "x y", SUBVxVy{}},
+	{"8xy6", "SHR V%X", "x", SHRVx{}},
+	{"8xy7", "SUBN V%X, V%X", "x y", SUBNVxVy{}},
+	{"8xyE", "SHL V%X", "x", SHLVx{}},
+	{"9xy0", "SNE V%X, V%X", "x y", SNEVxVy{}},
+	{"Annn", "LD I, 0x%03X", "nnn", LDI{}},
+	{"Bnnn", "JP V0, 0x%03X", "nnn", JPV0{}},
+	{"Cxkk", "RND V%X, 0x%02X", "x kk", RNDVx{}},
+	{"Dxyn", "DRW V%X, V%X, %d", "x y n", DRWVxVy{}},
+	{"Ex9E", "SKP V%X", "x", SKPVx{}},
+	{"ExA1", "SKNP V%X", "x", SKNPVx{}},
+	{"Fx07", "LD V%X, DT", "x", LDVxDT{}},
+	{"Fx0A", "LD V%X, K", "x", LDVxK{}},
+	{"Fx15", "LD DT, V%X", "x", LDDTVx{}},
+	{"Fx18", "LD ST, V%X", "x", LDSTVx{}},
+	{"Fx1E", "ADD I, V%X", "x", ADDIVx{}},
+	{"Fx29", "LD F, V%X", "x", LDFVx{}},
+	{"Fx33", "LD B, V%X", "x", LDBVx{}},
+	{"Fx55", "LD [I], V%X", "x", LDIVx{}},
+	{"Fx65", "LD V%X, [I]", "x", LDVxI{}},
+}
+
+// referenceDecode returns the assembly of an op, and the decoded op formatted
+// like %T%+v
+func referenceDecode(op uint16) (asm string, decoded string, ok bool) {
+	for _, ref := range referenceOps {
+		matches := true
+		for i, c := range ref.pattern {
+			nibble := op >> uint(12-4*i) & 0xF
+			if digit := strings.IndexRune("0123456789ABCDEF", c); digit != -1 && uint16(digit) != nibble {
+				matches = false
+			}
+		}
+		if !matches {
+			continue
+		}
+		var operands []interface{}
+		var fields []string
+		for _, name := range strings.Fields(ref.operands) {
+			var value uint16
+			switch name {
+			case "nnn":
+				value = op & 0xFFF
+			case "kk":
+				value = op & 0xFF
+			case "x":
+				value = op >> 8 & 0xF
+			case "y":
+				value = op >> 4 & 0xF
+			case "n":
+				value = op & 0xF
+			}
+			operands = append(operands, value)
+			fields = append(fields, fmt.Sprintf("%s:%d", name, value))
+		}
+		return fmt.Sprintf(ref.format, operands...), fmt.Sprintf("%T{%s}", ref.op, strings.Join(fields, " ")), true
+	}
+	return "", "", false
+}
+
+func checkDecode(t *testing.T, op uint16) {
+	expected, expectedDecoded, expectedOK := referenceDecode(op)
+	decoded, ok := EncodedOp(op).tryDecode()
+	if ok != expectedOK {
+		t.Fatalf("(%#04x).tryDecode(): Expected ok %v, Actual %v", op, expectedOK, ok)
+	}
+	if actual := fmt.Sprintf("%T%+v", decoded, decoded); ok && actual != expectedDecoded {
+		t.Fatalf("(%#04x).tryDecode(): Expected %s, Actual %s", op, expectedDecoded, actual)
+	}
+	if actual := EncodedOp(op).Disassemble(); ok && actual != expected {
+		t.Fatalf("(%#04x).Disassemble(): Expected %q, Actual %q", op, expected, actual)
+	}
+}
+
+func TestDecodeReference(t *testing.T) {
+	for op := 0; op <= 0xFFFF; op++ {
+		checkDecode(t, uint16(op))
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []uint16{0x00E0, 0x00EE, 0x0123, 0x8AB6, 0x8ABE, 0xD125, 0xE19E, 0xF265, 0xFFFF} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, op uint16) {
+		checkDecode(t, op)
+	})
+}
+
+// counterRandom is a deterministic Random for reproducible fuzzing
+type counterRandom struct {
+	n uint8
+}
+
+func (r *counterRandom) Next() uint8 {
+	r.n = r.n*37 + 11
+	return r.n
+}
+
+const (
+	fuzzSteps       = 2000
+	fuzzTimerPeriod = 8
+	fuzzKeyPeriod   = 16
+	fuzzKeyDownFlag = 0x80
+)
+
+func FuzzStep(f *testing.F) {
+	roms, _ := filepath.Glob("roms/*")
+	for _, name := range roms {
+		if rom, err := ioutil.ReadFile(name); err == nil {
+			f.Add(rom, []uint8{0x85, 0x05, 0x84, 0x04, 0x86}, "")
+		}
+	}
+	f.Add([]uint8{0x00, 0xEE}, []uint8{}, "")                                // RET on an empty stack
+	f.Add([]uint8{0x22, 0x00}, []uint8{}, "")                                // CALL itself until the stack overflows
+	f.Add([]uint8{0xAF, 0xFF, 0xD0, 0x1F}, []uint8{}, "")                    // DRW outside memory
+	f.Add([]uint8{0x1F, 0xFE}, []uint8{}, "")                                // JP to the end of memory
+	f.Add([]uint8{0x60, 0xFF, 0xE0, 0x9E, 0x12, 0x02}, []uint8{0x8F}, "")    // SKP with Vx > 0xF
+	f.Add([]uint8{0xF0, 0x0A, 0x12, 0x00}, []uint8{0x83, 0x03}, "")          // LD V0, K
+	f.Add([]uint8{0x00, 0xEE}, []uint8{}, "Deep stack: freeze SP=0x10")      // RET from a cheated stack pointer
+	f.Add([]uint8{0x00, 0xEE}, []uint8{}, "Bad stack: freeze SP=0x20")       // rejected stack pointer
+	f.Add([]uint8{0x22, 0x00}, []uint8{}, "Lives: patch VE=0x05 0xFFF=0xEE") // CALL with cheats
+	f.Fuzz(func(t *testing.T, rom []uint8, keys []uint8, cheatFile string) {
+		vm, err := TryNew(rom)
+		if err != nil {
+			if len(rom) <= len(VM{}.Memory)-romStartAddress {
+				t.Fatalf("TryNew(%d bytes): %v", len(rom), err)
+			}
+			return
+		}
+		vm.SetRandom(&counterRandom{})
+		// the cheats that parse are enabled, as by the frontends
+		if cheats, err := ParseCheats(strings.NewReader(cheatFile)); err == nil {
+			vm.SetCheats(cheats)
+			for i := range cheats {
+				vm.ToggleCheat(i)
+			}
+		}
+		for step := 0; step < fuzzSteps; step++ {
+			if len(keys) > 0 && step%fuzzKeyPeriod == 0 {
+				k := keys[step/fuzzKeyPeriod%len(keys)]
+				if k&fuzzKeyDownFlag != 0 {
+					vm.SetKeyDown(k & 0xF)
+				} else {
+					vm.SetKeyUp(k & 0xF)
+				}
+			}
+			if step%fuzzTimerPeriod == 0 {
+				vm.TickTimers()
+			}
+			before := *vm
+			if err := vm.TryStep(); err != nil {
+				var vmErr *Error
+				if !errors.As(err, &vmErr) {
+					t.Fatalf("TryStep(): Expected *Error, Actual %T", err)
+				}
+				if *vm != before {
+					t.Fatalf("TryStep(): Changed the VM and returned %v", err)
+				}
+				return
+			}
+			if int(vm.SP) > len(vm.Stack) {
+				t.Fatalf("SP out of range after step %d: %d", step, vm.SP)
+			}
+			if int(vm.PC) > len(vm.Memory) {
+				t.Fatalf("PC out of range after step %d: %#x", step, vm.PC)
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package gdbstub
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// interruptByte is sent by the client outside of packets to stop execution
+const interruptByte = 0x03
+
+// event is either a packet or an interrupt from the client
+type event struct {
+	packet    string
+	interrupt bool
+}
+
+// readEvents parses the client's byte stream into events until the stream
+// ends. Acknowledgements from the client are dropped, as packets are never
+// retransmitted.
+func readEvents(r io.Reader, ack func(), events chan<- event) error {
+	reader := bufio.NewReader(r)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case interruptByte:
+			events <- event{interrupt: true}
+		case '$':
+			data, err := reader.ReadBytes('#')
+			if err != nil {
+				return err
+			}
+			var checksum [2]byte
+			if _, err := io.ReadFull(reader, checksum[:]); err != nil {
+				return err
+			}
+			data = data[:len(data)-1]
+			if fmt.Sprintf("%02x", sum(data)) != string(bytes.ToLower(checksum[:])) {
+				return fmt.Errorf("bad checksum for packet %q", data)
+			}
+			ack()
+			events <- event{packet: string(unescape(data))}
+		}
+	}
+}
+
+func sum(data []byte) uint8 {
+	var s uint8
+	for _, b := range data {
+		s += b
+	}
+	return s
+}
+
+// unescape undoes the '}' escaping of binary data
+func unescape(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '}' && i+1 < len(data) {
+			i++
+			result = append(result, data[i]^0x20)
+		} else {
+			result = append(result, data[i])
+		}
+	}
+	return result
+}
+
+func escape(data string) []byte {
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '$', '#', '}', '*':
+			result = append(result, '}', c^0x20)
+		default:
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func writePacket(w io.Writer, data string) error {
+	escaped := escape(data)
+	_, err := fmt.Fprintf(w, "$%s#%02x", escaped, sum(escaped))
+	return err
+}
@@ -0,0 +1,540 @@
+/*
+Package gdbstub implements a GDB remote serial protocol server for the CHIP-8
+VM, for debugging ROMs with gdb or any other RSP client:
+
+	(gdb) target remote localhost:1234
+
+The registers are described to the client by a target description with the
+layout below, 16 bit registers being little-endian:
+
+	0-15  v0-vf  8 bits
+	16    i      16 bits
+	17    pc     16 bits
+	18    sp     8 bits
+	19    dt     8 bits
+	20    st     8 bits
+
+Memory is the VM's 4K address space. Software breakpoints (Z0/z0),
+single-step, continue and interrupting a running VM are supported. Timers
+tick at TimerFrequencyHz relative to CPUFrequencyHz VM steps, so emulated
+time is preserved while running unthrottled.
+
+Memory can be viewed as sprites with monitor commands, highlighting the
+sprites recorded by Options.Sprites:
+
+	(gdb) monitor sprite 0x2ea 6
+	(gdb) monitor sprites
+*/
+package gdbstub
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/sprites"
+)
+
+// Signals reported in stop replies
+const (
+	sigint  = 2
+	sigill  = 4
+	sigtrap = 5
+)
+
+const memorySize = len(chip8.VM{}.Memory)
+
+// interruptCheckInterval is the number of steps between checks for an
+// interrupt from the client while continuing
+const interruptCheckInterval = 1024
+
+type register struct {
+	name    string
+	bitsize int
+	typ     string
+}
+
+var registers = func() []register {
+	var result []register
+	for i := 0; i < 16; i++ {
+		result = append(result, register{fmt.Sprintf("v%x", i), 8, "uint8"})
+	}
+	return append(result,
+		register{"i", 16, "data_ptr"},
+		register{"pc", 16, "code_ptr"},
+		register{"sp", 8, "uint8"},
+		register{"dt", 8, "uint8"},
+		register{"st", 8, "uint8"})
+}()
+
+var targetXML = func() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?>` + "\n")
+	b.WriteString(`<!DOCTYPE target SYSTEM "gdb-target.dtd">` + "\n")
+	b.WriteString(`<target version="1.0">` + "\n")
+	b.WriteString(`<feature name="org.odsod.chip8.core">` + "\n")
+	for i, r := range registers {
+		fmt.Fprintf(&b, `<reg name="%s" bitsize="%d" type="%s" regnum="%d"/>`+"\n", r.name, r.bitsize, r.typ, i)
+	}
+	b.WriteString("</feature>\n</target>\n")
+	return b.String()
+}()
+
+type Options struct {
+	CPUFrequencyHz   int
+	TimerFrequencyHz int
+	// Sprites is an optional recorder of the sprites drawn by the VM, which
+	// must have it as tracer
+	Sprites *sprites.Recorder
+}
+
+type Stub struct {
+	mu          sync.Mutex
+	vm          *chip8.VM
+	opts        Options
+	breakpoints map[uint16]bool
+	cycles      int
+	noAck       atomic.Bool
+	// pending are the packets received while continuing, handled after the
+	// stop reply
+	pending []string
+}
+
+func New(vm *chip8.VM, opts Options) *Stub {
+	return &Stub{vm: vm, opts: opts, breakpoints: make(map[uint16]bool)}
+}
+
+// Serve debugs the VM for each connection accepted from l, one at a time,
+// until l is closed
+func (s *Stub) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		log.Printf("%s: attached", conn.RemoteAddr())
+		if err := s.ServeConn(conn); err != nil && err != io.EOF {
+			log.Printf("%s: %v", conn.RemoteAddr(), err)
+		}
+		conn.Close()
+		log.Printf("%s: detached", conn.RemoteAddr())
+	}
+}
+
+// ServeConn answers requests on a single connection until the client
+// detaches, kills the session or disconnects
+func (s *Stub) ServeConn(conn io.ReadWriter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noAck.Store(false)
+	s.pending = nil
+	events := make(chan event)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- readEvents(conn, func() {
+			if !s.noAck.Load() {
+				conn.Write([]byte{'+'})
+			}
+		}, events)
+		close(events)
+	}()
+	next := func() (event, bool) {
+		if len(s.pending) > 0 {
+			packet := s.pending[0]
+			s.pending = s.pending[1:]
+			return event{packet: packet}, true
+		}
+		ev, ok := <-events
+		return ev, ok
+	}
+	for ev, ok := next(); ok; ev, ok = next() {
+		if ev.interrupt {
+			// not running, nothing to interrupt
+			continue
+		}
+		if ev.packet == "k" {
+			// kill expects no reply
+			return nil
+		}
+		reply, done := s.handle(ev.packet, events)
+		if err := writePacket(conn, reply); err != nil {
+			return err
+		}
+		if strings.HasPrefix(ev.packet, "QStartNoAckMode") {
+			s.noAck.Store(true)
+		}
+		if done {
+			return nil
+		}
+	}
+	return <-errs
+}
+
+func (s *Stub) handle(packet string, events <-chan event) (reply string, done bool) {
+	if packet == "" {
+		return "", false
+	}
+	args := packet[1:]
+	switch packet[0] {
+	case '?':
+		return stopReply(sigtrap), false
+	case 'g':
+		return s.readRegisters(), false
+	case 'G':
+		return s.writeRegisters(args), false
+	case 'p':
+		return s.readRegister(args), false
+	case 'P':
+		return s.writeRegister(args), false
+	case 'm':
+		return s.readMemory(args), false
+	case 'M':
+		return s.writeMemory(args), false
+	case 's':
+		if args != "" {
+			return "E01", false
+		}
+		return s.step(), false
+	case 'c':
+		if args != "" {
+			return "E01", false
+		}
+		return s.cont(events), false
+	case 'Z', 'z':
+		return s.breakpoint(packet[0] == 'Z', args), false
+	case 'H':
+		return "OK", false
+	case 'T':
+		return "OK", false
+	case 'D':
+		return "OK", true
+	case 'v':
+		if strings.HasPrefix(packet, "vCont?") {
+			return "vCont;c;s", false
+		}
+		if strings.HasPrefix(packet, "vCont;") {
+			switch packet[len("vCont;")] {
+			case 'c':
+				return s.cont(events), false
+			case 's':
+				return s.step(), false
+			}
+		}
+		return "", false
+	case 'q', 'Q':
+		return s.query(packet), false
+	}
+	return "", false
+}
+
+func (s *Stub) query(packet string) string {
+	switch {
+	case strings.HasPrefix(packet, "qSupported"):
+		return "PacketSize=4000;qXfer:features:read+;QStartNoAckMode+;vContSupported+"
+	case packet == "QStartNoAckMode":
+		return "OK"
+	case packet == "qAttached":
+		return "1"
+	case packet == "qC":
+		return "QC1"
+	case packet == "qfThreadInfo":
+		return "m1"
+	case packet == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(packet, "qRcmd,"):
+		command, err := hex.DecodeString(strings.TrimPrefix(packet, "qRcmd,"))
+		if err != nil {
+			return "E01"
+		}
+		return hex.EncodeToString([]byte(s.monitor(string(command))))
+	case strings.HasPrefix(packet, "qXfer:features:read:target.xml:"):
+		return readXfer(targetXML, strings.TrimPrefix(packet, "qXfer:features:read:target.xml:"))
+	}
+	return ""
+}
+
+// defaultSpriteRows is the number of rows shown by monitor sprite, the
+// largest sprite height
+const defaultSpriteRows = 15
+
+func (s *Stub) monitor(command string) string {
+	var highlights []sprites.Region
+	if s.opts.Sprites != nil {
+		highlights = s.opts.Sprites.Regions()
+	}
+	var b strings.Builder
+	fields := strings.Fields(command)
+	switch {
+	case len(fields) == 1 && fields[0] == "sprites":
+		if len(highlights) == 0 {
+			return "No sprites drawn\n"
+		}
+		for _, r := range highlights {
+			fmt.Fprintf(&b, "sprite_%03X:\n", r.Address)
+			_ = sprites.WriteText(&b, s.vm.Memory[:], int(r.Address), r.End(), highlights)
+		}
+	case (len(fields) == 2 || len(fields) == 3) && fields[0] == "sprite":
+		address, err := strconv.ParseUint(fields[1], 0, 16)
+		if err != nil {
+			return fmt.Sprintf("Invalid address: %s\n", fields[1])
+		}
+		rows := uint64(defaultSpriteRows)
+		if len(fields) == 3 {
+			if rows, err = strconv.ParseUint(fields[2], 0, 16); err != nil {
+				return fmt.Sprintf("Invalid number of rows: %s\n", fields[2])
+			}
+		}
+		_ = sprites.WriteText(&b, s.vm.Memory[:], int(address), int(address+rows), highlights)
+	default:
+		return "Commands: sprite ADDRESS [ROWS], sprites\n"
+	}
+	return b.String()
+}
+
+func readXfer(document, offsetLength string) string {
+	parts := strings.SplitN(offsetLength, ",", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	offset, err1 := strconv.ParseUint(parts[0], 16, 32)
+	length, err2 := strconv.ParseUint(parts[1], 16, 32)
+	if err1 != nil || err2 != nil {
+		return "E01"
+	}
+	if offset >= uint64(len(document)) {
+		return "l"
+	}
+	end := offset + length
+	if end >= uint64(len(document)) {
+		return "l" + document[offset:]
+	}
+	return "m" + document[offset:end]
+}
+
+func stopReply(signal int) string {
+	return fmt.Sprintf("S%02x", signal)
+}
+
+func (s *Stub) registerBytes(i int) []byte {
+	switch {
+	case i < 16:
+		return []byte{s.vm.V[i]}
+	case i == 16:
+		return []byte{uint8(s.vm.I), uint8(s.vm.I >> 8)}
+	case i == 17:
+		return []byte{uint8(s.vm.PC), uint8(s.vm.PC >> 8)}
+	case i == 18:
+		return []byte{s.vm.SP}
+	case i == 19:
+		return []byte{s.vm.DT}
+	default:
+		return []byte{s.vm.ST}
+	}
+}
+
+func (s *Stub) setRegisterBytes(i int, b []byte) {
+	switch {
+	case i < 16:
+		s.vm.V[i] = b[0]
+	case i == 16:
+		s.vm.I = uint16(b[0]) | uint16(b[1])<<8
+	case i == 17:
+		s.vm.PC = uint16(b[0]) | uint16(b[1])<<8
+	case i == 18:
+		s.vm.SP = b[0]
+	case i == 19:
+		s.vm.DT = b[0]
+	default:
+		s.vm.ST = b[0]
+	}
+}
+
+// checkRegisterBytes reports whether a register value is one the VM can
+// execute with, a PC within memory and an SP within the stack
+func (s *Stub) checkRegisterBytes(i int, b []byte) bool {
+	switch i {
+	case 17:
+		return int(uint16(b[0])|uint16(b[1])<<8) < memorySize-1
+	case 18:
+		return int(b[0]) <= len(s.vm.Stack)
+	}
+	return true
+}
+
+func (s *Stub) readRegisters() string {
+	var b []byte
+	for i := range registers {
+		b = append(b, s.registerBytes(i)...)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (s *Stub) writeRegisters(args string) string {
+	b, err := hex.DecodeString(args)
+	if err != nil {
+		return "E01"
+	}
+	values := make([][]byte, len(registers))
+	for i, r := range registers {
+		n := r.bitsize / 8
+		if len(b) < n || !s.checkRegisterBytes(i, b[:n]) {
+			return "E01"
+		}
+		values[i], b = b[:n], b[n:]
+	}
+	for i, value := range values {
+		s.setRegisterBytes(i, value)
+	}
+	return "OK"
+}
+
+func parseRegisterNumber(arg string) (int, bool) {
+	i, err := strconv.ParseUint(arg, 16, 8)
+	if err != nil || int(i) >= len(registers) {
+		return 0, false
+	}
+	return int(i), true
+}
+
+func (s *Stub) readRegister(args string) string {
+	i, ok := parseRegisterNumber(args)
+	if !ok {
+		return "E01"
+	}
+	return hex.EncodeToString(s.registerBytes(i))
+}
+
+func (s *Stub) writeRegister(args string) string {
+	parts := strings.SplitN(args, "=", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	i, ok := parseRegisterNumber(parts[0])
+	if !ok {
+		return "E01"
+	}
+	b, err := hex.DecodeString(parts[1])
+	if err != nil || len(b) != registers[i].bitsize/8 || !s.checkRegisterBytes(i, b) {
+		return "E01"
+	}
+	s.setRegisterBytes(i, b)
+	return "OK"
+}
+
+// parseRange parses "addr,length" and checks it is within memory
+func parseRange(args string) (address, length int, ok bool) {
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	a, err1 := strconv.ParseUint(parts[0], 16, 32)
+	l, err2 := strconv.ParseUint(parts[1], 16, 32)
+	if err1 != nil || err2 != nil || a+l > uint64(memorySize) {
+		return 0, 0, false
+	}
+	return int(a), int(l), true
+}
+
+func (s *Stub) readMemory(args string) string {
+	address, length, ok := parseRange(args)
+	if !ok {
+		return "E01"
+	}
+	return hex.EncodeToString(s.vm.Memory[address : address+length])
+}
+
+func (s *Stub) writeMemory(args string) string {
+	parts := strings.SplitN(args, ":", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	address, length, ok := parseRange(parts[0])
+	if !ok {
+		return "E01"
+	}
+	b, err := hex.DecodeString(parts[1])
+	if err != nil || len(b) != length {
+		return "E01"
+	}
+	copy(s.vm.Memory[address:], b)
+	return "OK"
+}
+
+func (s *Stub) breakpoint(insert bool, args string) string {
+	// type,addr,kind
+	parts := strings.Split(args, ",")
+	if len(parts) < 2 {
+		return "E01"
+	}
+	if parts[0] != "0" {
+		// only software breakpoints are supported
+		return ""
+	}
+	address, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil || address >= uint64(memorySize) {
+		return "E01"
+	}
+	if insert {
+		s.breakpoints[uint16(address)] = true
+	} else {
+		delete(s.breakpoints, uint16(address))
+	}
+	return "OK"
+}
+
+// execute runs a single VM step, ticking the timers every
+// CPUFrequencyHz/TimerFrequencyHz steps, and reports whether the step
+// succeeded
+func (s *Stub) execute() bool {
+	previousTicks := s.cycles * s.opts.TimerFrequencyHz / s.opts.CPUFrequencyHz
+	s.cycles++
+	if s.cycles*s.opts.TimerFrequencyHz/s.opts.CPUFrequencyHz > previousTicks {
+		s.vm.TickTimers()
+	}
+	if err := s.vm.TryStep(); err != nil {
+		log.Print(err)
+		return false
+	}
+	return true
+}
+
+func (s *Stub) step() string {
+	if !s.execute() {
+		return stopReply(sigill)
+	}
+	return stopReply(sigtrap)
+}
+
+// cont runs until a breakpoint, an error or an interrupt, queueing the
+// other packets received meanwhile
+func (s *Stub) cont(events <-chan event) string {
+	// always execute the instruction at a breakpoint being continued from
+	if !s.execute() {
+		return stopReply(sigill)
+	}
+	for i := 1; ; i++ {
+		if s.breakpoints[s.vm.PC] {
+			return stopReply(sigtrap)
+		}
+		if i%interruptCheckInterval == 0 {
+			select {
+			case ev, ok := <-events:
+				if !ok || ev.interrupt {
+					return stopReply(sigint)
+				}
+				s.pending = append(s.pending, ev.packet)
+			default:
+			}
+		}
+		if !s.execute() {
+			return stopReply(sigill)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package gdbstub
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+// client is a minimal RSP client
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newClient(t *testing.T, rom []uint8) (*client, *chip8.VM) {
+	vm := chip8.New(rom)
+	stub := New(vm, Options{CPUFrequencyHz: 600, TimerFrequencyHz: 60})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go stub.Serve(l)
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { clientConn.Close() })
+	return &client{t: t, conn: clientConn, reader: bufio.NewReader(clientConn)}, vm
+}
+
+func (c *client) send(data string) {
+	c.t.Helper()
+	if err := writePacket(c.conn, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) receive() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if b != '$' {
+			// acknowledgement
+			continue
+		}
+		data, err := c.reader.ReadString('#')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		var checksum [2]byte
+		if _, err := io.ReadFull(c.reader, checksum[:]); err != nil {
+			c.t.Fatal(err)
+		}
+		data = strings.TrimSuffix(data, "#")
+		if fmt.Sprintf("%02x", sum([]byte(data))) != string(checksum[:]) {
+			c.t.Fatalf("bad checksum for %q", data)
+		}
+		return string(unescape([]byte(data)))
+	}
+}
+
+func (c *client) call(data, expected string) {
+	c.t.Helper()
+	c.send(data)
+	if actual := c.receive(); actual != expected {
+		c.t.Errorf("%s: Expected %q, Actual %q", data, expected, actual)
+	}
+}
+
+var loopROM = []uint8{
+	0x60, 0x05, // 0x200 LD V0, 5
+	0x70, 0x01, // 0x202 ADD V0, 1
+	0x12, 0x02, // 0x204 JP 0x202
+}
+
+func TestEscape(t *testing.T) {
+	for _, s := range []string{"", "abc", "$#}*", "a}b"} {
+		if actual := string(unescape(escape(s))); actual != s {
+			t.Errorf("unescape(escape(%q)): Actual %q", s, actual)
+		}
+	}
+}
+
+func TestTargetDescription(t *testing.T) {
+	c, _ := newClient(t, loopROM)
+	c.send("qSupported:multiprocess+;xmlRegisters=i386")
+	if reply := c.receive(); !strings.Contains(reply, "qXfer:features:read+") {
+		t.Errorf("Expected qXfer:features:read+ in %q", reply)
+	}
+	var document strings.Builder
+	for {
+		c.send(fmt.Sprintf("qXfer:features:read:target.xml:%x,%x", document.Len(), 64))
+		reply := c.receive()
+		document.WriteString(reply[1:])
+		if reply[0] == 'l' {
+			break
+		}
+	}
+	if document.String() != targetXML {
+		t.Errorf("Expected %q, Actual %q", targetXML, document.String())
+	}
+	for _, name := range []string{"v0", "vf", `"i"`, "pc", "sp", "dt", "st"} {
+		if !strings.Contains(targetXML, name) {
+			t.Errorf("Expected register %s in target description", name)
+		}
+	}
+}
+
+func TestRegisters(t *testing.T) {
+	c, vm := newClient(t, loopROM)
+	vm.V[0xF] = 0xAB
+	vm.I = 0x1234
+	vm.DT = 7
+	c.call("g", "000000000000000000000000000000ab"+"3412"+"0002"+"00"+"07"+"00")
+	c.call("p11", "0002")
+	c.call("P11=0402", "OK")
+	c.call("p11", "0402")
+	c.call("P1=ff", "OK")
+	if vm.V[1] != 0xFF || vm.PC != 0x204 {
+		t.Errorf("Expected V1 = 0xff, PC = 0x204, Actual V1 = %#x, PC = %#x", vm.V[1], vm.PC)
+	}
+	c.call("p15", "E01")
+
+	// PC outside memory and SP beyond the stack leave the VM unchanged
+	c.call("P11=ff0f", "E01")
+	c.call("P12=11", "E01")
+	c.call("G"+strings.Repeat("00", 16)+"0000"+"0003"+"11"+"00"+"00", "E01")
+	if vm.PC != 0x204 || vm.SP != 0 || vm.V[1] != 0xFF {
+		t.Errorf("Expected PC = 0x204, SP = 0, V1 = 0xff, Actual PC = %#x, SP = %d, V1 = %#x", vm.PC, vm.SP, vm.V[1])
+	}
+	c.call("G"+strings.Repeat("00", 16)+"0000"+"0003"+"10"+"00"+"00", "OK")
+	if vm.PC != 0x300 || vm.SP != 16 || vm.V[1] != 0 {
+		t.Errorf("Expected PC = 0x300, SP = 16, V1 = 0, Actual PC = %#x, SP = %d, V1 = %#x", vm.PC, vm.SP, vm.V[1])
+	}
+}
+
+func TestMemory(t *testing.T) {
+	c, vm := newClient(t, loopROM)
+	c.call("m200,6", "600570011202")
+	c.call("M300,2:beef", "OK")
+	if vm.Memory[0x300] != 0xBE || vm.Memory[0x301] != 0xEF {
+		t.Error("Expected memory write at 0x300")
+	}
+	c.call("mfff,2", "E01")
+}
+
+func TestStepAndBreakpoints(t *testing.T) {
+	c, vm := newClient(t, loopROM)
+	c.call("?", "S05")
+	c.call("s", "S05")
+	if vm.PC != 0x202 || vm.V[0] != 5 {
+		t.Fatalf("Expected PC = 0x202, V0 = 5 after step, Actual PC = %#x, V0 = %d", vm.PC, vm.V[0])
+	}
+	c.call("Z0,204,2", "OK")
+	c.call("c", "S05")
+	if vm.PC != 0x204 || vm.V[0] != 6 {
+		t.Errorf("Expected PC = 0x204, V0 = 6 at breakpoint, Actual PC = %#x, V0 = %d", vm.PC, vm.V[0])
+	}
+	// continuing from a breakpoint loops back to it
+	c.call("c", "S05")
+	if vm.PC != 0x204 || vm.V[0] != 7 {
+		t.Errorf("Expected PC = 0x204, V0 = 7 at breakpoint, Actual PC = %#x, V0 = %d", vm.PC, vm.V[0])
+	}
+	c.call("z0,204,2", "OK")
+	c.call("vCont;s:1", "S05")
+}
+
+func TestInterrupt(t *testing.T) {
+	c, _ := newClient(t, loopROM)
+	c.send("c")
+	time.Sleep(10 * time.Millisecond)
+	c.conn.Write([]byte{interruptByte})
+	if reply := c.receive(); reply != "S02" {
+		t.Errorf("Expected S02 after interrupt, Actual %q", reply)
+	}
+}
+
+func TestPacketWhileRunning(t *testing.T) {
+	c, _ := newClient(t, loopROM)
+	c.send("c")
+	c.send("m200,2")
+	time.Sleep(10 * time.Millisecond)
+	c.conn.Write([]byte{interruptByte})
+	if reply := c.receive(); reply != "S02" {
+		t.Errorf("Expected S02 after interrupt, Actual %q", reply)
+	}
+	if reply := c.receive(); reply != "6005" {
+		t.Errorf("Expected the queued read after the stop reply, Actual %q", reply)
+	}
+}
+
+func TestKill(t *testing.T) {
+	c, _ := newClient(t, loopROM)
+	c.send("k")
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	rest, err := io.ReadAll(c.reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(rest), "$") {
+		t.Errorf("Expected no reply to k, Actual %q", rest)
+	}
+}
+
+func TestIllegalInstruction(t *testing.T) {
+	c, _ := newClient(t, []uint8{0xFF, 0xFF})
+	c.call("c", "S04")
+}
+
+func TestNoAckMode(t *testing.T) {
+	c, _ := newClient(t, loopROM)
+	c.call("QStartNoAckMode", "OK")
+	c.send("m200,2")
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if b, _ := c.reader.ReadByte(); b != '$' {
+		t.Errorf("Expected no acknowledgement, Actual %q", b)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	c, _ := newClient(t, loopROM)
+	monitor := func(command, expected string) {
+		t.Helper()
+		c.send("qRcmd," + hex.EncodeToString([]byte(command)))
+		reply, err := hex.DecodeString(c.receive())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(reply) != expected {
+			t.Errorf("monitor %s: Expected %q, Actual %q", command, expected, reply)
+		}
+	}
+	monitor("sprite 0x0 2", "0x000   ####....  0xF0\n0x001   #..#....  0x90\n")
+	monitor("sprites", "No sprites drawn\n")
+	monitor("help", "Commands: sprite ADDRESS [ROWS], sprites\n")
+}
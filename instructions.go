@@ -0,0 +1,409 @@
+package chip8
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./cmd/chip8-isa -o INSTRUCTIONS.md
+
+// Platform is a CHIP-8 variant, each one extending the previous one
+type Platform int
+
+const (
+	PlatformCHIP8 Platform = iota
+	PlatformSCHIP
+	PlatformXOCHIP
+)
+
+func (p Platform) String() string {
+	switch p {
+	case PlatformCHIP8:
+		return "CHIP-8"
+	case PlatformSCHIP:
+		return "SCHIP"
+	case PlatformXOCHIP:
+		return "XO-CHIP"
+	}
+	return fmt.Sprintf("Platform(%d)", int(p))
+}
+
+// Operand is a field of an op, named as in the instruction patterns
+type Operand string
+
+const (
+	// OperandNNN is the lowest 12 bits, an address
+	OperandNNN Operand = "nnn"
+	// OperandKK is the lowest 8 bits, a byte
+	OperandKK Operand = "kk"
+	// OperandN is the lowest 4 bits, a nibble
+	OperandN Operand = "n"
+	// OperandX is the lower 4 bits of the high byte, a register
+	OperandX Operand = "x"
+	// OperandY is the upper 4 bits of the low byte, a register
+	OperandY Operand = "y"
+)
+
+func (o Operand) extract(op EncodedOp) uint16 {
+	switch o {
+	case OperandNNN:
+		return op.nnn()
+	case OperandKK:
+		return uint16(op.kk())
+	case OperandN:
+		return uint16(op.n())
+	case OperandX:
+		return uint16(op.x())
+	case OperandY:
+		return uint16(op.y())
+	}
+	panic(fmt.Sprintf("Unsupported operand: %s", o))
+}
+
+func (o Operand) insert(value uint16) EncodedOp {
+	switch o {
+	case OperandNNN:
+		return EncodedOp(value & 0xFFF)
+	case OperandKK:
+		return EncodedOp(value & 0xFF)
+	case OperandN:
+		return EncodedOp(value & 0xF)
+	case OperandX:
+		return EncodedOp(value&0xF) << 8
+	case OperandY:
+		return EncodedOp(value&0xF) << 4
+	}
+	panic(fmt.Sprintf("Unsupported operand: %s", o))
+}
+
+func (o Operand) max() uint64 {
+	switch o {
+	case OperandNNN:
+		return 0xFFF
+	case OperandKK:
+		return 0xFF
+	}
+	return 0xF
+}
+
+// Instruction describes an op of the instruction set
+type Instruction struct {
+	// Pattern is the op with operands as letters, e.g. 8xy4
+	Pattern string
+	// Mask has the bits set which are not operands, and Bits is their value
+	Mask, Bits EncodedOp
+	// Syntax is the assembly with operands in braces, e.g. ADD V{x}, V{y}
+	Syntax string
+	// Mnemonic is the first word of the syntax, e.g. ADD
+	Mnemonic string
+	// Operands are the operands in the order they appear in the syntax
+	Operands []Operand
+	// Cycles is the approximate cost on the COSMAC VIP in machine cycles of
+	// 8 clock periods at 1.76 MHz, excluding waiting for the display
+	Cycles int
+	// Platform is the first platform with the instruction
+	Platform Platform
+	// Description is a one sentence description
+	Description string
+
+	// decode is nil for instructions not executed by the VM
+	decode func(EncodedOp) Op
+}
+
+// Instructions is the instruction set, which decoding, disassembly, assembly
+// and INSTRUCTIONS.md are all generated from. Only CHIP-8 instructions are
+// executed by the VM, the others are known for disassembling and assembling
+// ROMs for other platforms. XO-CHIP's four byte F000 nnnn is not included.
+var Instructions = []*Instruction{
+	{Pattern: "00E0", Syntax: "CLS", Cycles: 24, Description: "Clear the display.",
+		decode: func(op EncodedOp) Op { return op.decodeCLS() }},
+	{Pattern: "00EE", Syntax: "RET", Cycles: 23, Description: "Return from a subroutine.",
+		decode: func(op EncodedOp) Op { return op.decodeRET() }},
+	{Pattern: "1nnn", Syntax: "JP 0x{nnn}", Cycles: 23, Description: "Jump to location nnn.",
+		decode: func(op EncodedOp) Op { return op.decodeJP() }},
+	{Pattern: "2nnn", Syntax: "CALL 0x{nnn}", Cycles: 23, Description: "Call subroutine at nnn.",
+		decode: func(op EncodedOp) Op { return op.decodeCALL() }},
+	{Pattern: "3xkk", Syntax: "SE V{x}, 0x{kk}", Cycles: 12, Description: "Skip next instruction if Vx = kk.",
+		decode: func(op EncodedOp) Op { return op.decodeSEVx() }},
+	{Pattern: "4xkk", Syntax: "SNE V{x}, 0x{kk}", Cycles: 12, Description: "Skip next instruction if Vx != kk.",
+		decode: func(op EncodedOp) Op { return op.decodeSNEVx() }},
+	{Pattern: "5xy0", Syntax: "SE V{x}, V{y}", Cycles: 16, Description: "Skip next instruction if Vx = Vy.",
+		decode: func(op EncodedOp) Op { return op.decodeSEVxVy() }},
+	{Pattern: "6xkk", Syntax: "LD V{x}, 0x{kk}", Cycles: 6, Description: "Set Vx = kk.",
+		decode: func(op EncodedOp) Op { return op.decodeLDVx() }},
+	{Pattern: "7xkk", Syntax: "ADD V{x}, 0x{kk}", Cycles: 10, Description: "Set Vx = Vx + kk.",
+		decode: func(op EncodedOp) Op { return op.decodeADDVx() }},
+	{Pattern: "8xy0", Syntax: "LD V{x}, V{y}", Cycles: 44, Description: "Set Vx = Vy.",
+		decode: func(op EncodedOp) Op { return op.decodeLDVxVy() }},
+	{Pattern: "8xy1", Syntax: "OR V{x}, V{y}", Cycles: 44, Description: "Set Vx = Vx OR Vy.",
+		decode: func(op EncodedOp) Op { return op.decodeORVxVy() }},
+	{Pattern: "8xy2", Syntax: "AND V{x}, V{y}", Cycles: 44, Description: "Set Vx = Vx AND Vy.",
+		decode: func(op EncodedOp) Op { return op.decodeANDVxVy() }},
+	{Pattern: "8xy3", Syntax: "XOR V{x}, V{y}", Cycles: 44, Description: "Set Vx = Vx XOR Vy.",
+		decode: func(op EncodedOp) Op { return op.decodeXORVxVy() }},
+	{Pattern: "8xy4", Syntax: "ADD V{x}, V{y}", Cycles: 44, Description: "Set Vx = Vx + Vy, set VF = carry.",
+		decode: func(op EncodedOp) Op { return op.decodeADDVxVy() }},
+	{Pattern: "8xy5", Syntax: "SUB V{x}, V{y}", Cycles: 44, Description: "Set Vx = Vx - Vy, set VF = NOT borrow.",
+		decode: func(op EncodedOp) Op { return op.decodeSUBVxVy() }},
+	{Pattern: "8xy6", Syntax: "SHR V{x}", Cycles: 44, Description: "Set Vx = Vx SHR 1.",
+		decode: func(op EncodedOp) Op { return op.decodeSHRVx() }},
+	{Pattern: "8xy7", Syntax: "SUBN V{x}, V{y}", Cycles: 44, Description: "Set Vx = Vy - Vx, set VF = NOT borrow.",
+		decode: func(op EncodedOp) Op { return op.decodeSUBNVxVy() }},
+	{Pattern: "8xyE", Syntax: "SHL V{x}", Cycles: 44, Description: "Set Vx = Vx SHL 1.",
+		decode: func(op EncodedOp) Op { return op.decodeSHLVx() }},
+	{Pattern: "9xy0", Syntax: "SNE V{x}, V{y}", Cycles: 16, Description: "Skip next instruction if Vx != Vy.",
+		decode: func(op EncodedOp) Op { return op.decodeSNEVxVy() }},
+	{Pattern: "Annn", Syntax: "LD I, 0x{nnn}", Cycles: 12, Description: "Set I = nnn.",
+		decode: func(op EncodedOp) Op { return op.decodeLDI() }},
+	{Pattern: "Bnnn", Syntax: "JP V0, 0x{nnn}", Cycles: 23, Description: "Jump to location nnn + V0.",
+		decode: func(op EncodedOp) Op { return op.decodeJPV0() }},
+	{Pattern: "Cxkk", Syntax: "RND V{x}, 0x{kk}", Cycles: 36, Description: "Set Vx = random byte AND kk.",
+		decode: func(op EncodedOp) Op { return op.decodeRNDVx() }},
+	{Pattern: "Dxyn", Syntax: "DRW V{x}, V{y}, {n}", Cycles: 680, Description: "Display n-byte sprite starting at memory location I at (Vx, Vy), set VF = collision.",
+		decode: func(op EncodedOp) Op { return op.decodeDRWVxVy() }},
+	{Pattern: "Ex9E", Syntax: "SKP V{x}", Cycles: 16, Description: "Skip next instruction if key with the value of Vx is pressed.",
+		decode: func(op EncodedOp) Op { return op.decodeSKPVx() }},
+	{Pattern: "ExA1", Syntax: "SKNP V{x}", Cycles: 16, Description: "Skip next instruction if key with the value of Vx is not pressed.",
+		decode: func(op EncodedOp) Op { return op.decodeSKNPVx() }},
+	{Pattern: "Fx07", Syntax: "LD V{x}, DT", Cycles: 10, Description: "Set Vx = delay timer value.",
+		decode: func(op EncodedOp) Op { return op.decodeLDVxDT() }},
+	{Pattern: "Fx0A", Syntax: "LD V{x}, K", Cycles: 10, Description: "Wait for a key press, store the value of the key in Vx.",
+		decode: func(op EncodedOp) Op { return op.decodeLDVxK() }},
+	{Pattern: "Fx15", Syntax: "LD DT, V{x}", Cycles: 10, Description: "Set delay timer = Vx.",
+		decode: func(op EncodedOp) Op { return op.decodeLDDTVx() }},
+	{Pattern: "Fx18", Syntax: "LD ST, V{x}", Cycles: 10, Description: "Set sound timer = Vx.",
+		decode: func(op EncodedOp) Op { return op.decodeLDSTVx() }},
+	{Pattern: "Fx1E", Syntax: "ADD I, V{x}", Cycles: 19, Description: "Set I = I + Vx.",
+		decode: func(op EncodedOp) Op { return op.decodeADDIVx() }},
+	{Pattern: "Fx29", Syntax: "LD F, V{x}", Cycles: 20, Description: "Set I = location of sprite for digit Vx.",
+		decode: func(op EncodedOp) Op { return op.decodeLDFVx() }},
+	{Pattern: "Fx33", Syntax: "LD B, V{x}", Cycles: 204, Description: "Store BCD representation of Vx in memory locations I, I+1, and I+2.",
+		decode: func(op EncodedOp) Op { return op.decodeLDBVx() }},
+	{Pattern: "Fx55", Syntax: "LD [I], V{x}", Cycles: 133, Description: "Store registers V0 through Vx in memory starting at location I.",
+		decode: func(op EncodedOp) Op { return op.decodeLDIVx() }},
+	{Pattern: "Fx65", Syntax: "LD V{x}, [I]", Cycles: 133, Description: "Read registers V0 through Vx from memory starting at location I.",
+		decode: func(op EncodedOp) Op { return op.decodeLDVxI() }},
+
+	{Pattern: "00Cn", Syntax: "SCD {n}", Platform: PlatformSCHIP, Description: "Scroll the display down n lines."},
+	{Pattern: "00FB", Syntax: "SCR", Platform: PlatformSCHIP, Description: "Scroll the display right 4 pixels."},
+	{Pattern: "00FC", Syntax: "SCL", Platform: PlatformSCHIP, Description: "Scroll the display left 4 pixels."},
+	{Pattern: "00FD", Syntax: "EXIT", Platform: PlatformSCHIP, Description: "Exit the interpreter."},
+	{Pattern: "00FE", Syntax: "LOW", Platform: PlatformSCHIP, Description: "Switch to 64x32 low resolution."},
+	{Pattern: "00FF", Syntax: "HIGH", Platform: PlatformSCHIP, Description: "Switch to 128x64 high resolution."},
+	{Pattern: "Dxy0", Syntax: "DRW V{x}, V{y}, 0", Platform: PlatformSCHIP, Description: "Display 16x16 sprite starting at memory location I at (Vx, Vy), set VF = collision."},
+	{Pattern: "Fx30", Syntax: "LD HF, V{x}", Platform: PlatformSCHIP, Description: "Set I = location of 10-byte sprite for digit Vx."},
+	{Pattern: "Fx75", Syntax: "LD R, V{x}", Platform: PlatformSCHIP, Description: "Store V0 through Vx in the RPL user flags."},
+	{Pattern: "Fx85", Syntax: "LD V{x}, R", Platform: PlatformSCHIP, Description: "Read V0 through Vx from the RPL user flags."},
+
+	{Pattern: "00Dn", Syntax: "SCU {n}", Platform: PlatformXOCHIP, Description: "Scroll the display up n lines."},
+	{Pattern: "5xy2", Syntax: "SAVE V{x}, V{y}", Platform: PlatformXOCHIP, Description: "Store registers Vx through Vy in memory starting at location I."},
+	{Pattern: "5xy3", Syntax: "LOAD V{x}, V{y}", Platform: PlatformXOCHIP, Description: "Read registers Vx through Vy from memory starting at location I."},
+	{Pattern: "Fx01", Syntax: "PLANE {x}", Platform: PlatformXOCHIP, Description: "Select the drawing planes x."},
+	{Pattern: "F002", Syntax: "AUDIO", Platform: PlatformXOCHIP, Description: "Load the 16-byte audio pattern starting at memory location I."},
+	{Pattern: "Fx3A", Syntax: "PITCH V{x}", Platform: PlatformXOCHIP, Description: "Set the audio pattern playback rate from Vx."},
+}
+
+// instructionsByNibble are the instructions by their highest nibble, the
+// most specific first
+var instructionsByNibble [16][]*Instruction
+
+func init() {
+	for _, in := range Instructions {
+		in.parse()
+		nibble := in.Bits >> 12
+		instructionsByNibble[nibble] = append(instructionsByNibble[nibble], in)
+	}
+	for _, instructions := range instructionsByNibble {
+		sort.SliceStable(instructions, func(i, j int) bool {
+			return instructions[i].Mask > instructions[j].Mask
+		})
+	}
+}
+
+func (in *Instruction) parse() {
+	if len(in.Pattern) != 4 {
+		panic(fmt.Sprintf("Invalid pattern: %s", in.Pattern))
+	}
+	for i, c := range in.Pattern {
+		shift := uint(12 - 4*i)
+		if digit, err := strconv.ParseUint(string(c), 16, 4); err == nil {
+			in.Mask |= 0xF << shift
+			in.Bits |= EncodedOp(digit) << shift
+		}
+	}
+	in.Mnemonic = strings.Fields(in.Syntax)[0]
+	for rest := in.Syntax; strings.Contains(rest, "{"); {
+		start, end := strings.Index(rest, "{"), strings.Index(rest, "}")
+		in.Operands = append(in.Operands, Operand(rest[start+1:end]))
+		rest = rest[end+1:]
+	}
+}
+
+// Lookup returns the instruction of an op on a platform, or nil
+func (p Platform) Lookup(op EncodedOp) *Instruction {
+	for _, in := range instructionsByNibble[op>>12] {
+		if in.Platform <= p && op&in.Mask == in.Bits {
+			return in
+		}
+	}
+	return nil
+}
+
+// Disassemble returns the assembly of an op
+func (in *Instruction) Disassemble(op EncodedOp) string {
+	var b strings.Builder
+	rest := in.Syntax
+	for _, operand := range in.Operands {
+		start, end := strings.Index(rest, "{"), strings.Index(rest, "}")
+		b.WriteString(rest[:start])
+		value := operand.extract(op)
+		switch operand {
+		case OperandNNN:
+			fmt.Fprintf(&b, "%03X", value)
+		case OperandKK:
+			fmt.Fprintf(&b, "%02X", value)
+		case OperandX, OperandY:
+			fmt.Fprintf(&b, "%X", value)
+		default:
+			fmt.Fprintf(&b, "%d", value)
+		}
+		rest = rest[end+1:]
+	}
+	b.WriteString(rest)
+	return b.String()
+}
+
+// Disassemble returns the assembly of an op on a platform, and a data word if
+// the platform does not have the op
+func (p Platform) Disassemble(op EncodedOp) string {
+	if in := p.Lookup(op); in != nil {
+		return in.Disassemble(op)
+	}
+	return fmt.Sprintf("DW 0x%04X", uint16(op))
+}
+
+// assemblyArgs splits a line of assembly into its upper case mnemonic and
+// arguments
+func assemblyArgs(line string) (string, []string) {
+	line = strings.ToUpper(strings.TrimSpace(line))
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) == 1 {
+		return fields[0], nil
+	}
+	args := strings.Split(fields[1], ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return fields[0], args
+}
+
+// assemble encodes a line of assembly if it matches the syntax of the
+// instruction
+func (in *Instruction) assemble(mnemonic string, args []string) (EncodedOp, bool) {
+	syntaxMnemonic, syntaxArgs := assemblyArgs(in.Syntax)
+	if mnemonic != syntaxMnemonic || len(args) != len(syntaxArgs) {
+		return 0, false
+	}
+	op := in.Bits
+	for i, syntaxArg := range syntaxArgs {
+		start := strings.Index(syntaxArg, "{")
+		if start == -1 {
+			if args[i] != syntaxArg {
+				return 0, false
+			}
+			continue
+		}
+		end := strings.Index(syntaxArg, "}")
+		operand := Operand(strings.ToLower(syntaxArg[start+1 : end]))
+		prefix := syntaxArg[:start]
+		if prefix == "0X" {
+			// Numbers may be written in any base
+			prefix = ""
+		}
+		if !strings.HasPrefix(args[i], prefix) {
+			return 0, false
+		}
+		s := strings.TrimPrefix(args[i], prefix)
+		base := 0
+		if operand == OperandX || operand == OperandY {
+			base = 16
+		}
+		if strings.HasPrefix(s, "#") {
+			s, base = s[1:], 16
+		}
+		value, err := strconv.ParseUint(s, base, 16)
+		if err != nil || value > operand.max() {
+			return 0, false
+		}
+		op |= operand.insert(uint16(value))
+	}
+	if op&in.Mask != in.Bits {
+		return 0, false
+	}
+	return op, true
+}
+
+// Assemble encodes a line of assembly in the syntax of Disassemble on a
+// platform. Mnemonics and registers are case insensitive and numbers may be
+// decimal, 0x or # prefixed hex, 0b prefixed binary or 0o prefixed octal.
+func (p Platform) Assemble(line string) (EncodedOp, error) {
+	mnemonic, args := assemblyArgs(line)
+	if mnemonic == "DW" && len(args) == 1 {
+		s, base := args[0], 0
+		if strings.HasPrefix(s, "#") {
+			s, base = s[1:], 16
+		}
+		value, err := strconv.ParseUint(s, base, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid word: %s", args[0])
+		}
+		return EncodedOp(value), nil
+	}
+	for _, instructions := range instructionsByNibble {
+		for _, in := range instructions {
+			if in.Platform > p {
+				continue
+			}
+			if op, ok := in.assemble(mnemonic, args); ok && p.Lookup(op) == in {
+				return op, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid %s instruction: %s", p, strings.TrimSpace(line))
+}
+
+// Assemble encodes a line of CHIP-8 assembly, see Platform.Assemble
+func Assemble(line string) (EncodedOp, error) {
+	return PlatformCHIP8.Assemble(line)
+}
+
+// WriteInstructionsMarkdown writes the instruction set as the Markdown of
+// INSTRUCTIONS.md
+func WriteInstructionsMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Instruction set\n\n")
+	b.WriteString("Generated from `chip8.Instructions` by `go generate`, do not edit.\n\n")
+	b.WriteString("Cycles are the approximate cost on the COSMAC VIP in machine cycles\n")
+	b.WriteString("of 8 clock periods, excluding waiting for the display.\n")
+	for p := PlatformCHIP8; p <= PlatformXOCHIP; p++ {
+		fmt.Fprintf(&b, "\n## %s\n\n", p)
+		b.WriteString("| Op | Assembly | Cycles | Description |\n")
+		b.WriteString("|----|----------|--------|-------------|\n")
+		for _, in := range Instructions {
+			if in.Platform != p {
+				continue
+			}
+			cycles := "-"
+			if in.decode != nil {
+				cycles = strconv.Itoa(in.Cycles)
+			}
+			syntax := strings.NewReplacer("{", "", "}", "").Replace(in.Syntax)
+			fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s |\n", in.Pattern, syntax, cycles, in.Description)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
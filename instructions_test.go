@@ -0,0 +1,200 @@
+package chip8
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// legacyDecode is the nested switch decoder which preceded the instruction
+// table
+func legacyDecode(op EncodedOp) (Op, bool) {
+	switch op >> 12 {
+	case 0x0:
+		switch op {
+		case 0x00E0:
+			return op.decodeCLS(), true
+		case 0x00EE:
+			return op.decodeRET(), true
+		}
+	case 0x1:
+		return op.decodeJP(), true
+	case 0x2:
+		return op.decodeCALL(), true
+	case 0x3:
+		return op.decodeSEVx(), true
+	case 0x4:
+		return op.decodeSNEVx(), true
+	case 0x5:
+		switch op & 0x000F {
+		case 0x0:
+			return op.decodeSEVxVy(), true
+		}
+	case 0x6:
+		return op.decodeLDVx(), true
+	case 0x7:
+		return op.decodeADDVx(), true
+	case 0x8:
+		switch op & 0x000F {
+		case 0x0:
+			return op.decodeLDVxVy(), true
+		case 0x1:
+			return op.decodeORVxVy(), true
+		case 0x2:
+			return op.decodeANDVxVy(), true
+		case 0x3:
+			return op.decodeXORVxVy(), true
+		case 0x4:
+			return op.decodeADDVxVy(), true
+		case 0x5:
+			return op.decodeSUBVxVy(), true
+		case 0x6:
+			return op.decodeSHRVx(), true
+		case 0x7:
+			return op.decodeSUBNVxVy(), true
+		case 0xE:
+			return op.decodeSHLVx(), true
+		}
+	case 0x9:
+		switch op & 0x000F {
+		case 0x0:
+			return op.decodeSNEVxVy(), true
+		}
+	case 0xA:
+		return op.decodeLDI(), true
+	case 0xB:
+		return op.decodeJPV0(), true
+	case 0xC:
+		return op.decodeRNDVx(), true
+	case 0xD:
+		return op.decodeDRWVxVy(), true
+	case 0xE:
+		switch op & 0x00FF {
+		case 0x9E:
+			return op.decodeSKPVx(), true
+		case 0xA1:
+			return op.decodeSKNPVx(), true
+		}
+	case 0xF:
+		switch op & 0x00FF {
+		case 0x07:
+			return op.decodeLDVxDT(), true
+		case 0x0A:
+			return op.decodeLDVxK(), true
+		case 0x15:
+			return op.decodeLDDTVx(), true
+		case 0x18:
+			return op.decodeLDSTVx(), true
+		case 0x1E:
+			return op.decodeADDIVx(), true
+		case 0x29:
+			return op.decodeLDFVx(), true
+		case 0x33:
+			return op.decodeLDBVx(), true
+		case 0x55:
+			return op.decodeLDIVx(), true
+		case 0x65:
+			return op.decodeLDVxI(), true
+		}
+	}
+	return nil, false
+}
+
+func TestInstructionsEquivalentToLegacyDecode(t *testing.T) {
+	for i := 0; i <= 0xFFFF; i++ {
+		op := EncodedOp(i)
+		expected, expectedOK := legacyDecode(op)
+		actual, ok := op.tryDecode()
+		if ok != expectedOK || actual != expected {
+			t.Fatalf("(%#04x).tryDecode(): Expected %#v %v, Actual %#v %v", i, expected, expectedOK, actual, ok)
+		}
+	}
+}
+
+func TestAssembleRoundTrip(t *testing.T) {
+	for p := PlatformCHIP8; p <= PlatformXOCHIP; p++ {
+		for i := 0; i <= 0xFFFF; i++ {
+			op := EncodedOp(i)
+			asm := p.Disassemble(op)
+			actual, err := p.Assemble(asm)
+			if err != nil {
+				t.Fatalf("%s.Assemble(%q): %v", p, asm, err)
+			}
+			// SHR and SHL do not show Vy, so compare the assembly
+			if p.Disassemble(actual) != asm {
+				t.Fatalf("%s.Assemble(%q): Expected %#04x, Actual %#04x", p, asm, i, uint16(actual))
+			}
+		}
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	for _, tt := range []struct {
+		line     string
+		platform Platform
+		expected EncodedOp
+	}{
+		{"cls", PlatformCHIP8, 0x00E0},
+		{"jp 0x2a0", PlatformCHIP8, 0x12A0},
+		{"JP V0, #300", PlatformCHIP8, 0xB300},
+		{"ld va, 5", PlatformCHIP8, 0x6A05},
+		{"LD VA, 0b101", PlatformCHIP8, 0x6A05},
+		{"LD V1, V2", PlatformCHIP8, 0x8120},
+		{"ld [i], vf", PlatformCHIP8, 0xFF55},
+		{"DRW V1, V2, 15", PlatformCHIP8, 0xD12F},
+		{"DRW V1, V2, 0", PlatformCHIP8, 0xD120},
+		{"DRW V1, V2, 0", PlatformSCHIP, 0xD120},
+		{"HIGH", PlatformSCHIP, 0x00FF},
+		{"PLANE 3", PlatformXOCHIP, 0xF301},
+		{"DW 0x0123", PlatformCHIP8, 0x0123},
+	} {
+		actual, err := tt.platform.Assemble(tt.line)
+		if err != nil || actual != tt.expected {
+			t.Errorf("%s.Assemble(%q): Expected %#04x, Actual %#04x (%v)", tt.platform, tt.line, uint16(tt.expected), uint16(actual), err)
+		}
+	}
+	for _, tt := range []struct {
+		line     string
+		platform Platform
+	}{
+		{"HIGH", PlatformCHIP8},
+		{"LD V1, 0x100", PlatformCHIP8},
+		{"DRW V1, V2, 16", PlatformCHIP8},
+		{"JP 0x1000", PlatformCHIP8},
+		{"LD VG, 1", PlatformCHIP8},
+		{"NOP", PlatformXOCHIP},
+	} {
+		if actual, err := tt.platform.Assemble(tt.line); err == nil {
+			t.Errorf("%s.Assemble(%q): Expected error, Actual %#04x", tt.platform, tt.line, uint16(actual))
+		}
+	}
+}
+
+func TestPlatformLookup(t *testing.T) {
+	if in := PlatformCHIP8.Lookup(0xD120); in.Pattern != "Dxyn" {
+		t.Errorf("CHIP-8 0xD120: Expected Dxyn, Actual %s", in.Pattern)
+	}
+	if in := PlatformSCHIP.Lookup(0xD120); in.Pattern != "Dxy0" {
+		t.Errorf("SCHIP 0xD120: Expected Dxy0, Actual %s", in.Pattern)
+	}
+	if in := PlatformSCHIP.Lookup(0xF301); in != nil {
+		t.Errorf("SCHIP 0xF301: Expected nil, Actual %s", in.Pattern)
+	}
+	if in := PlatformXOCHIP.Lookup(0xF301); in.Pattern != "Fx01" || in.Mnemonic != "PLANE" {
+		t.Errorf("XO-CHIP 0xF301: Expected Fx01 PLANE, Actual %s %s", in.Pattern, in.Mnemonic)
+	}
+}
+
+func TestInstructionsMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteInstructionsMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	committed, err := ioutil.ReadFile("INSTRUCTIONS.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), committed) {
+		t.Errorf("INSTRUCTIONS.md is out of date, run go generate")
+	}
+}
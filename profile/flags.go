@@ -0,0 +1,66 @@
+package profile
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+
+	"github.com/odsod/chip8"
+)
+
+// Flags are the command line flags for profiling shared by the commands
+type Flags struct {
+	Pprof    *string
+	Report   *string
+	profiler *Profiler
+}
+
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	return &Flags{
+		Pprof:  fs.String("profile", "", "Write a pprof profile of the ROM to this file on exit"),
+		Report: fs.String("profileReport", "", "Write an annotated disassembly with execution counts to this file on exit"),
+	}
+}
+
+// Open returns the profiler to set on the VM, or nil when profiling is off
+func (f *Flags) Open(romFile string) (chip8.Tracer, error) {
+	if *f.Pprof == "" && *f.Report == "" {
+		return nil, nil
+	}
+	rom, err := ioutil.ReadFile(romFile)
+	if err != nil {
+		return nil, err
+	}
+	f.profiler = New(rom, romFile)
+	return f.profiler, nil
+}
+
+func writeFile(name string, write func(f *os.File) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Close writes the requested profile outputs
+func (f *Flags) Close() error {
+	if f.profiler == nil {
+		return nil
+	}
+	if *f.Pprof != "" {
+		if err := writeFile(*f.Pprof, func(w *os.File) error { return f.profiler.WritePprof(w) }); err != nil {
+			return err
+		}
+	}
+	if *f.Report != "" {
+		if err := writeFile(*f.Report, func(w *os.File) error { return f.profiler.WriteReport(w) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
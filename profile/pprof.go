@@ -0,0 +1,172 @@
+package profile
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+/*
+protoBuffer encodes the subset of protocol buffers needed for the pprof
+profile.proto format, see
+https://github.com/google/pprof/blob/main/proto/profile.proto
+*/
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(field int, value uint64) {
+	b.data = binary.AppendUvarint(b.data, uint64(field)<<3)
+	b.data = binary.AppendUvarint(b.data, value)
+}
+
+func (b *protoBuffer) bytes(field int, value []byte) {
+	b.data = binary.AppendUvarint(b.data, uint64(field)<<3|2)
+	b.data = binary.AppendUvarint(b.data, uint64(len(value)))
+	b.data = append(b.data, value...)
+}
+
+func (b *protoBuffer) message(field int, m *protoBuffer) {
+	b.bytes(field, m.data)
+}
+
+func (b *protoBuffer) packed(field int, values []uint64) {
+	var p protoBuffer
+	for _, v := range values {
+		p.data = binary.AppendUvarint(p.data, v)
+	}
+	b.bytes(field, p.data)
+}
+
+// Field numbers from profile.proto
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+	profilePeriodType  = 11
+	profilePeriod      = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID      = 1
+	locationAddress = 3
+	locationLine    = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+type stringTable struct {
+	strings []string
+	index   map[string]uint64
+}
+
+func (t *stringTable) id(s string) uint64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	i := uint64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.index[s] = i
+	return i
+}
+
+// WritePprof writes a gzipped pprof profile with one sample per distinct call
+// stack, valued by the number of executed instructions. Functions are the
+// main program and the called subroutines, and line numbers are addresses.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	table := &stringTable{strings: []string{""}, index: map[string]uint64{"": 0}}
+	var profile protoBuffer
+
+	var valueType protoBuffer
+	valueType.varint(valueTypeType, table.id("instructions"))
+	valueType.varint(valueTypeUnit, table.id("count"))
+	profile.message(profileSampleType, &valueType)
+	profile.message(profilePeriodType, &valueType)
+	profile.varint(profilePeriod, 1)
+
+	// deterministic output
+	var samples []*sample
+	for _, s := range p.samples {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i].stack, samples[j].stack
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				if a[k].pc != b[k].pc {
+					return a[k].pc < b[k].pc
+				}
+				return a[k].function < b[k].function
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	locationIDs := make(map[location]uint64)
+	functionIDs := make(map[uint16]uint64)
+	var locations, functions []protoBuffer
+	for _, s := range samples {
+		var ids []uint64
+		for _, l := range s.stack {
+			fid, ok := functionIDs[l.function]
+			if !ok {
+				fid = uint64(len(functions) + 1)
+				functionIDs[l.function] = fid
+				var f protoBuffer
+				f.varint(functionID, fid)
+				f.varint(functionName, table.id(subroutineName(l.function)))
+				f.varint(functionSystemName, table.id(subroutineName(l.function)))
+				f.varint(functionFilename, table.id(p.name))
+				f.varint(functionStartLine, uint64(l.function))
+				functions = append(functions, f)
+			}
+			lid, ok := locationIDs[l]
+			if !ok {
+				lid = uint64(len(locations) + 1)
+				locationIDs[l] = lid
+				var line protoBuffer
+				line.varint(lineFunctionID, fid)
+				line.varint(lineLine, uint64(l.pc))
+				var loc protoBuffer
+				loc.varint(locationID, lid)
+				loc.varint(locationAddress, uint64(l.pc))
+				loc.message(locationLine, &line)
+				locations = append(locations, loc)
+			}
+			ids = append(ids, lid)
+		}
+		var sample protoBuffer
+		sample.packed(sampleLocationID, ids)
+		sample.packed(sampleValue, []uint64{s.count})
+		profile.message(profileSample, &sample)
+	}
+	for i := range locations {
+		profile.message(profileLocation, &locations[i])
+	}
+	for i := range functions {
+		profile.message(profileFunction, &functions[i])
+	}
+	for _, s := range table.strings {
+		profile.bytes(profileStringTable, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,161 @@
+/*
+Package profile measures which parts of a ROM execute and where the cycles go.
+
+A Profiler is a chip8.Tracer counting executions per address and per op
+family, and tracking CALL and RET to attribute cycles to subroutines. The
+results can be written as an annotated disassembly with WriteReport, or as a
+pprof profile with WritePprof for viewing with go tool pprof.
+*/
+package profile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/trace"
+)
+
+const (
+	romStartAddress = 0x200
+	maxCallDepth    = 16
+)
+
+type frame struct {
+	// callSite is the address of the CALL in the caller
+	callSite uint16
+	// function is the address of the subroutine
+	function uint16
+}
+
+// location is an address within a function, the ROM start address being the
+// function of the main program
+type location struct {
+	pc, function uint16
+}
+
+// sample counts the executions with a particular call stack, leaf first
+type sample struct {
+	stack []location
+	count uint64
+}
+
+type Profiler struct {
+	rom  []uint8
+	name string
+
+	// Counts are the number of executions per address
+	Counts [4096]uint64
+
+	// OpCounts are the number of executions per op family, e.g. DRW
+	OpCounts map[string]uint64
+
+	// Calls are the number of calls per call site and subroutine
+	Calls map[[2]uint16]uint64
+
+	// Total is the number of executed instructions
+	Total uint64
+
+	stack   []frame
+	samples map[string]*sample
+}
+
+// New returns a profiler for a ROM, the name being used as file name in
+// pprof profiles
+func New(rom []uint8, name string) *Profiler {
+	return &Profiler{
+		rom:      rom,
+		name:     name,
+		OpCounts: make(map[string]uint64),
+		Calls:    make(map[[2]uint16]uint64),
+		samples:  make(map[string]*sample),
+	}
+}
+
+// function returns the subroutine currently executing, the ROM start address
+// for the main program
+func (p *Profiler) function() uint16 {
+	if len(p.stack) == 0 {
+		return romStartAddress
+	}
+	return p.stack[len(p.stack)-1].function
+}
+
+// addSample counts an execution of pc with the current call stack
+func (p *Profiler) addSample(pc uint16) {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%03x", pc)
+	for _, f := range p.stack {
+		fmt.Fprintf(&key, ",%03x:%03x", f.callSite, f.function)
+	}
+	s, ok := p.samples[key.String()]
+	if !ok {
+		s = &sample{stack: []location{{pc, p.function()}}}
+		for i := len(p.stack) - 1; i >= 0; i-- {
+			caller := uint16(romStartAddress)
+			if i > 0 {
+				caller = p.stack[i-1].function
+			}
+			s.stack = append(s.stack, location{p.stack[i].callSite, caller})
+		}
+		p.samples[key.String()] = s
+	}
+	s.count++
+}
+
+func (p *Profiler) Trace(record *chip8.TraceRecord) {
+	p.Counts[record.PC]++
+	p.OpCounts[trace.Mnemonic(record.Op)]++
+	p.Total++
+	p.addSample(record.PC)
+	switch {
+	case record.Op&0xF000 == 0x2000:
+		function := uint16(record.Op & 0x0FFF)
+		p.Calls[[2]uint16{record.PC, function}]++
+		if len(p.stack) < maxCallDepth {
+			p.stack = append(p.stack, frame{callSite: record.PC, function: function})
+		}
+	case record.Op == 0x00EE:
+		if len(p.stack) > 0 {
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+	}
+}
+
+// Coverage returns the number of distinct executed addresses in the ROM and
+// the number of instruction slots in the ROM
+func (p *Profiler) Coverage() (executed, total int) {
+	for address := romStartAddress; address < romStartAddress+len(p.rom); address++ {
+		if p.Counts[address] > 0 {
+			executed++
+		}
+	}
+	return executed, (len(p.rom) + 1) / 2
+}
+
+// Subroutines returns the addresses of all called subroutines
+func (p *Profiler) Subroutines() map[uint16]bool {
+	result := make(map[uint16]bool)
+	for call := range p.Calls {
+		result[call[1]] = true
+	}
+	return result
+}
+
+// FunctionTime returns the executions per subroutine, self counting only the
+// subroutine's own instructions and inclusive also counting its callees
+func (p *Profiler) FunctionTime() (self, inclusive map[uint16]uint64) {
+	self = make(map[uint16]uint64)
+	inclusive = make(map[uint16]uint64)
+	for _, s := range p.samples {
+		self[s.stack[0].function] += s.count
+		seen := make(map[uint16]bool)
+		for _, l := range s.stack {
+			if !seen[l.function] {
+				inclusive[l.function] += s.count
+				seen[l.function] = true
+			}
+		}
+	}
+	return self, inclusive
+}
@@ -0,0 +1,99 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+var callROM = []uint8{
+	0x22, 0x08, // 0x200 CALL 0x208
+	0x22, 0x08, // 0x202 CALL 0x208
+	0x12, 0x04, // 0x204 JP 0x204
+	0xAA,       // 0x206 data
+	0xBB,       // 0x207 data
+	0x60, 0x01, // 0x208 LD V0, 1
+	0x00, 0xEE, // 0x20A RET
+}
+
+func run(rom []uint8, cycles int) *Profiler {
+	p := New(rom, "test.ch8")
+	vm := chip8.New(rom)
+	vm.SetTracer(p)
+	for i := 0; i < cycles; i++ {
+		vm.Step()
+	}
+	return p
+}
+
+func TestCounts(t *testing.T) {
+	p := run(callROM, 10)
+	for address, expected := range map[int]uint64{
+		0x200: 1, 0x202: 1, 0x204: 4, 0x206: 0, 0x208: 2, 0x20A: 2,
+	} {
+		if p.Counts[address] != expected {
+			t.Errorf("Counts[%#x]: Expected %d, Actual %d", address, expected, p.Counts[address])
+		}
+	}
+	if p.OpCounts["CALL"] != 2 || p.OpCounts["RET"] != 2 || p.OpCounts["JP"] != 4 || p.OpCounts["LD"] != 2 {
+		t.Errorf("Unexpected op counts: %v", p.OpCounts)
+	}
+	if p.Calls[[2]uint16{0x200, 0x208}] != 1 || p.Calls[[2]uint16{0x202, 0x208}] != 1 {
+		t.Errorf("Unexpected calls: %v", p.Calls)
+	}
+	if executed, total := p.Coverage(); executed != 5 || total != 6 {
+		t.Errorf("Coverage(): Expected 5 of 6, Actual %d of %d", executed, total)
+	}
+}
+
+func TestFunctionTime(t *testing.T) {
+	p := run(callROM, 10)
+	self, inclusive := p.FunctionTime()
+	if self[0x200] != 6 || self[0x208] != 4 {
+		t.Errorf("Unexpected self time: %v", self)
+	}
+	if inclusive[0x200] != 10 || inclusive[0x208] != 4 {
+		t.Errorf("Unexpected inclusive time: %v", inclusive)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(callROM, 10).WriteReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Instructions executed: 10",
+		"sub_208:",
+		"0x204 1204  JP 0x204",
+		"-          0x206 AABB  LD I, 0xABB",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in report:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(callROM, 10).WritePprof(&buf); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"instructions", "count", "main", "sub_208", "test.ch8"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("Expected %q in the string table", s)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/odsod/chip8"
+)
+
+func percent(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// WriteReport writes a summary of op families and subroutines followed by the
+// ROM's disassembly annotated with execution counts
+func (p *Profiler) WriteReport(w io.Writer) error {
+	executed, total := p.Coverage()
+	fmt.Fprintf(w, "ROM: %s\n", p.name)
+	fmt.Fprintf(w, "Instructions executed: %d\n", p.Total)
+	fmt.Fprintf(w, "Coverage: %d of %d instructions (%.1f%%)\n", executed, total, percent(uint64(executed), uint64(total)))
+
+	fmt.Fprintf(w, "\nOp families:\n")
+	var mnemonics []string
+	for m := range p.OpCounts {
+		mnemonics = append(mnemonics, m)
+	}
+	sort.Slice(mnemonics, func(i, j int) bool {
+		if p.OpCounts[mnemonics[i]] != p.OpCounts[mnemonics[j]] {
+			return p.OpCounts[mnemonics[i]] > p.OpCounts[mnemonics[j]]
+		}
+		return mnemonics[i] < mnemonics[j]
+	})
+	for _, m := range mnemonics {
+		fmt.Fprintf(w, "  %-4s %12d %6.2f%%\n", m, p.OpCounts[m], percent(p.OpCounts[m], p.Total))
+	}
+
+	fmt.Fprintf(w, "\nSubroutines:\n")
+	fmt.Fprintf(w, "  %-9s %12s %8s %12s %8s %8s\n", "address", "self", "", "inclusive", "", "calls")
+	self, inclusive := p.FunctionTime()
+	calls := make(map[uint16]uint64)
+	for call, n := range p.Calls {
+		calls[call[1]] += n
+	}
+	var functions []uint16
+	for f := range inclusive {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if inclusive[functions[i]] != inclusive[functions[j]] {
+			return inclusive[functions[i]] > inclusive[functions[j]]
+		}
+		return functions[i] < functions[j]
+	})
+	for _, f := range functions {
+		fmt.Fprintf(w, "  %-9s %12d %7.2f%% %12d %7.2f%% %8d\n",
+			subroutineName(f), self[f], percent(self[f], p.Total), inclusive[f], percent(inclusive[f], p.Total), calls[f])
+	}
+
+	fmt.Fprintf(w, "\nAnnotated disassembly:\n")
+	subroutines := p.Subroutines()
+	end := romStartAddress + len(p.rom)
+	for address := romStartAddress; address < end; {
+		if subroutines[uint16(address)] {
+			fmt.Fprintf(w, "\n%s:\n", subroutineName(uint16(address)))
+		}
+		count := p.Counts[address]
+		// an unexecuted byte followed by executed code is misaligned data
+		if count == 0 && address+1 < end && p.Counts[address+1] > 0 || address+1 == end {
+			fmt.Fprintf(w, "  %12s %7s  0x%03X %02X    DB 0x%02X\n", "-", "", address, p.rom[address-romStartAddress], p.rom[address-romStartAddress])
+			address++
+			continue
+		}
+		op := chip8.EncodedOp(uint16(p.rom[address-romStartAddress])<<8 | uint16(p.rom[address+1-romStartAddress]))
+		if count == 0 {
+			fmt.Fprintf(w, "  %12s %7s  0x%03X %04X  %s\n", "-", "", address, uint16(op), op.Disassemble())
+		} else {
+			fmt.Fprintf(w, "  %12d %6.2f%%  0x%03X %04X  %s\n", count, percent(count, p.Total), address, uint16(op), op.Disassemble())
+		}
+		address += 2
+	}
+	return nil
+}
+
+func subroutineName(address uint16) string {
+	if address == romStartAddress {
+		return "main"
+	}
+	return fmt.Sprintf("sub_%03X", address)
+}
@@ -0,0 +1,91 @@
+package remote
+
+import (
+	"net/rpc"
+	"net/rpc/jsonrpc"
+
+	"github.com/odsod/chip8"
+)
+
+// Client is a typed wrapper for the Chip8 JSON-RPC methods
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects to a server, network being "tcp" or "unix"
+func Dial(network, address string) (*Client, error) {
+	c, err := jsonrpc.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rpc: c}, nil
+}
+
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+func (c *Client) LoadROM(rom []uint8) error {
+	return c.rpc.Call("Chip8.LoadROM", LoadROMArgs{ROM: rom}, &Empty{})
+}
+
+func (c *Client) Reset() error {
+	return c.rpc.Call("Chip8.Reset", Empty{}, &Empty{})
+}
+
+func (c *Client) RunCycles(cycles int) error {
+	return c.rpc.Call("Chip8.Run", RunArgs{Cycles: cycles}, &Empty{})
+}
+
+func (c *Client) RunFrames(frames int) error {
+	return c.rpc.Call("Chip8.Run", RunArgs{Frames: frames}, &Empty{})
+}
+
+func (c *Client) Resume() error {
+	return c.rpc.Call("Chip8.Resume", Empty{}, &Empty{})
+}
+
+func (c *Client) Pause() error {
+	return c.rpc.Call("Chip8.Pause", Empty{}, &Empty{})
+}
+
+func (c *Client) SetKeys(keys [16]bool) error {
+	return c.rpc.Call("Chip8.SetKeys", SetKeysArgs{Keys: keys}, &Empty{})
+}
+
+func (c *Client) GetRegisters() (Registers, error) {
+	var reply Registers
+	err := c.rpc.Call("Chip8.GetRegisters", Empty{}, &reply)
+	return reply, err
+}
+
+func (c *Client) SetRegisters(registers Registers) error {
+	return c.rpc.Call("Chip8.SetRegisters", registers, &Empty{})
+}
+
+func (c *Client) ReadMemory(address uint16, length int) ([]uint8, error) {
+	var reply MemoryReply
+	err := c.rpc.Call("Chip8.ReadMemory", ReadMemoryArgs{Address: address, Length: length}, &reply)
+	return reply.Data, err
+}
+
+func (c *Client) WriteMemory(address uint16, data []uint8) error {
+	return c.rpc.Call("Chip8.WriteMemory", WriteMemoryArgs{Address: address, Data: data}, &Empty{})
+}
+
+// Screenshot returns the screen as a PNG image scaled by scale
+func (c *Client) Screenshot(scale int) ([]uint8, error) {
+	var reply ScreenshotReply
+	err := c.rpc.Call("Chip8.Screenshot", ScreenshotArgs{Scale: scale}, &reply)
+	return reply.PNG, err
+}
+
+func (c *Client) SaveState() (chip8.VM, error) {
+	var reply StateMessage
+	err := c.rpc.Call("Chip8.SaveState", Empty{}, &reply)
+	return reply.State, err
+}
+
+func (c *Client) LoadState(state chip8.VM) error {
+	return c.rpc.Call("Chip8.LoadState", StateMessage{State: state}, &Empty{})
+}
@@ -0,0 +1,253 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+// startServer runs a server on a loopback port and returns a client for it
+func startServer(t *testing.T) *Client {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go Serve(l, NewService(Options{CPUFrequencyHz: 600, TimerFrequencyHz: 60}))
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+var keyDigitROM = []uint8{
+	0xF0, 0x0A, // LD V0, K
+	0xF0, 0x29, // LD F, V0
+	0xD1, 0x15, // DRW V1, V1, 5
+	0x12, 0x06, // JP 0x206
+}
+
+func TestRunAndRegisters(t *testing.T) {
+	client := startServer(t)
+	if err := client.LoadROM([]uint8{
+		0x60, 0x05, // LD V0, 5
+		0x70, 0x01, // ADD V0, 1
+		0x12, 0x02, // JP 0x202
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RunCycles(3); err != nil {
+		t.Fatal(err)
+	}
+	registers, err := client.GetRegisters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registers.V[0] != 6 || registers.PC != 0x202 {
+		t.Errorf("Expected V0 = 6, PC = 0x202, Actual V0 = %d, PC = %#x", registers.V[0], registers.PC)
+	}
+	// one frame at 600 Hz is 10 cycles, i.e. 5 more ADDs
+	if err := client.RunFrames(1); err != nil {
+		t.Fatal(err)
+	}
+	if registers, _ = client.GetRegisters(); registers.V[0] != 11 {
+		t.Errorf("Expected V0 = 11, Actual %d", registers.V[0])
+	}
+	registers.V[0] = 0xAA
+	registers.PC = 0x204
+	if err := client.SetRegisters(registers); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := client.GetRegisters(); after != registers {
+		t.Errorf("Expected %+v, Actual %+v", registers, after)
+	}
+	registers.PC = 0xFFFF
+	if err := client.SetRegisters(registers); err == nil {
+		t.Error("Expected out of range PC to be rejected")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	client := startServer(t)
+	if err := client.WriteMemory(0x300, []uint8{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.ReadMemory(0x2FF, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []uint8{0, 1, 2, 3, 0}) {
+		t.Errorf("Expected [0 1 2 3 0], Actual %v", data)
+	}
+	if _, err := client.ReadMemory(0xFFF, 2); err == nil {
+		t.Error("Expected out of bounds read to fail")
+	}
+	if err := client.WriteMemory(0xFFF, []uint8{1, 2}); err == nil {
+		t.Error("Expected out of bounds write to fail")
+	}
+}
+
+func TestKeysAndScreenshot(t *testing.T) {
+	client := startServer(t)
+	if err := client.LoadROM(keyDigitROM); err != nil {
+		t.Fatal(err)
+	}
+	client.RunFrames(1)
+	client.SetKeys([16]bool{0x8: true})
+	client.RunFrames(1)
+	data, err := client.Screenshot(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+		t.Fatalf("Expected 128x64 screenshot, Actual %dx%d", b.Dx(), b.Dy())
+	}
+	// the "8" digit sprite has its middle row lit and the pixel below blank
+	if r, _, _, _ := img.At(2, 4).RGBA(); r == 0 {
+		t.Error("Expected pixel (1, 2) to be lit")
+	}
+	if r, _, _, _ := img.At(2, 2).RGBA(); r != 0 {
+		t.Error("Expected pixel (1, 1) to be blank")
+	}
+}
+
+func TestSaveAndLoadState(t *testing.T) {
+	client := startServer(t)
+	if err := client.LoadROM(keyDigitROM); err != nil {
+		t.Fatal(err)
+	}
+	client.RunFrames(1)
+	state, err := client.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.IsWaitingForKeyPress {
+		t.Fatal("Expected saved state to be waiting for a key press")
+	}
+	client.SetKeys([16]bool{0x3: true})
+	client.RunFrames(1)
+	if err := client.LoadState(state); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := client.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != state {
+		t.Error("Expected loaded state to equal saved state")
+	}
+}
+
+func TestResumeAndPause(t *testing.T) {
+	client := startServer(t)
+	if err := client.LoadROM([]uint8{
+		0x70, 0x01, // ADD V0, 1
+		0x12, 0x00, // JP 0x200
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := client.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	paused, _ := client.GetRegisters()
+	if paused.V[0] == 0 {
+		t.Error("Expected the VM to run while resumed")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if after, _ := client.GetRegisters(); after != paused {
+		t.Error("Expected the VM to stop while paused")
+	}
+}
+
+func TestPanicIsReturnedAsError(t *testing.T) {
+	client := startServer(t)
+	if err := client.LoadROM([]uint8{0xFF, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RunCycles(1); err == nil || !strings.Contains(err.Error(), "Unsupported op") {
+		t.Errorf("Expected unsupported op to be returned as an error, Actual %v", err)
+	}
+	if registers, _ := client.GetRegisters(); registers.PC != 0x200 {
+		t.Errorf("Expected the VM to stop at the failing op, Actual PC %#x", registers.PC)
+	}
+	// the server should survive
+	if _, err := client.GetRegisters(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResumePanicIsReturnedAsError(t *testing.T) {
+	client := startServer(t)
+	if err := client.LoadROM([]uint8{0xFF, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := client.GetRegisters(); err == nil {
+		t.Error("Expected unsupported op to be returned as an error")
+	}
+	// reported once, and resuming again is possible
+	if _, err := client.GetRegisters(); err != nil {
+		t.Error(err)
+	}
+	if err := client.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := client.Pause(); err == nil {
+		t.Error("Expected unsupported op to be returned by Pause")
+	}
+}
+
+func TestLoadInvalidState(t *testing.T) {
+	client := startServer(t)
+	state, err := client.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, modify := range []func(vm *chip8.VM){
+		func(vm *chip8.VM) { vm.SP = 17 },
+		func(vm *chip8.VM) { vm.PC = 0xFFF },
+		func(vm *chip8.VM) { vm.K = 0x10 },
+	} {
+		invalid := state
+		modify(&invalid)
+		if err := client.LoadState(invalid); err == nil {
+			t.Errorf("Expected an error for SP %d, PC %#x, K %#x", invalid.SP, invalid.PC, invalid.K)
+		}
+	}
+	if registers, _ := client.GetRegisters(); registers.PC != state.PC || registers.SP != state.SP {
+		t.Error("Expected the VM to be unchanged")
+	}
+
+	data, err := json.Marshal(StateMessage{State: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message StateMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatal(err)
+	}
+	short := bytes.Replace(data, []byte(`"Stack":[0,`), []byte(`"Stack":[`), 1)
+	if err := json.Unmarshal(short, &message); err == nil {
+		t.Error("Expected an error for a short stack")
+	}
+}
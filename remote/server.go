@@ -0,0 +1,459 @@
+/*
+Package remote exposes a CHIP-8 VM over JSON-RPC for driving the emulator from
+test scripts.
+
+The protocol is JSON-RPC 1.0 as implemented by net/rpc/jsonrpc: one JSON
+object per request on a TCP or Unix socket, of the form
+
+	{"method": "Chip8.Run", "params": [{"Frames": 60}], "id": 1}
+
+answered by
+
+	{"id": 1, "result": {...}, "error": null}
+
+Byte slices (ROM, Data, PNG) are base64 encoded strings. The methods are:
+
+	Chip8.LoadROM      {ROM}                  -> {}
+	Chip8.Reset        {}                     -> {}
+	Chip8.Run          {Cycles, Frames}       -> {}
+	Chip8.Resume       {}                     -> {}
+	Chip8.Pause        {}                     -> {}
+	Chip8.SetKeys      {Keys: [16]bool}       -> {}
+	Chip8.GetRegisters {}                     -> Registers
+	Chip8.SetRegisters Registers              -> {}
+	Chip8.ReadMemory   {Address, Length}      -> {Data}
+	Chip8.WriteMemory  {Address, Data}        -> {}
+	Chip8.Screenshot   {Scale}                -> {PNG}
+	Chip8.SaveState    {}                     -> {State}
+	Chip8.LoadState    {State}                -> {}
+
+Run executes Cycles VM steps followed by Frames frames, where a frame is one
+timer tick and CPUFrequencyHz/60 steps. Resume runs the VM in real time until
+Pause. A VM error, such as an unsupported op, is returned as an error, the VM
+stopping before the failing op. An error while resumed stops the VM and
+is returned by the next Pause or GetRegisters. LoadState rejects states the VM
+could not run, such as an SP beyond the stack.
+*/
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+const (
+	frameRateHz = 60
+	memorySize  = len(chip8.VM{}.Memory)
+	maxROMSize  = memorySize - 0x200
+)
+
+type Options struct {
+	CPUFrequencyHz   int
+	TimerFrequencyHz int
+}
+
+type Empty struct{}
+
+type LoadROMArgs struct {
+	ROM []uint8
+}
+
+type RunArgs struct {
+	Cycles int
+	Frames int
+}
+
+type SetKeysArgs struct {
+	Keys [16]bool
+}
+
+type Registers struct {
+	V                    [16]uint8
+	I                    uint16
+	PC                   uint16
+	SP                   uint8
+	DT                   uint8
+	ST                   uint8
+	Stack                [16]uint16
+	IsWaitingForKeyPress bool
+	IsWaitingForDisplay  bool
+}
+
+type ReadMemoryArgs struct {
+	Address uint16
+	Length  int
+}
+
+type MemoryReply struct {
+	Data []uint8
+}
+
+type WriteMemoryArgs struct {
+	Address uint16
+	Data    []uint8
+}
+
+type ScreenshotArgs struct {
+	Scale int
+}
+
+type ScreenshotReply struct {
+	PNG []uint8
+}
+
+type StateMessage struct {
+	State chip8.VM
+}
+
+// Service is the RPC receiver registered as "Chip8"
+type Service struct {
+	mu      sync.Mutex
+	opts    Options
+	rom     []uint8
+	vm      *chip8.VM
+	random  chip8.Random
+	pause   chan struct{}
+	stopped chan struct{}
+	// resumeErr is the panic that stopped the VM while resumed, until reported
+	resumeErr error
+	// frames, cpuCycles and timerCycles count emulated time since reset
+	frames      int
+	cpuCycles   int
+	timerCycles int
+}
+
+type mathRandom struct{}
+
+func (mathRandom) Next() uint8 {
+	return uint8(rand.Uint32())
+}
+
+func NewService(opts Options) *Service {
+	s := &Service{opts: opts, random: mathRandom{}}
+	s.reset(nil)
+	return s
+}
+
+func (s *Service) reset(rom []uint8) {
+	s.rom = rom
+	s.vm = chip8.New(rom)
+	s.vm.SetRandom(s.random)
+	s.frames, s.cpuCycles, s.timerCycles = 0, 0, 0
+	s.resumeErr = nil
+}
+
+// takeResumeError returns and clears the error that stopped a resumed VM,
+// with the VM locked
+func (s *Service) takeResumeError() error {
+	err := s.resumeErr
+	s.resumeErr = nil
+	return err
+}
+
+// guard runs f with the VM locked
+func (s *Service) guard(f func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return f()
+}
+
+func (s *Service) runFrame() error {
+	s.frames++
+	for ; s.timerCycles < s.frames*s.opts.TimerFrequencyHz/frameRateHz; s.timerCycles++ {
+		s.vm.TickTimers()
+	}
+	for ; s.cpuCycles < s.frames*s.opts.CPUFrequencyHz/frameRateHz; s.cpuCycles++ {
+		if err := s.vm.TryStep(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) LoadROM(args LoadROMArgs, reply *Empty) error {
+	if len(args.ROM) > maxROMSize {
+		return fmt.Errorf("Not enough memory to fit ROM of size %d bytes", len(args.ROM))
+	}
+	return s.guard(func() error {
+		s.reset(args.ROM)
+		return nil
+	})
+}
+
+func (s *Service) Reset(args Empty, reply *Empty) error {
+	return s.guard(func() error {
+		s.reset(s.rom)
+		return nil
+	})
+}
+
+func (s *Service) Run(args RunArgs, reply *Empty) error {
+	if args.Cycles < 0 || args.Frames < 0 {
+		return errors.New("Cycles and Frames must not be negative")
+	}
+	return s.guard(func() error {
+		for i := 0; i < args.Cycles; i++ {
+			if err := s.vm.TryStep(); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < args.Frames; i++ {
+			if err := s.runFrame(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Service) Resume(args Empty, reply *Empty) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pause != nil {
+		return nil
+	}
+	pause, stopped := make(chan struct{}), make(chan struct{})
+	s.pause, s.stopped = pause, stopped
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second / frameRateHz)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pause:
+				return
+			case <-ticker.C:
+				if err := s.guard(s.runFrame); err != nil {
+					s.mu.Lock()
+					s.resumeErr = fmt.Errorf("Stopped running: %v", err)
+					if s.pause == pause {
+						s.pause, s.stopped = nil, nil
+					}
+					s.mu.Unlock()
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Service) Pause(args Empty, reply *Empty) error {
+	s.mu.Lock()
+	pause, stopped := s.pause, s.stopped
+	s.pause, s.stopped = nil, nil
+	s.mu.Unlock()
+	if pause != nil {
+		close(pause)
+		<-stopped
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.takeResumeError()
+}
+
+func (s *Service) SetKeys(args SetKeysArgs, reply *Empty) error {
+	return s.guard(func() error {
+		for key := uint8(0); key <= 0xF; key++ {
+			switch down := args.Keys[key]; {
+			case down && !s.vm.Keys[key]:
+				s.vm.SetKeyDown(key)
+			case !down && s.vm.Keys[key]:
+				s.vm.SetKeyUp(key)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Service) GetRegisters(args Empty, reply *Registers) error {
+	return s.guard(func() error {
+		if err := s.takeResumeError(); err != nil {
+			return err
+		}
+		*reply = Registers{
+			V:                    s.vm.V,
+			I:                    s.vm.I,
+			PC:                   s.vm.PC,
+			SP:                   s.vm.SP,
+			DT:                   s.vm.DT,
+			ST:                   s.vm.ST,
+			Stack:                s.vm.Stack,
+			IsWaitingForKeyPress: s.vm.IsWaitingForKeyPress,
+			IsWaitingForDisplay:  s.vm.IsWaitingForDisplay,
+		}
+		return nil
+	})
+}
+
+func (s *Service) SetRegisters(args Registers, reply *Empty) error {
+	if int(args.PC) >= memorySize-1 {
+		return fmt.Errorf("PC out of range: %#x", args.PC)
+	}
+	if int(args.SP) > len(args.Stack) {
+		return fmt.Errorf("SP out of range: %d", args.SP)
+	}
+	return s.guard(func() error {
+		s.vm.V = args.V
+		s.vm.I = args.I
+		s.vm.PC = args.PC
+		s.vm.SP = args.SP
+		s.vm.DT = args.DT
+		s.vm.ST = args.ST
+		s.vm.Stack = args.Stack
+		s.vm.IsWaitingForKeyPress = args.IsWaitingForKeyPress
+		s.vm.IsWaitingForDisplay = args.IsWaitingForDisplay
+		return nil
+	})
+}
+
+func checkRange(address uint16, length int) error {
+	if length < 0 || int(address)+length > memorySize {
+		return fmt.Errorf("Memory range out of bounds: %#x + %d", address, length)
+	}
+	return nil
+}
+
+func (s *Service) ReadMemory(args ReadMemoryArgs, reply *MemoryReply) error {
+	if err := checkRange(args.Address, args.Length); err != nil {
+		return err
+	}
+	return s.guard(func() error {
+		reply.Data = append([]uint8(nil), s.vm.Memory[args.Address:int(args.Address)+args.Length]...)
+		return nil
+	})
+}
+
+func (s *Service) WriteMemory(args WriteMemoryArgs, reply *Empty) error {
+	if err := checkRange(args.Address, len(args.Data)); err != nil {
+		return err
+	}
+	return s.guard(func() error {
+		copy(s.vm.Memory[args.Address:], args.Data)
+		return nil
+	})
+}
+
+func screenshot(videoMemory [chip8.ScreenHeight]uint64, scale int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, chip8.ScreenWidth*scale, chip8.ScreenHeight*scale))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		scanLine := videoMemory[y/scale]
+		for x := 0; x < img.Rect.Dx(); x++ {
+			if scanLine&(0x8000000000000000>>uint(x/scale)) > 0 {
+				img.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+	return img
+}
+
+func (s *Service) Screenshot(args ScreenshotArgs, reply *ScreenshotReply) error {
+	if args.Scale < 1 {
+		args.Scale = 1
+	}
+	var videoMemory [chip8.ScreenHeight]uint64
+	s.guard(func() error {
+		videoMemory = s.vm.VideoMemory
+		return nil
+	})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, screenshot(videoMemory, args.Scale)); err != nil {
+		return err
+	}
+	reply.PNG = buf.Bytes()
+	return nil
+}
+
+func (s *Service) SaveState(args Empty, reply *StateMessage) error {
+	return s.guard(func() error {
+		reply.State = *s.vm
+		return nil
+	})
+}
+
+func (s *Service) LoadState(args StateMessage, reply *Empty) error {
+	if err := checkState(&args.State); err != nil {
+		return err
+	}
+	return s.guard(func() error {
+		vm := args.State
+		vm.SetRandom(s.random)
+		s.vm = &vm
+		return nil
+	})
+}
+
+// checkState returns an error for a VM state that would fail on the next step
+// for reasons other than its op
+func checkState(vm *chip8.VM) error {
+	if int(vm.PC) >= memorySize-1 {
+		return fmt.Errorf("PC out of range: %#x", vm.PC)
+	}
+	if int(vm.SP) > len(vm.Stack) {
+		return fmt.Errorf("SP out of range: %d", vm.SP)
+	}
+	if vm.K > 0xF {
+		return fmt.Errorf("K out of range: %#x", vm.K)
+	}
+	return nil
+}
+
+// UnmarshalJSON rejects states with arrays of the wrong length, which
+// encoding/json would otherwise truncate or fill with zeros
+func (m *StateMessage) UnmarshalJSON(data []byte) error {
+	var arrays struct {
+		State struct {
+			V, Stack, Keys, Memory, VideoMemory []json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(data, &arrays); err != nil {
+		return err
+	}
+	var vm chip8.VM
+	for _, array := range []struct {
+		name     string
+		actual   []json.RawMessage
+		expected int
+	}{
+		{"V", arrays.State.V, len(vm.V)},
+		{"Stack", arrays.State.Stack, len(vm.Stack)},
+		{"Keys", arrays.State.Keys, len(vm.Keys)},
+		{"Memory", arrays.State.Memory, len(vm.Memory)},
+		{"VideoMemory", arrays.State.VideoMemory, len(vm.VideoMemory)},
+	} {
+		if len(array.actual) != array.expected {
+			return fmt.Errorf("%s has length %d instead of %d", array.name, len(array.actual), array.expected)
+		}
+	}
+	type plain StateMessage
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+// Serve answers JSON-RPC requests on every connection accepted from l until
+// l is closed
+func Serve(l net.Listener, service *Service) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Chip8", service); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
@@ -0,0 +1,78 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+)
+
+var (
+	background  = color.RGBA{0x20, 0x20, 0x20, 0xFF}
+	unlitPixel  = color.RGBA{0x00, 0x00, 0x00, 0xFF}
+	litPixel    = color.RGBA{0xC0, 0xC0, 0xC0, 0xFF}
+	unlitSprite = color.RGBA{0x00, 0x30, 0x00, 0xFF}
+	litSprite   = color.RGBA{0x40, 0xFF, 0x40, 0xFF}
+)
+
+type SheetOptions struct {
+	// RowsPerColumn is the number of bytes in each column of the sheet
+	RowsPerColumn int
+	// Scale is the size of a pixel in the sheet
+	Scale int
+}
+
+var DefaultSheetOptions = SheetOptions{
+	RowsPerColumn: 64,
+	Scale:         4,
+}
+
+// Render renders a memory range as a sheet of 8 pixel wide columns, left to
+// right, with a one pixel gap between them. Highlighted regions are drawn in
+// green. Options that are not positive take their DefaultSheetOptions value.
+func Render(memory []uint8, start, end int, highlights []Region, opts SheetOptions) *image.RGBA {
+	if opts.RowsPerColumn <= 0 {
+		opts.RowsPerColumn = DefaultSheetOptions.RowsPerColumn
+	}
+	if opts.Scale <= 0 {
+		opts.Scale = DefaultSheetOptions.Scale
+	}
+	if end > len(memory) {
+		end = len(memory)
+	}
+	n := end - start
+	if n <= 0 {
+		return image.NewRGBA(image.Rectangle{})
+	}
+	columns := (n + opts.RowsPerColumn - 1) / opts.RowsPerColumn
+	rows := opts.RowsPerColumn
+	if columns <= 1 {
+		rows = n
+	}
+	img := image.NewRGBA(image.Rect(0, 0, (columns*9-1)*opts.Scale, rows*opts.Scale))
+	fill(img, img.Rect, background)
+	for i := 0; i < n; i++ {
+		address := start + i
+		lit, unlit := litPixel, unlitPixel
+		if isHighlighted(highlights, address) {
+			lit, unlit = litSprite, unlitSprite
+		}
+		x0, y := i/opts.RowsPerColumn*9, i%opts.RowsPerColumn
+		for bit := 0; bit < 8; bit++ {
+			c := unlit
+			if memory[address]>>uint(7-bit)&1 == 1 {
+				c = lit
+			}
+			fill(img, image.Rect(
+				(x0+bit)*opts.Scale, y*opts.Scale,
+				(x0+bit+1)*opts.Scale, (y+1)*opts.Scale), c)
+		}
+	}
+	return img
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
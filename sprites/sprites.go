@@ -0,0 +1,128 @@
+/*
+Package sprites extracts sprite art from CHIP-8 memory.
+
+Sprites are 8 pixels wide with one byte per row, most significant bit
+leftmost, so any memory range can be viewed as a column of 8 pixel wide
+bitmaps. A Recorder finds the regions a running VM draws with DRW, which can
+then be highlighted in a PNG sheet rendered by Render, printed as text by
+WriteText, or written back as assembler DB directives by WriteDB.
+*/
+package sprites
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/odsod/chip8"
+)
+
+const memorySize = len(chip8.VM{}.Memory)
+
+// Region is a sprite of Height rows at Address
+type Region struct {
+	Address uint16
+	Height  uint8
+}
+
+// End is the address after the last row of the region
+func (r Region) End() int {
+	return int(r.Address) + int(r.Height)
+}
+
+// Contains reports whether an address is a row of the region
+func (r Region) Contains(address int) bool {
+	return address >= int(r.Address) && address < r.End()
+}
+
+// Recorder is a chip8.Tracer recording the sprite sources of DRW
+type Recorder struct {
+	vm      *chip8.VM
+	heights map[uint16]uint8
+}
+
+// NewRecorder returns a recorder for a VM, which must have it as tracer
+func NewRecorder(vm *chip8.VM) *Recorder {
+	return &Recorder{vm: vm, heights: make(map[uint16]uint8)}
+}
+
+func (r *Recorder) Trace(record *chip8.TraceRecord) {
+	if record.Op&0xF000 != 0xD000 {
+		return
+	}
+	// DRW does not change I, so it still holds the source of the sprite
+	height := uint8(record.Op & 0xF)
+	if height > r.heights[r.vm.I] {
+		r.heights[r.vm.I] = height
+	}
+}
+
+// Regions returns the recorded regions in address order, with the largest
+// height drawn from each address
+func (r *Recorder) Regions() []Region {
+	return Merge(r.heights)
+}
+
+// Merge returns regions for a map of heights by address in address order
+func Merge(heights map[uint16]uint8) []Region {
+	regions := make([]Region, 0, len(heights))
+	for address, height := range heights {
+		regions = append(regions, Region{address, height})
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].Address < regions[j].Address })
+	return regions
+}
+
+func isHighlighted(regions []Region, address int) bool {
+	for _, r := range regions {
+		if r.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+func row(b uint8) string {
+	var s strings.Builder
+	for bit := 7; bit >= 0; bit-- {
+		if b>>uint(bit)&1 == 1 {
+			s.WriteByte('#')
+		} else {
+			s.WriteByte('.')
+		}
+	}
+	return s.String()
+}
+
+// WriteText writes a memory range as text, one row per byte, marking the rows
+// of highlighted regions with a *
+func WriteText(w io.Writer, memory []uint8, start, end int, highlights []Region) error {
+	var b strings.Builder
+	for address := start; address < end && address < len(memory); address++ {
+		mark := ' '
+		if isHighlighted(highlights, address) {
+			mark = '*'
+		}
+		fmt.Fprintf(&b, "0x%03X %c %s  0x%02X\n", address, mark, row(memory[address]), memory[address])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteDB writes regions as assembler DB directives, each labelled by its
+// address and with its bitmap in comments
+func WriteDB(w io.Writer, memory []uint8, regions []Region) error {
+	var b strings.Builder
+	for i, r := range regions {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "sprite_%03X:\n", r.Address)
+		for address := int(r.Address); address < r.End() && address < len(memory); address++ {
+			fmt.Fprintf(&b, "  DB 0x%02X  ; %s\n", memory[address], row(memory[address]))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,96 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+func TestRecorder(t *testing.T) {
+	vm := chip8.New([]uint8{
+		0xA2, 0x0A, // 0x200 LD I, 0x20A
+		0xD0, 0x12, // 0x202 DRW V0, V1, 2
+		0xD0, 0x11, // 0x204 DRW V0, V1, 1
+		0xF0, 0x29, // 0x206 LD F, V0
+		0xD0, 0x15, // 0x208 DRW V0, V1, 5
+		0x81, 0x7E, // 0x20A sprite
+	})
+	r := NewRecorder(vm)
+	vm.SetTracer(r)
+	for i := 0; i < 5; i++ {
+		vm.Step()
+	}
+	if expected := []Region{{0x000, 5}, {0x20A, 2}}; !reflect.DeepEqual(r.Regions(), expected) {
+		t.Errorf("Expected %v, Actual %v", expected, r.Regions())
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	memory := []uint8{0x81, 0x7E, 0x00}
+	if err := WriteText(&buf, memory, 0, 5, []Region{{1, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"0x000   #......#  0x81\n" +
+		"0x001 * .######.  0x7E\n" +
+		"0x002   ........  0x00\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteDB(t *testing.T) {
+	var buf bytes.Buffer
+	memory := []uint8{0x81, 0x7E, 0x00, 0xF0}
+	if err := WriteDB(&buf, memory, []Region{{0, 2}, {3, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"sprite_000:\n" +
+		"  DB 0x81  ; #......#\n" +
+		"  DB 0x7E  ; .######.\n" +
+		"\n" +
+		"sprite_003:\n" +
+		"  DB 0xF0  ; ####....\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	memory := []uint8{0x80, 0x01, 0x00}
+	img := Render(memory, 0, 3, []Region{{1, 1}}, SheetOptions{RowsPerColumn: 2, Scale: 2})
+	if expected := (17 * 2); img.Bounds().Dx() != expected || img.Bounds().Dy() != 4 {
+		t.Fatalf("Unexpected bounds %v", img.Bounds())
+	}
+	for _, tt := range []struct {
+		x, y     int
+		expected color.RGBA
+	}{
+		{0, 0, litPixel},
+		{3, 1, unlitPixel},
+		{15, 3, litSprite},
+		{0, 3, unlitSprite},
+		{16, 0, background},
+		{18, 0, unlitPixel},
+		{18, 2, background},
+	} {
+		if actual := img.RGBAAt(tt.x, tt.y); actual != tt.expected {
+			t.Errorf("(%d, %d): Expected %v, Actual %v", tt.x, tt.y, tt.expected, actual)
+		}
+	}
+}
+
+func TestRenderDefaultOptions(t *testing.T) {
+	memory := make([]uint8, 100)
+	img := Render(memory, 0, len(memory), nil, SheetOptions{})
+	// two columns of 64 rows at scale 4
+	if expected := image.Rect(0, 0, 17*4, 64*4); img.Bounds() != expected {
+		t.Errorf("Expected bounds %v, Actual %v", expected, img.Bounds())
+	}
+}
@@ -0,0 +1,85 @@
+package chip8
+
+const (
+	// VIPCyclesPerSecond is the number of machine cycles per second of the
+	// COSMAC VIP's CDP1802, 8 clock periods at 1.7609 MHz
+	VIPCyclesPerSecond = 1760900 / 8
+
+	// VIPFrameRateHz is the rate of the display interrupt, which ticks the
+	// timers
+	VIPFrameRateHz = 60
+
+	// VIPCyclesPerFrame is the number of machine cycles between two display
+	// interrupts
+	VIPCyclesPerFrame = VIPCyclesPerSecond / VIPFrameRateHz
+)
+
+/*
+VIPTiming runs a VM a 60 Hz frame at a time with the timing of the original
+COSMAC VIP interpreter, instead of at a fixed number of instructions per
+second:
+
+  - each instruction costs its Instruction.Cycles out of VIPCyclesPerFrame
+    machine cycles per frame, any overrun being taken from the next frame
+  - the timers tick once at the start of each frame, on the display interrupt
+  - DRW waits for the display interrupt, so it is executed first in a frame
+    and at most one sprite is drawn per frame
+*/
+type VIPTiming struct {
+	vm *VM
+	// cycles are the machine cycles left of the current frame, negative when
+	// the previous frame overran
+	cycles int
+}
+
+func NewVIPTiming(vm *VM) *VIPTiming {
+	return &VIPTiming{vm: vm}
+}
+
+// RunFrame ticks the timers and runs the VM until the frame's machine cycles
+// are spent, a DRW waits for the next frame, or the VM waits for a key
+// press. It returns the number of executed instructions, or an error if an
+// instruction cannot be executed. A frame that fails at its first instruction
+// neither ticks the timers nor spends cycles, so calling RunFrame again on a
+// failed VM does not change it.
+func (t *VIPTiming) RunFrame() (steps int, err error) {
+	if !t.vm.IsWaitingForKeyPress {
+		if _, err := t.vm.check(); err != nil {
+			return 0, err
+		}
+	}
+	t.vm.TickTimers()
+	t.cycles += VIPCyclesPerFrame
+	for t.cycles > 0 && !t.vm.IsWaitingForKeyPress && !t.vm.IsWaitingForDisplay {
+		op, ok := t.vm.peek()
+		in := PlatformCHIP8.Lookup(op)
+		if !ok || in == nil {
+			// let TryStep report the error
+			t.cycles = 0
+			return steps, t.vm.TryStep()
+		}
+		if in.Mnemonic == "DRW" && steps > 0 {
+			// wait for the display interrupt
+			break
+		}
+		if err := t.vm.TryStep(); err != nil {
+			t.cycles = 0
+			return steps, err
+		}
+		t.cycles -= in.Cycles
+		steps++
+	}
+	if t.cycles > 0 {
+		// idle cycles waiting for an interrupt or key press are not kept
+		t.cycles = 0
+	}
+	return steps, nil
+}
+
+// peek returns the op at PC, and false if PC is outside memory
+func (vm *VM) peek() (EncodedOp, bool) {
+	if int(vm.PC)+1 >= len(vm.Memory) {
+		return 0, false
+	}
+	return EncodedOp(uint16(vm.Memory[vm.PC])<<8 | uint16(vm.Memory[vm.PC+1])), true
+}
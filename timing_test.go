@@ -0,0 +1,94 @@
+package chip8
+
+import "testing"
+
+func TestVIPTiming(t *testing.T) {
+	vm := New([]uint8{
+		0x60, 0x01, // 0x200 LD V0, 0x01
+		0x70, 0x01, // 0x202 ADD V0, 0x01
+		0x12, 0x02, // 0x204 JP 0x202
+	})
+	vm.DT = 10
+	timing := NewVIPTiming(vm)
+	steps, err := timing.RunFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// LD costs 6, then each loop iteration of ADD and JP costs 10 + 23
+	if expected := 1 + 2*((VIPCyclesPerFrame-6+32)/33); steps < expected-1 || steps > expected {
+		t.Errorf("Expected about %d steps, Actual %d", expected, steps)
+	}
+	if vm.DT != 9 {
+		t.Errorf("Expected the timers to tick once per frame, DT: %d", vm.DT)
+	}
+	total := steps
+	for i := 0; i < 59; i++ {
+		steps, err := timing.RunFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += steps
+	}
+	// one second of frames spends one second of cycles, give or take the
+	// overrun of the last instruction
+	cycles := 6 + (total-1)/2*33
+	if cycles < VIPCyclesPerSecond-33 || cycles > VIPCyclesPerSecond+33 {
+		t.Errorf("Expected %d cycles per second, Actual %d", VIPCyclesPerSecond, cycles)
+	}
+}
+
+func TestVIPTiming_DisplayWait(t *testing.T) {
+	vm := New([]uint8{
+		0x60, 0x01, // 0x200 LD V0, 0x01
+		0xD0, 0x01, // 0x202 DRW V0, V0, 1
+		0xD0, 0x01, // 0x204 DRW V0, V0, 1
+		0x12, 0x06, // 0x206 JP 0x206
+	})
+	timing := NewVIPTiming(vm)
+	for i, expected := range []struct {
+		steps int
+		pc    uint16
+	}{
+		{1, 0x202}, // LD, then the first DRW waits for the next frame
+		{1, 0x204}, // the first DRW, then the second one waits
+	} {
+		steps, err := timing.RunFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if steps != expected.steps || vm.PC != expected.pc {
+			t.Errorf("Frame %d: Expected %d steps to 0x%03X, Actual %d steps to 0x%03X", i, expected.steps, expected.pc, steps, vm.PC)
+		}
+	}
+	if steps, _ := timing.RunFrame(); steps < 2 || vm.PC != 0x206 {
+		t.Errorf("Expected the second DRW and the loop to run, Actual %d steps to 0x%03X", steps, vm.PC)
+	}
+}
+
+func TestVIPTiming_Error(t *testing.T) {
+	vm := New([]uint8{0x00, 0xEE})
+	if _, err := NewVIPTiming(vm).RunFrame(); err == nil {
+		t.Errorf("Expected an error for RET with an empty stack")
+	}
+}
+
+func TestVIPTiming_ErrorDoesNotDrift(t *testing.T) {
+	vm := New([]uint8{
+		0x60, 0x05, // 0x200 LD V0, 5
+		0xF0, 0x15, // 0x202 LD DT, V0
+		0x00, 0xEE, // 0x204 RET
+	})
+	timing := NewVIPTiming(vm)
+	if _, err := timing.RunFrame(); err == nil {
+		t.Fatal("Expected an error for RET with an empty stack")
+	}
+	cycles, dt := timing.cycles, vm.DT
+	for i := 0; i < 2; i++ {
+		if _, err := timing.RunFrame(); err == nil {
+			t.Fatal("Expected the error again")
+		}
+		if timing.cycles != cycles || vm.DT != dt {
+			t.Errorf("run %d: Expected cycles %d and DT %d, Actual %d and %d", i, cycles, dt, timing.cycles, vm.DT)
+		}
+	}
+}
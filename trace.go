@@ -0,0 +1,119 @@
+package chip8
+
+import "fmt"
+
+// Tracer receives a record of every instruction executed by VM.Step
+type Tracer interface {
+	Trace(record *TraceRecord)
+}
+
+// Register identifies a register in a RegisterChange
+type Register uint8
+
+const (
+	// RegisterV0 - RegisterVF are V0 - VF, i.e. RegisterV0 + x is Vx
+	RegisterV0 Register = 0x00
+	RegisterVF Register = 0x0F
+	RegisterI  Register = 0x10
+	RegisterSP Register = 0x11
+	RegisterDT Register = 0x12
+	RegisterST Register = 0x13
+)
+
+func (r Register) String() string {
+	switch {
+	case r <= RegisterVF:
+		return fmt.Sprintf("V%X", uint8(r))
+	case r == RegisterI:
+		return "I"
+	case r == RegisterSP:
+		return "SP"
+	case r == RegisterDT:
+		return "DT"
+	case r == RegisterST:
+		return "ST"
+	}
+	return fmt.Sprintf("Register(%d)", uint8(r))
+}
+
+type RegisterChange struct {
+	Register Register
+	Old, New uint16
+}
+
+type MemoryWrite struct {
+	Address  uint16
+	Old, New uint8
+}
+
+// TraceRecord describes a single executed instruction. The PC register is not
+// part of the changes, the PC of the next record shows the control flow.
+type TraceRecord struct {
+	// Cycle is the number of instructions executed before this one
+	Cycle        uint64
+	PC           uint16
+	Op           EncodedOp
+	Registers    []RegisterChange
+	MemoryWrites []MemoryWrite
+}
+
+// tracing holds the state used to compute trace records
+type tracing struct {
+	tracer Tracer
+	cycle  uint64
+	memory [4096]uint8
+	record TraceRecord
+}
+
+// SetTracer makes Step report every executed instruction to tracer, nil turns
+// tracing off. The record passed to the tracer is reused between calls.
+func (vm *VM) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		vm.tracing = nil
+		return
+	}
+	vm.tracing = &tracing{tracer: tracer}
+}
+
+func (vm *VM) registerValues() [RegisterST + 1]uint16 {
+	var result [RegisterST + 1]uint16
+	for i, v := range vm.V {
+		result[RegisterV0+Register(i)] = uint16(v)
+	}
+	result[RegisterI] = vm.I
+	result[RegisterSP] = uint16(vm.SP)
+	result[RegisterDT] = uint16(vm.DT)
+	result[RegisterST] = uint16(vm.ST)
+	return result
+}
+
+func (vm *VM) traceStep() {
+	t := vm.tracing
+	pc := vm.PC
+	before := vm.registerValues()
+	t.memory = vm.Memory
+	op := vm.fetch()
+	op.decode().execute(vm)
+	after := vm.registerValues()
+
+	record := &t.record
+	record.Cycle = t.cycle
+	record.PC = pc
+	record.Op = op
+	record.Registers = record.Registers[:0]
+	for r := range before {
+		if before[r] != after[r] {
+			record.Registers = append(record.Registers, RegisterChange{Register(r), before[r], after[r]})
+		}
+	}
+	record.MemoryWrites = record.MemoryWrites[:0]
+	if t.memory != vm.Memory {
+		for address := range t.memory {
+			if t.memory[address] != vm.Memory[address] {
+				record.MemoryWrites = append(record.MemoryWrites, MemoryWrite{uint16(address), t.memory[address], vm.Memory[address]})
+			}
+		}
+	}
+	t.cycle++
+	t.tracer.Trace(record)
+}
@@ -0,0 +1,198 @@
+package trace
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/odsod/chip8"
+)
+
+// Divergence describes the first instruction at which two traces differ
+type Divergence struct {
+	// A and B are the differing records, one of them nil if its trace ended
+	A, B *chip8.TraceRecord
+
+	// Context are the records preceding the divergence, common to both traces
+	Context []*chip8.TraceRecord
+
+	// Registers are the register values before the divergence, reconstructed
+	// from the register changes of the trace starting from a zeroed VM
+	Registers [chip8.RegisterST + 1]uint16
+
+	// Memory are the values written to memory before the divergence
+	Memory map[uint16]uint8
+}
+
+type DiffOptions struct {
+	// Context is the number of preceding records to keep
+	Context int
+
+	// InstructionsOnly compares only PC and op, for traces without changes
+	InstructionsOnly bool
+}
+
+// Diff aligns two traces by cycle and returns the first divergence, or nil
+// when the traces agree. Records present in only one of the traces, e.g. due
+// to a cycle window, are skipped.
+func Diff(a, b Reader, opts DiffOptions) (*Divergence, error) {
+	d := &Divergence{Memory: make(map[uint16]uint8)}
+	ra, err := read(a)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := read(b)
+	if err != nil {
+		return nil, err
+	}
+	for ra != nil && rb != nil {
+		switch {
+		case ra.Cycle < rb.Cycle:
+			if ra, err = read(a); err != nil {
+				return nil, err
+			}
+			continue
+		case rb.Cycle < ra.Cycle:
+			if rb, err = read(b); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if ra.PC != rb.PC || ra.Op != rb.Op || (!opts.InstructionsOnly && !equal(ra, rb)) {
+			d.A, d.B = ra, rb
+			return d, nil
+		}
+		for _, change := range ra.Registers {
+			d.Registers[change.Register] = change.New
+		}
+		for _, write := range ra.MemoryWrites {
+			d.Memory[write.Address] = write.New
+		}
+		if opts.Context > 0 {
+			if len(d.Context) == opts.Context {
+				d.Context = d.Context[1:]
+			}
+			d.Context = append(d.Context, ra)
+		}
+		if ra, err = read(a); err != nil {
+			return nil, err
+		}
+		if rb, err = read(b); err != nil {
+			return nil, err
+		}
+	}
+	if ra == nil && rb == nil {
+		return nil, nil
+	}
+	d.A, d.B = ra, rb
+	return d, nil
+}
+
+// read returns the next record, or nil at the end of the trace
+func read(r Reader) (*chip8.TraceRecord, error) {
+	record, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return record, err
+}
+
+func formatRecord(record *chip8.TraceRecord) string {
+	if record == nil {
+		return "<end of trace>"
+	}
+	return FormatText(record)
+}
+
+// Report writes a human readable description of the divergence
+func (d *Divergence) Report(w io.Writer, nameA, nameB string) {
+	cycle := uint64(0)
+	if d.A != nil {
+		cycle = d.A.Cycle
+	} else {
+		cycle = d.B.Cycle
+	}
+	fmt.Fprintf(w, "First divergence at cycle %d\n\n", cycle)
+	if len(d.Context) > 0 {
+		fmt.Fprintln(w, "Preceding instructions:")
+		for _, record := range d.Context {
+			fmt.Fprintf(w, "  %s\n", FormatText(record))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%s:\n  %s\n", nameA, formatRecord(d.A))
+	fmt.Fprintf(w, "%s:\n  %s\n\n", nameB, formatRecord(d.B))
+
+	fmt.Fprintln(w, "Registers before divergence:")
+	for r := chip8.RegisterV0; r <= chip8.RegisterST; r++ {
+		fmt.Fprintf(w, "  %-2s=0x%02X", r, d.Registers[r])
+		if r%8 == 7 || r == chip8.RegisterST {
+			fmt.Fprintln(w)
+		}
+	}
+	if d.A != nil && d.B != nil {
+		after := func(record *chip8.TraceRecord) map[chip8.Register]uint16 {
+			result := make(map[chip8.Register]uint16)
+			for _, change := range record.Registers {
+				result[change.Register] = change.New
+			}
+			return result
+		}
+		afterA, afterB := after(d.A), after(d.B)
+		printed := false
+		for r := chip8.RegisterV0; r <= chip8.RegisterST; r++ {
+			va, okA := afterA[r]
+			vb, okB := afterB[r]
+			if !okA {
+				va = d.Registers[r]
+			}
+			if !okB {
+				vb = d.Registers[r]
+			}
+			if va != vb {
+				if !printed {
+					fmt.Fprintln(w, "\nRegisters after divergence:")
+					printed = true
+				}
+				fmt.Fprintf(w, "  %s: %s=0x%02X %s=0x%02X\n", r, nameA, va, nameB, vb)
+			}
+		}
+		writesA, writesB := make(map[uint16]uint8), make(map[uint16]uint8)
+		for _, write := range d.A.MemoryWrites {
+			writesA[write.Address] = write.New
+		}
+		for _, write := range d.B.MemoryWrites {
+			writesB[write.Address] = write.New
+		}
+		var addresses []int
+		for address := range writesA {
+			addresses = append(addresses, int(address))
+		}
+		for address := range writesB {
+			if _, ok := writesA[address]; !ok {
+				addresses = append(addresses, int(address))
+			}
+		}
+		sort.Ints(addresses)
+		printed = false
+		for _, address := range addresses {
+			va, okA := writesA[uint16(address)]
+			vb, okB := writesB[uint16(address)]
+			if va == vb && okA == okB {
+				continue
+			}
+			if !printed {
+				fmt.Fprintln(w, "\nMemory writes after divergence:")
+				printed = true
+			}
+			fmt.Fprintf(w, "  [0x%03X]: %s=%s %s=%s\n", address, nameA, formatWrite(va, okA), nameB, formatWrite(vb, okB))
+		}
+	}
+}
+
+func formatWrite(value uint8, ok bool) string {
+	if !ok {
+		return "unwritten"
+	}
+	return fmt.Sprintf("0x%02X", value)
+}
@@ -0,0 +1,159 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+var counterROM = []uint8{
+	0x60, 0x00, // 0x200 LD V0, 0
+	0xA3, 0x00, // 0x202 LD I, 0x300
+	0x70, 0x01, // 0x204 ADD V0, 1
+	0xF0, 0x33, // 0x206 LD B, V0
+	0x30, 0x05, // 0x208 SE V0, 5
+	0x12, 0x04, // 0x20A JP 0x204
+	0x12, 0x0C, // 0x20C JP 0x20C
+}
+
+// record runs a ROM and returns its trace in a format
+func record(t *testing.T, rom []uint8, format string, cycles int) *bytes.Buffer {
+	var buf bytes.Buffer
+	sink, err := NewSink(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := chip8.New(rom)
+	vm.SetTracer(sink)
+	for i := 0; i < cycles; i++ {
+		vm.Step()
+	}
+	sink.Flush()
+	return &buf
+}
+
+func TestReadersRoundTrip(t *testing.T) {
+	expected := record(t, counterROM, "text", 30).String()
+	for _, format := range []string{"text", "json", "binary"} {
+		r, err := NewReader(record(t, counterROM, format, 30))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		var actual strings.Builder
+		for _, record := range records {
+			fmt.Fprintln(&actual, FormatText(record))
+		}
+		if actual.String() != expected {
+			t.Errorf("%s: Expected %q, Actual %q", format, expected, actual.String())
+		}
+	}
+}
+
+func TestBinaryReaderUnknownRegister(t *testing.T) {
+	trace := []byte(binaryMagic)
+	trace = append(trace, binaryVersion,
+		0,                      // cycle
+		0x02, 0x00, 0x60, 0x00, // PC and op
+		1,                            // register changes
+		0xFF, 0x00, 0x00, 0x00, 0x01, // register, old and new
+		0) // memory writes
+	r, err := NewReader(bytes.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(); err == nil {
+		t.Error("Expected an error for an unknown register")
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	a, _ := NewReader(record(t, counterROM, "binary", 30))
+	b, _ := NewReader(record(t, counterROM, "json", 30))
+	d, err := Diff(a, b, DiffOptions{Context: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != nil {
+		t.Errorf("Expected no divergence, Actual %+v", d)
+	}
+}
+
+func TestDiffDivergence(t *testing.T) {
+	modified := append([]uint8(nil), counterROM...)
+	modified[9] = 0x03 // SE V0, 3
+	a, _ := NewReader(record(t, counterROM, "text", 30))
+	b, _ := NewReader(record(t, modified, "text", 30))
+	d, err := Diff(a, b, DiffOptions{Context: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil {
+		t.Fatal("Expected a divergence")
+	}
+	if d.A.Cycle != 4 || d.A.Op != 0x3005 || d.B.Op != 0x3003 {
+		t.Errorf("Unexpected divergence at %s / %s", FormatText(d.A), FormatText(d.B))
+	}
+	if len(d.Context) != 2 || d.Registers[chip8.RegisterV0] != 1 || d.Memory[0x302] != 1 {
+		t.Errorf("Unexpected context %+v", d)
+	}
+	var report bytes.Buffer
+	d.Report(&report, "a", "b")
+	if !strings.Contains(report.String(), "First divergence at cycle 4") {
+		t.Errorf("Unexpected report:\n%s", report.String())
+	}
+}
+
+func TestDiffInstructionsOnly(t *testing.T) {
+	// another emulator's log with the instructions only
+	other := "000000 0x200 6000\n000001 0x202 A300\n000002 0x204 7001\n"
+	a, _ := NewReader(record(t, counterROM, "text", 3))
+	b, _ := NewReader(strings.NewReader(other))
+	d, err := Diff(a, b, DiffOptions{InstructionsOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != nil {
+		t.Errorf("Expected no divergence, Actual %s / %s", formatRecord(d.A), formatRecord(d.B))
+	}
+}
+
+func TestDiffTraceEnded(t *testing.T) {
+	a, _ := NewReader(record(t, counterROM, "text", 10))
+	b, _ := NewReader(record(t, counterROM, "text", 5))
+	d, err := Diff(a, b, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil || d.A == nil || d.A.Cycle != 5 || d.B != nil {
+		t.Errorf("Expected divergence at the end of the shorter trace, Actual %+v", d)
+	}
+}
+
+func TestDiffReportChanges(t *testing.T) {
+	a := strings.NewReader("000000 0x200 F033  [0x300]=0x00->0x01\n")
+	b := strings.NewReader("000000 0x200 F033  VF=0x00->0x01 [0x301]=0x00->0x02\n")
+	ra, _ := NewReader(a)
+	rb, _ := NewReader(b)
+	d, err := Diff(ra, rb, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report bytes.Buffer
+	d.Report(&report, "a", "b")
+	for _, expected := range []string{
+		"VF: a=0x00 b=0x01",
+		"[0x300]: a=0x01 b=unwritten",
+		"[0x301]: a=unwritten b=0x02",
+	} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("Expected %q in report:\n%s", expected, report.String())
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/odsod/chip8"
+)
+
+// Filter passes on the records matching all of its conditions to a tracer
+type Filter struct {
+	Tracer chip8.Tracer
+
+	// MinPC and MaxPC is the inclusive PC range to trace
+	MinPC, MaxPC uint16
+
+	// Mnemonics are the op families to trace, e.g. DRW or LD, empty means all
+	Mnemonics []string
+
+	// MinCycle and MaxCycle is the inclusive cycle window to trace
+	MinCycle, MaxCycle uint64
+}
+
+// NewFilter returns a filter passing everything to tracer
+func NewFilter(tracer chip8.Tracer) *Filter {
+	return &Filter{
+		Tracer:   tracer,
+		MaxPC:    0xFFF,
+		MaxCycle: ^uint64(0),
+	}
+}
+
+// Mnemonic returns the op family of an op, e.g. DRW, or DW for unsupported ops
+func Mnemonic(op chip8.EncodedOp) string {
+	if in := chip8.PlatformCHIP8.Lookup(op); in != nil {
+		return in.Mnemonic
+	}
+	return "DW"
+}
+
+func (f *Filter) Trace(record *chip8.TraceRecord) {
+	if record.PC < f.MinPC || record.PC > f.MaxPC {
+		return
+	}
+	if record.Cycle < f.MinCycle || record.Cycle > f.MaxCycle {
+		return
+	}
+	if len(f.Mnemonics) > 0 {
+		mnemonic := Mnemonic(record.Op)
+		found := false
+		for _, m := range f.Mnemonics {
+			if strings.EqualFold(m, mnemonic) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+	f.Tracer.Trace(record)
+}
+
+// parseRange parses "min-max", where either side may be left out
+func parseRange(s string, max uint64) (uint64, uint64, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid range %q, expected min-max", s)
+	}
+	lo, hi := uint64(0), max
+	var err error
+	if parts[0] != "" {
+		if lo, err = strconv.ParseUint(parts[0], 0, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	if parts[1] != "" {
+		if hi, err = strconv.ParseUint(parts[1], 0, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	if lo > hi || hi > max {
+		return 0, 0, fmt.Errorf("Invalid range %q", s)
+	}
+	return lo, hi, nil
+}
+
+// SetPCRange parses a PC range like 0x200-0x2FF
+func (f *Filter) SetPCRange(s string) error {
+	lo, hi, err := parseRange(s, 0xFFF)
+	if err != nil {
+		return err
+	}
+	f.MinPC, f.MaxPC = uint16(lo), uint16(hi)
+	return nil
+}
+
+// SetCycleWindow parses a cycle window like 1000-2000
+func (f *Filter) SetCycleWindow(s string) error {
+	lo, hi, err := parseRange(s, ^uint64(0))
+	if err != nil {
+		return err
+	}
+	f.MinCycle, f.MaxCycle = lo, hi
+	return nil
+}
+
+// SetMnemonics parses a comma separated list of op families like DRW,CALL
+func (f *Filter) SetMnemonics(s string) {
+	f.Mnemonics = nil
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			f.Mnemonics = append(f.Mnemonics, m)
+		}
+	}
+}
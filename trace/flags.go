@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"flag"
+	"os"
+
+	"github.com/odsod/chip8"
+)
+
+// Flags are the command line flags for tracing shared by the commands
+type Flags struct {
+	File   *string
+	Format *string
+	PC     *string
+	Ops    *string
+	Cycles *string
+	file   *os.File
+	sink   Sink
+}
+
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	return &Flags{
+		File:   fs.String("trace", "", "Write an execution trace to this file"),
+		Format: fs.String("traceFormat", "text", "The trace format: text, json or binary"),
+		PC:     fs.String("tracePC", "", "Only trace this PC range, e.g. 0x200-0x2FF"),
+		Ops:    fs.String("traceOps", "", "Only trace these ops, e.g. DRW,CALL,RET"),
+		Cycles: fs.String("traceCycles", "", "Only trace this cycle window, e.g. 1000-2000"),
+	}
+}
+
+// Open creates the trace file and returns the tracer to set on the VM, or nil
+// when tracing is off
+func (f *Flags) Open() (chip8.Tracer, error) {
+	if *f.File == "" {
+		return nil, nil
+	}
+	filter := NewFilter(nil)
+	if *f.PC != "" {
+		if err := filter.SetPCRange(*f.PC); err != nil {
+			return nil, err
+		}
+	}
+	if *f.Cycles != "" {
+		if err := filter.SetCycleWindow(*f.Cycles); err != nil {
+			return nil, err
+		}
+	}
+	filter.SetMnemonics(*f.Ops)
+	file, err := os.Create(*f.File)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := NewSink(*f.Format, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	f.file, f.sink = file, sink
+	filter.Tracer = sink
+	return filter, nil
+}
+
+// Close flushes and closes the trace file
+func (f *Flags) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	if err := f.sink.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
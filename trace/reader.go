@@ -0,0 +1,250 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/odsod/chip8"
+)
+
+// Reader reads records from a trace in any of the formats
+type Reader interface {
+	// Read returns the next record, or io.EOF at the end of the trace
+	Read() (*chip8.TraceRecord, error)
+}
+
+// NewReader detects the format of a trace from its first bytes
+func NewReader(r io.Reader) (Reader, error) {
+	buffered := bufio.NewReader(r)
+	head, err := buffered.Peek(len(binaryMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case string(head) == binaryMagic:
+		buffered.Discard(len(binaryMagic))
+		version, err := buffered.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if version != binaryVersion {
+			return nil, fmt.Errorf("Unsupported binary trace version: %d", version)
+		}
+		return &binaryReader{r: buffered}, nil
+	case len(head) > 0 && head[0] == '{':
+		return &jsonReader{dec: json.NewDecoder(buffered)}, nil
+	}
+	return &textReader{scanner: bufio.NewScanner(buffered)}, nil
+}
+
+// ReadAll reads all remaining records of a trace
+func ReadAll(r Reader) ([]*chip8.TraceRecord, error) {
+	var records []*chip8.TraceRecord
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+var registersByName = func() map[string]chip8.Register {
+	result := make(map[string]chip8.Register)
+	for r := chip8.RegisterV0; r <= chip8.RegisterST; r++ {
+		result[r.String()] = r
+	}
+	return result
+}()
+
+func parseRegister(name string) (chip8.Register, error) {
+	if r, ok := registersByName[strings.ToUpper(name)]; ok {
+		return r, nil
+	}
+	return 0, fmt.Errorf("Unknown register: %s", name)
+}
+
+type textReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func parseChange(s string) (old, new uint64, err error) {
+	parts := strings.SplitN(s, "->", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid change: %s", s)
+	}
+	if old, err = strconv.ParseUint(parts[0], 0, 16); err != nil {
+		return
+	}
+	new, err = strconv.ParseUint(parts[1], 0, 16)
+	return
+}
+
+func parseTextRecord(line string) (*chip8.TraceRecord, error) {
+	instruction, changes := line, ""
+	if i := strings.Index(line, "  "); i >= 0 {
+		instruction, changes = line[:i], line[i+2:]
+	}
+	fields := strings.Fields(instruction)
+	if len(fields) < 3 {
+		return nil, errors.New("expected cycle, PC and op")
+	}
+	cycle, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := strconv.ParseUint(fields[1], 0, 16)
+	if err != nil {
+		return nil, err
+	}
+	op, err := strconv.ParseUint(fields[2], 16, 16)
+	if err != nil {
+		return nil, err
+	}
+	record := &chip8.TraceRecord{Cycle: cycle, PC: uint16(pc), Op: chip8.EncodedOp(op)}
+	for _, change := range strings.Fields(changes) {
+		parts := strings.SplitN(change, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid change: %s", change)
+		}
+		old, new, err := parseChange(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(parts[0], "[") && strings.HasSuffix(parts[0], "]") {
+			address, err := strconv.ParseUint(parts[0][1:len(parts[0])-1], 0, 16)
+			if err != nil {
+				return nil, err
+			}
+			record.MemoryWrites = append(record.MemoryWrites, chip8.MemoryWrite{Address: uint16(address), Old: uint8(old), New: uint8(new)})
+			continue
+		}
+		register, err := parseRegister(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		record.Registers = append(record.Registers, chip8.RegisterChange{Register: register, Old: uint16(old), New: uint16(new)})
+	}
+	return record, nil
+}
+
+func (r *textReader) Read() (*chip8.TraceRecord, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record, err := parseTextRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type jsonReader struct {
+	dec *json.Decoder
+}
+
+func (r *jsonReader) Read() (*chip8.TraceRecord, error) {
+	var j jsonRecord
+	if err := r.dec.Decode(&j); err != nil {
+		return nil, err
+	}
+	record := &chip8.TraceRecord{Cycle: j.Cycle, PC: j.PC, Op: chip8.EncodedOp(j.Op)}
+	for _, change := range j.Registers {
+		register, err := parseRegister(change.Register)
+		if err != nil {
+			return nil, err
+		}
+		record.Registers = append(record.Registers, chip8.RegisterChange{Register: register, Old: change.Old, New: change.New})
+	}
+	for _, write := range j.MemoryWrites {
+		record.MemoryWrites = append(record.MemoryWrites, chip8.MemoryWrite{Address: write.Address, Old: write.Old, New: write.New})
+	}
+	return record, nil
+}
+
+type binaryReader struct {
+	r *bufio.Reader
+}
+
+func (r *binaryReader) Read() (*chip8.TraceRecord, error) {
+	cycle, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	var header [5]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	record := &chip8.TraceRecord{
+		Cycle: cycle,
+		PC:    binary.BigEndian.Uint16(header[0:]),
+		Op:    chip8.EncodedOp(binary.BigEndian.Uint16(header[2:])),
+	}
+	for i := 0; i < int(header[4]); i++ {
+		var change [5]byte
+		if _, err := io.ReadFull(r.r, change[:]); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if chip8.Register(change[0]) > chip8.RegisterST {
+			return nil, fmt.Errorf("Unknown register: %d", change[0])
+		}
+		record.Registers = append(record.Registers, chip8.RegisterChange{
+			Register: chip8.Register(change[0]),
+			Old:      binary.BigEndian.Uint16(change[1:]),
+			New:      binary.BigEndian.Uint16(change[3:]),
+		})
+	}
+	writes, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	for i := uint64(0); i < writes; i++ {
+		var write [4]byte
+		if _, err := io.ReadFull(r.r, write[:]); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		record.MemoryWrites = append(record.MemoryWrites, chip8.MemoryWrite{
+			Address: binary.BigEndian.Uint16(write[0:]),
+			Old:     write[2],
+			New:     write[3],
+		})
+	}
+	return record, nil
+}
+
+// equal reports whether two records describe the same instruction and effects
+func equal(a, b *chip8.TraceRecord) bool {
+	if a.PC != b.PC || a.Op != b.Op ||
+		len(a.Registers) != len(b.Registers) || len(a.MemoryWrites) != len(b.MemoryWrites) {
+		return false
+	}
+	for i := range a.Registers {
+		if a.Registers[i] != b.Registers[i] {
+			return false
+		}
+	}
+	for i := range a.MemoryWrites {
+		if a.MemoryWrites[i] != b.MemoryWrites[i] {
+			return false
+		}
+	}
+	return true
+}
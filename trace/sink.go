@@ -0,0 +1,196 @@
+/*
+Package trace provides sinks and filters for the execution traces reported by
+chip8.VM.SetTracer.
+
+Three formats are supported:
+
+Text, one line per instruction with the cycle, PC, raw op, disassembly and
+changes, e.g.
+
+	000042 0x20A D015 DRW V0, V1, 5  VF=0x00->0x01
+
+JSON lines, one object per instruction, e.g.
+
+	{"cycle":42,"pc":522,"op":53269,"asm":"DRW V0, V1, 5","registers":[{"register":"VF","old":0,"new":1}]}
+
+Binary, the magic "CH8T" and a version byte followed by one record per
+instruction:
+
+	uvarint cycle
+	uint16  PC (big-endian)
+	uint16  op (big-endian)
+	uint8   number of register changes
+	        per change: uint8 register, uint16 old, uint16 new
+	uvarint number of memory writes
+	        per write: uint16 address, uint8 old, uint8 new
+
+Traces in any format can be read back with NewReader and compared with Diff or
+chip8-tracediff. Logs from other emulators can be compared by writing them in
+the text format: the disassembly is ignored when reading, and the changes,
+separated from the instruction by two spaces, may be left out when comparing
+instructions only.
+*/
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/odsod/chip8"
+)
+
+const (
+	binaryMagic   = "CH8T"
+	binaryVersion = 1
+)
+
+// Sink is a tracer writing records to an underlying writer, which must be
+// flushed when done
+type Sink interface {
+	chip8.Tracer
+	Flush() error
+}
+
+// NewSink returns the sink for the format name text, json or binary
+func NewSink(format string, w io.Writer) (Sink, error) {
+	switch format {
+	case "text":
+		return NewTextSink(w), nil
+	case "json":
+		return NewJSONSink(w), nil
+	case "binary":
+		return NewBinarySink(w), nil
+	}
+	return nil, fmt.Errorf("Unsupported trace format: %s", format)
+}
+
+// FormatText formats a record as a line of the text format, without newline
+func FormatText(record *chip8.TraceRecord) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%06d 0x%03X %04X %s", record.Cycle, record.PC, uint16(record.Op), record.Op.Disassemble())
+	if len(record.Registers) > 0 || len(record.MemoryWrites) > 0 {
+		b.WriteString(" ")
+	}
+	for _, change := range record.Registers {
+		if change.Register == chip8.RegisterI {
+			fmt.Fprintf(&b, " %s=0x%03X->0x%03X", change.Register, change.Old, change.New)
+		} else {
+			fmt.Fprintf(&b, " %s=0x%02X->0x%02X", change.Register, change.Old, change.New)
+		}
+	}
+	for _, write := range record.MemoryWrites {
+		fmt.Fprintf(&b, " [0x%03X]=0x%02X->0x%02X", write.Address, write.Old, write.New)
+	}
+	return b.String()
+}
+
+type textSink struct {
+	w *bufio.Writer
+}
+
+func NewTextSink(w io.Writer) Sink {
+	return &textSink{bufio.NewWriter(w)}
+}
+
+func (s *textSink) Trace(record *chip8.TraceRecord) {
+	s.w.WriteString(FormatText(record))
+	s.w.WriteByte('\n')
+}
+
+func (s *textSink) Flush() error {
+	return s.w.Flush()
+}
+
+type jsonRegisterChange struct {
+	Register string `json:"register"`
+	Old      uint16 `json:"old"`
+	New      uint16 `json:"new"`
+}
+
+type jsonMemoryWrite struct {
+	Address uint16 `json:"address"`
+	Old     uint8  `json:"old"`
+	New     uint8  `json:"new"`
+}
+
+type jsonRecord struct {
+	Cycle        uint64               `json:"cycle"`
+	PC           uint16               `json:"pc"`
+	Op           uint16               `json:"op"`
+	Asm          string               `json:"asm"`
+	Registers    []jsonRegisterChange `json:"registers,omitempty"`
+	MemoryWrites []jsonMemoryWrite    `json:"memory,omitempty"`
+}
+
+type jsonSink struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewJSONSink(w io.Writer) Sink {
+	buffered := bufio.NewWriter(w)
+	return &jsonSink{w: buffered, enc: json.NewEncoder(buffered)}
+}
+
+func (s *jsonSink) Trace(record *chip8.TraceRecord) {
+	r := jsonRecord{
+		Cycle: record.Cycle,
+		PC:    record.PC,
+		Op:    uint16(record.Op),
+		Asm:   record.Op.Disassemble(),
+	}
+	for _, change := range record.Registers {
+		r.Registers = append(r.Registers, jsonRegisterChange{change.Register.String(), change.Old, change.New})
+	}
+	for _, write := range record.MemoryWrites {
+		r.MemoryWrites = append(r.MemoryWrites, jsonMemoryWrite{write.Address, write.Old, write.New})
+	}
+	s.enc.Encode(r)
+}
+
+func (s *jsonSink) Flush() error {
+	return s.w.Flush()
+}
+
+type binarySink struct {
+	w             *bufio.Writer
+	buf           []byte
+	headerWritten bool
+}
+
+func NewBinarySink(w io.Writer) Sink {
+	return &binarySink{w: bufio.NewWriter(w)}
+}
+
+func (s *binarySink) Trace(record *chip8.TraceRecord) {
+	b := s.buf[:0]
+	if !s.headerWritten {
+		b = append(b, binaryMagic...)
+		b = append(b, binaryVersion)
+		s.headerWritten = true
+	}
+	b = binary.AppendUvarint(b, record.Cycle)
+	b = binary.BigEndian.AppendUint16(b, record.PC)
+	b = binary.BigEndian.AppendUint16(b, uint16(record.Op))
+	b = append(b, uint8(len(record.Registers)))
+	for _, change := range record.Registers {
+		b = append(b, uint8(change.Register))
+		b = binary.BigEndian.AppendUint16(b, change.Old)
+		b = binary.BigEndian.AppendUint16(b, change.New)
+	}
+	b = binary.AppendUvarint(b, uint64(len(record.MemoryWrites)))
+	for _, write := range record.MemoryWrites {
+		b = binary.BigEndian.AppendUint16(b, write.Address)
+		b = append(b, write.Old, write.New)
+	}
+	s.buf = b
+	s.w.Write(b)
+}
+
+func (s *binarySink) Flush() error {
+	return s.w.Flush()
+}
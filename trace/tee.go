@@ -0,0 +1,29 @@
+package trace
+
+import "github.com/odsod/chip8"
+
+type tee []chip8.Tracer
+
+func (t tee) Trace(record *chip8.TraceRecord) {
+	for _, tracer := range t {
+		tracer.Trace(record)
+	}
+}
+
+// Tee returns a tracer passing records to each non-nil tracer, or nil if there
+// are none so that tracing stays off
+func Tee(tracers ...chip8.Tracer) chip8.Tracer {
+	var result tee
+	for _, tracer := range tracers {
+		if tracer != nil {
+			result = append(result, tracer)
+		}
+	}
+	switch len(result) {
+	case 0:
+		return nil
+	case 1:
+		return result[0]
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+var testRecord = &chip8.TraceRecord{
+	Cycle:        42,
+	PC:           0x20A,
+	Op:           0xD015,
+	Registers:    []chip8.RegisterChange{{Register: chip8.RegisterVF, Old: 0, New: 1}},
+	MemoryWrites: []chip8.MemoryWrite{{Address: 0x300, Old: 0x00, New: 0x7B}},
+}
+
+func TestTextSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewTextSink(&buf)
+	sink.Trace(testRecord)
+	sink.Flush()
+	expected := "000042 0x20A D015 DRW V0, V1, 5  VF=0x00->0x01 [0x300]=0x00->0x7B\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, Actual %q", expected, buf.String())
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONSink(&buf)
+	sink.Trace(testRecord)
+	sink.Flush()
+	var actual jsonRecord
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual.Cycle != 42 || actual.PC != 0x20A || actual.Op != 0xD015 || actual.Asm != "DRW V0, V1, 5" ||
+		len(actual.Registers) != 1 || actual.Registers[0].Register != "VF" ||
+		len(actual.MemoryWrites) != 1 || actual.MemoryWrites[0].New != 0x7B {
+		t.Errorf("Unexpected JSON record: %s", buf.String())
+	}
+}
+
+func TestBinarySink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewBinarySink(&buf)
+	sink.Trace(testRecord)
+	sink.Flush()
+	expected := []byte{
+		'C', 'H', '8', 'T', 1,
+		42,         // cycle
+		0x02, 0x0A, // PC
+		0xD0, 0x15, // op
+		1, 0x0F, 0x00, 0x00, 0x00, 0x01, // VF 0 -> 1
+		1, 0x03, 0x00, 0x00, 0x7B, // [0x300] 0 -> 0x7B
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("Expected % x, Actual % x", expected, buf.Bytes())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewTextSink(&buf)
+	filter := NewFilter(sink)
+	if err := filter.SetPCRange("0x202-0x204"); err != nil {
+		t.Fatal(err)
+	}
+	if err := filter.SetCycleWindow("1-"); err != nil {
+		t.Fatal(err)
+	}
+	filter.SetMnemonics("ld, add")
+	vm := chip8.New([]uint8{
+		0x60, 0x01, // 0x200 LD V0, 1
+		0x70, 0x01, // 0x202 ADD V0, 1
+		0x61, 0x02, // 0x204 LD V1, 2
+		0x12, 0x02, // 0x206 JP 0x202
+	})
+	vm.SetTracer(filter)
+	for i := 0; i < 7; i++ {
+		vm.Step()
+	}
+	sink.Flush()
+	var pcs []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		pcs = append(pcs, strings.Fields(line)[1])
+	}
+	expected := "0x202 0x204 0x202 0x204"
+	if actual := strings.Join(pcs, " "); actual != expected {
+		t.Errorf("Expected PCs %s, Actual %s", expected, actual)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	for _, s := range []string{"", "5", "9-1", "0x200-0x1000", "a-b"} {
+		if _, _, err := parseRange(s, 0xFFF); err == nil {
+			t.Errorf("parseRange(%q): Expected error", s)
+		}
+	}
+}
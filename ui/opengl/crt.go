@@ -0,0 +1,164 @@
+package opengl
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+// CRT configures the shader post-processing emulating a CRT. The phosphor
+// persistence fades lit pixels out over Options.PixelFadeTime.
+type CRT struct {
+	// Scanlines darkens the edges of each pixel row, from 0 (not at all) to 1
+	// (black)
+	Scanlines float64
+	// Bloom adds the glow of the surrounding pixels, from 0 (none) to 1
+	// (their average)
+	Bloom float64
+	// Curvature bends the screen like the glass of a tube, from 0 (flat)
+	Curvature float64
+	// Foreground and Background are the colors of lit and unlit pixels
+	Foreground, Background color.RGBA
+}
+
+// CRTPresets are the CRT presets by name, "off" drawing without shaders
+var CRTPresets = map[string]CRT{
+	"scanlines": {
+		Scanlines:  0.5,
+		Foreground: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		Background: color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	},
+	"crt": {
+		Scanlines:  0.4,
+		Bloom:      0.3,
+		Curvature:  0.06,
+		Foreground: color.RGBA{0xE8, 0xF0, 0xFF, 0xFF},
+		Background: color.RGBA{0x10, 0x12, 0x14, 0xFF},
+	},
+	"green": {
+		Scanlines:  0.4,
+		Bloom:      0.4,
+		Curvature:  0.06,
+		Foreground: color.RGBA{0x33, 0xFF, 0x66, 0xFF},
+		Background: color.RGBA{0x05, 0x14, 0x08, 0xFF},
+	},
+}
+
+// ParseCRT returns the CRT preset with a name, or nil for "off"
+func ParseCRT(name string) (*CRT, error) {
+	if name == "off" {
+		return nil, nil
+	}
+	crt, ok := CRTPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported CRT preset: %s", name)
+	}
+	return &crt, nil
+}
+
+/*
+The rest of this file is a software reference renderer for the shaders in
+shader.go, doing the same math on the CPU so it can be tested without a GPU.
+*/
+
+// phosphor is the glow of each pixel, from 0 (dark) to 1 (lit)
+type phosphor [chip8.ScreenHeight][chip8.ScreenWidth]float64
+
+// at returns the glow of a pixel, clamping the coordinates to the screen like
+// the phosphor texture
+func (p *phosphor) at(x, y int) float64 {
+	x = int(math.Max(0, math.Min(float64(x), chip8.ScreenWidth-1)))
+	y = int(math.Max(0, math.Min(float64(y), chip8.ScreenHeight-1)))
+	return p[y][x]
+}
+
+// decay lights the lit pixels and decays the glow of the others by an
+// amount, like persistenceFragmentShader
+func (p *phosphor) decay(videoMemory [chip8.ScreenHeight]uint64, amount float64) {
+	for y, scanLine := range videoMemory {
+		for x := range p[y] {
+			if scanLine&(0x8000000000000000>>uint(x)) > 0 {
+				p[y][x] = 1
+			} else {
+				p[y][x] = math.Max(0, p[y][x]-amount)
+			}
+		}
+	}
+}
+
+// persistence converts the time between frames to the glow decayed over it
+type persistence struct {
+	fadeTime time.Duration
+	// carry is the decay too small for the 8-bit phosphor texture, carried
+	// over to the next frame
+	carry float64
+}
+
+// decay returns the glow decayed over elapsed time, in whole steps of the
+// 8-bit phosphor texture
+func (p *persistence) decay(elapsed time.Duration) float64 {
+	if p.fadeTime <= 0 {
+		return 1
+	}
+	p.carry += float64(elapsed) / float64(p.fadeTime)
+	if p.carry >= 1 {
+		p.carry = 0
+		return 1
+	}
+	steps := math.Floor(p.carry * 255)
+	p.carry -= steps / 255
+	return steps / 255
+}
+
+// render draws the phosphor to the whole of an image, like crtFragmentShader
+// draws it to the viewport
+func (crt CRT) render(dst *image.RGBA, p *phosphor) {
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			u := (float64(x) + 0.5) / float64(w)
+			v := (float64(y) + 0.5) / float64(h)
+			dst.SetRGBA(dst.Rect.Min.X+x, dst.Rect.Min.Y+y, crt.shade(p, u, v))
+		}
+	}
+}
+
+// shade returns the color at (u, v), from (0, 0) in the top left corner of
+// the screen to (1, 1) in the bottom right
+func (crt CRT) shade(p *phosphor, u, v float64) color.RGBA {
+	// curve the screen, leaving the corners outside of it black
+	cx, cy := 2*u-1, 2*v-1
+	cx, cy = cx*(1+crt.Curvature*cy*cy), cy*(1+crt.Curvature*cx*cx)
+	u, v = (cx+1)/2, (cy+1)/2
+	if u < 0 || u >= 1 || v < 0 || v >= 1 {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	x, y := int(u*chip8.ScreenWidth), int(v*chip8.ScreenHeight)
+	intensity := p.at(x, y)
+	if crt.Bloom > 0 {
+		glow := 0.0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					glow += p.at(x+dx, y+dy)
+				}
+			}
+		}
+		intensity = math.Min(1, intensity+crt.Bloom*glow/8)
+	}
+	_, row := math.Modf(v * chip8.ScreenHeight)
+	scan := 1 - crt.Scanlines*(1-math.Sin(math.Pi*row))
+	mix := func(bg, fg uint8) uint8 {
+		return uint8(math.Round((float64(bg) + (float64(fg)-float64(bg))*intensity) * scan))
+	}
+	return color.RGBA{
+		mix(crt.Background.R, crt.Foreground.R),
+		mix(crt.Background.G, crt.Foreground.G),
+		mix(crt.Background.B, crt.Foreground.B),
+		255,
+	}
+}
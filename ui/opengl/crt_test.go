@@ -0,0 +1,125 @@
+package opengl
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+func TestParseCRT(t *testing.T) {
+	if crt, err := ParseCRT("off"); crt != nil || err != nil {
+		t.Errorf("ParseCRT(off): Expected nil, Actual %v, %v", crt, err)
+	}
+	if crt, err := ParseCRT("crt"); err != nil || *crt != CRTPresets["crt"] {
+		t.Errorf("ParseCRT(crt): Expected %v, Actual %v, %v", CRTPresets["crt"], crt, err)
+	}
+	if _, err := ParseCRT("lcd"); err == nil {
+		t.Error("ParseCRT(lcd): Expected an error")
+	}
+}
+
+func TestPersistenceDecay(t *testing.T) {
+	p := persistence{fadeTime: 255 * time.Millisecond}
+	for _, testCase := range []struct {
+		elapsed  time.Duration
+		expected float64
+	}{
+		{time.Millisecond, 1.0 / 255},
+		{time.Millisecond / 2, 0},
+		{time.Millisecond / 2, 1.0 / 255},
+		{10 * time.Millisecond, 10.0 / 255},
+		{time.Second, 1},
+	} {
+		if actual := p.decay(testCase.elapsed); actual < testCase.expected-1e-9 || actual > testCase.expected+1e-9 {
+			t.Errorf("decay(%v): Expected %v, Actual %v", testCase.elapsed, testCase.expected, actual)
+		}
+	}
+	p = persistence{}
+	if actual := p.decay(time.Millisecond); actual != 1 {
+		t.Errorf("decay without fading: Expected 1, Actual %v", actual)
+	}
+}
+
+func TestPhosphorDecay(t *testing.T) {
+	var p phosphor
+	var videoMemory [chip8.ScreenHeight]uint64
+	videoMemory[0] = 0xC000000000000000
+	p.decay(videoMemory, 0.25)
+	videoMemory[0] = 0x8000000000000000
+	p.decay(videoMemory, 0.25)
+	if p[0][0] != 1 || p[0][1] != 0.75 || p[0][2] != 0 {
+		t.Errorf("Expected [1 0.75 0], Actual %v", p[0][:3])
+	}
+	for i := 0; i < 4; i++ {
+		p.decay(videoMemory, 0.25)
+	}
+	if p[0][1] != 0 {
+		t.Errorf("Expected the glow to fade out, Actual %v", p[0][1])
+	}
+}
+
+func TestCRTShade(t *testing.T) {
+	fg, bg := color.RGBA{200, 100, 0, 255}, color.RGBA{0, 0, 100, 255}
+	var p phosphor
+	p[0][0] = 1
+	p[5][5] = 0.5
+	for i := 0; i < 9; i++ {
+		if i != 4 {
+			p[10+i/3][10+i%3] = 1
+		}
+	}
+	// the center of the pixel at (x, y)
+	center := func(x, y int) (u, v float64) {
+		return (float64(x) + 0.5) / chip8.ScreenWidth, (float64(y) + 0.5) / chip8.ScreenHeight
+	}
+	for _, testCase := range []struct {
+		msg      string
+		crt      CRT
+		x, y     int
+		expected color.RGBA
+	}{
+		{"lit", CRT{}, 0, 0, fg},
+		{"unlit", CRT{}, 1, 0, bg},
+		{"fading", CRT{}, 5, 5, color.RGBA{100, 50, 50, 255}},
+		{"scanline center", CRT{Scanlines: 1}, 0, 0, fg},
+		{"bloom", CRT{Bloom: 0.5}, 11, 11, color.RGBA{100, 50, 50, 255}},
+		{"bloom is capped", CRT{Bloom: 1}, 0, 0, fg},
+		{"curvature keeps the center", CRT{Curvature: 0.2}, 32, 16, bg},
+	} {
+		testCase.crt.Foreground, testCase.crt.Background = fg, bg
+		u, v := center(testCase.x, testCase.y)
+		if actual := testCase.crt.shade(&p, u, v); actual != testCase.expected {
+			t.Errorf("%s: Expected %v, Actual %v", testCase.msg, testCase.expected, actual)
+		}
+	}
+
+	crt := CRT{Scanlines: 0.5, Foreground: fg, Background: bg}
+	if edge := crt.shade(&p, 0.5/chip8.ScreenWidth, 0.01/chip8.ScreenHeight); edge.R < 100 || edge.R > 105 {
+		t.Errorf("scanline edge: Expected about half of %v, Actual %v", fg, edge)
+	}
+	crt = CRT{Curvature: 0.2, Foreground: fg, Background: bg}
+	if corner := crt.shade(&p, 0.001, 0.001); corner != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("curved corner: Expected black, Actual %v", corner)
+	}
+}
+
+func TestCRTRender(t *testing.T) {
+	var p phosphor
+	p[1][1] = 1
+	crt := CRT{Foreground: color.RGBA{255, 255, 255, 255}, Background: color.RGBA{0, 0, 0, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, 2*chip8.ScreenWidth, 2*chip8.ScreenHeight))
+	crt.render(img, &p)
+	for _, testCase := range []struct {
+		x, y     int
+		expected uint8
+	}{
+		{1, 1, 0}, {2, 2, 255}, {3, 3, 255}, {4, 4, 0},
+	} {
+		if actual := img.RGBAAt(testCase.x, testCase.y).R; actual != testCase.expected {
+			t.Errorf("(%d, %d): Expected %d, Actual %d", testCase.x, testCase.y, testCase.expected, actual)
+		}
+	}
+}
@@ -1,26 +1,79 @@
 package opengl
 
-import "github.com/go-gl/glfw/v3.1/glfw"
+import (
+	"sort"
+
+	"github.com/go-gl/glfw/v3.1/glfw"
+)
+
+/*
+Dvorak keyboard layout mapping to CHIP-8 keys.
+
+	|1|2|3|4| -> |1|2|3|C|
+	|'|,|.|p| -> |4|5|6|D|
+	|a|o|e|u| -> |7|8|9|E|
+	|;|q|j|k| -> |A|0|B|F|
+*/
+var Dvorak = map[glfw.Key]uint8{
+	glfw.Key1: 0x1, glfw.Key2: 0x2, glfw.Key3: 0x3, glfw.Key4: 0xC,
+	glfw.KeyApostrophe: 0x4, glfw.KeyComma: 0x5, glfw.KeyPeriod: 0x6, glfw.KeyP: 0xD,
+	glfw.KeyA: 0x7, glfw.KeyO: 0x8, glfw.KeyE: 0x9, glfw.KeyU: 0xE,
+	glfw.KeySemicolon: 0xA, glfw.KeyQ: 0x0, glfw.KeyJ: 0xB, glfw.KeyK: 0xF,
+}
 
 /*
-Keyboard layout mapping to CHIP-8 keys.
+QWER(TY|TZ) keyboard layout mapping to CHIP-8 keys.
 
 	|1|2|3|4| -> |1|2|3|C|
 	|q|w|e|r| -> |4|5|6|D|
 	|a|s|d|f| -> |7|8|9|E|
 	|z|x|c|v| -> |A|0|B|F|
 */
-var keyMap = map[glfw.Key]uint8{
+var QWER = map[glfw.Key]uint8{
 	glfw.Key1: 0x1, glfw.Key2: 0x2, glfw.Key3: 0x3, glfw.Key4: 0xC,
 	glfw.KeyQ: 0x4, glfw.KeyW: 0x5, glfw.KeyE: 0x6, glfw.KeyR: 0xD,
 	glfw.KeyA: 0x7, glfw.KeyS: 0x8, glfw.KeyD: 0x9, glfw.KeyF: 0xE,
 	glfw.KeyZ: 0xA, glfw.KeyX: 0x0, glfw.KeyC: 0xB, glfw.KeyV: 0xF,
 }
 
-func readKeys(window *glfw.Window) [16]bool {
+// KeyboardLayouts are the keyboard layouts by name, the same as the terminal
+// UI's
+var KeyboardLayouts = map[string]map[glfw.Key]uint8{
+	"qwer":   QWER,
+	"dvorak": Dvorak,
+}
+
+// keyboardLayoutNames returns the names of the keyboard layouts in
+// alphabetical order
+func keyboardLayoutNames() []string {
+	names := make([]string, 0, len(KeyboardLayouts))
+	for name := range KeyboardLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readKeys(window *glfw.Window, keyMap map[glfw.Key]uint8) [16]bool {
 	var result [16]bool
 	for glfwKey, chip8Key := range keyMap {
 		result[chip8Key] = window.GetKey(glfwKey) == glfw.Press
 	}
 	return result
 }
+
+// cheatKeys are the keys toggling cheats, by index
+var cheatKeys = map[glfw.Key]int{
+	glfw.KeyF1: 0, glfw.KeyF2: 1, glfw.KeyF3: 2, glfw.KeyF4: 3, glfw.KeyF5: 4,
+	glfw.KeyF6: 5, glfw.KeyF7: 6, glfw.KeyF8: 7, glfw.KeyF9: 8,
+}
+
+// The keys controlling the emulator
+const (
+	pauseKey       = glfw.KeySpace
+	fastForwardKey = glfw.KeyTab
+	saveStateKey   = glfw.KeyF10
+	loadStateKey   = glfw.KeyF11
+	nextSlotKey    = glfw.KeyF12
+	menuKey        = glfw.KeyEscape
+)
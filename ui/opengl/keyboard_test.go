@@ -0,0 +1,17 @@
+package opengl
+
+import "testing"
+
+func TestKeyboardLayouts(t *testing.T) {
+	for _, name := range keyboardLayoutNames() {
+		var mapped [16]bool
+		for _, chip8Key := range KeyboardLayouts[name] {
+			mapped[chip8Key] = true
+		}
+		for key, ok := range mapped {
+			if !ok {
+				t.Errorf("%s: Expected key %X to be mapped", name, key)
+			}
+		}
+	}
+}
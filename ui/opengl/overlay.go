@@ -0,0 +1,52 @@
+package opengl
+
+import (
+	"image"
+	"time"
+
+	"github.com/go-gl/gl/v2.1/gl"
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/ui/osd"
+)
+
+// overlayScale is the resolution of the OSD overlay, in pixels per screen
+// pixel
+const overlayScale = 4
+
+// overlay draws the OSD over the screen from a texture, updated when the OSD
+// changes
+type overlay struct {
+	img     *image.RGBA
+	texture uint32
+}
+
+func newOverlay() *overlay {
+	o := &overlay{
+		img: image.NewRGBA(image.Rect(0, 0, overlayScale*chip8.ScreenWidth, overlayScale*chip8.ScreenHeight)),
+	}
+	gl.GenTextures(1, &o.texture)
+	gl.BindTexture(gl.TEXTURE_2D, o.texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(o.img.Rect.Dx()), int32(o.img.Rect.Dy()), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(o.img.Pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return o
+}
+
+// draw blends the OSD over the screen, covering (-x, -y) - (x, y)
+func (o *overlay) draw(display *osd.OSD, now time.Time, x, y float32) {
+	gl.BindTexture(gl.TEXTURE_2D, o.texture)
+	if display.Draw(o.img, now) {
+		gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, int32(o.img.Rect.Dx()), int32(o.img.Rect.Dy()),
+			gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(o.img.Pix))
+	}
+	// the image is alpha premultiplied
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	drawQuad(x, y)
+	gl.Disable(gl.BLEND)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
@@ -0,0 +1,275 @@
+package opengl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-gl/gl/v2.1/gl"
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/ui/raster"
+)
+
+// vertexShader draws a quad covering the viewport, with uv from (0, 0) in the
+// top left corner to (1, 1) in the bottom right
+const vertexShader = `
+#version 120
+attribute vec2 position;
+varying vec2 uv;
+void main() {
+	uv = vec2(position.x + 1.0, 1.0 - position.y) / 2.0;
+	gl_Position = vec4(position, 0.0, 1.0);
+}
+` + "\x00"
+
+// persistenceFragmentShader lights the lit pixels of the screen texture and
+// decays the glow of the others in the phosphor texture, drawing to the next
+// phosphor texture. Its texels are addressed by gl_FragCoord, so that the
+// rows of all textures are scan lines from the top.
+const persistenceFragmentShader = `
+#version 120
+uniform sampler2D screen;
+uniform sampler2D phosphor;
+uniform float decay;
+void main() {
+	vec2 texel = gl_FragCoord.xy / vec2(64.0, 32.0);
+	float lit = texture2D(screen, texel).r;
+	float glow = texture2D(phosphor, texel).r;
+	gl_FragColor = vec4(vec3(max(lit, glow - decay)), 1.0);
+}
+` + "\x00"
+
+// crtFragmentShader draws the phosphor texture with curvature, bloom and
+// scanlines, mirroring CRT.shade
+const crtFragmentShader = `
+#version 120
+uniform sampler2D phosphor;
+uniform float scanlines;
+uniform float bloom;
+uniform float curvature;
+uniform vec3 foreground;
+uniform vec3 background;
+varying vec2 uv;
+const vec2 size = vec2(64.0, 32.0);
+float glowAt(vec2 pixel) {
+	return texture2D(phosphor, (pixel + 0.5) / size).r;
+}
+void main() {
+	vec2 c = uv * 2.0 - 1.0;
+	c = c * (1.0 + curvature * c.yx * c.yx);
+	vec2 p = (c + 1.0) / 2.0;
+	if (p.x < 0.0 || p.x >= 1.0 || p.y < 0.0 || p.y >= 1.0) {
+		gl_FragColor = vec4(0.0, 0.0, 0.0, 1.0);
+		return;
+	}
+	vec2 pixel = floor(p * size);
+	float intensity = glowAt(pixel);
+	if (bloom > 0.0) {
+		float glow = 0.0;
+		for (int dy = -1; dy <= 1; dy++) {
+			for (int dx = -1; dx <= 1; dx++) {
+				if (dx != 0 || dy != 0) {
+					glow += glowAt(pixel + vec2(dx, dy));
+				}
+			}
+		}
+		intensity = min(1.0, intensity + bloom * glow / 8.0);
+	}
+	float scan = 1.0 - scanlines * (1.0 - sin(3.14159265 * fract(p.y * size.y)));
+	gl_FragColor = vec4(mix(background, foreground, intensity) * scan, 1.0);
+}
+` + "\x00"
+
+// crtRenderer draws the screen with the CRT shaders, keeping the phosphor
+// glow in a pair of textures drawn to in turn
+type crtRenderer struct {
+	crt         CRT
+	persistence persistence
+	lastFrame   time.Time
+	// lit is the screen texture data, 255 for lit pixels
+	lit          [chip8.ScreenHeight * chip8.ScreenWidth]uint8
+	screen       uint32
+	phosphor     [2]uint32
+	framebuffers [2]uint32
+	// current is the index of the phosphor texture with the latest glow
+	current            int
+	quad               uint32
+	persistenceProgram uint32
+	crtProgram         uint32
+}
+
+// newCRTRenderer compiles the shaders and creates the textures, returning an
+// error if the GL implementation does not support them
+func newCRTRenderer(crt CRT, pixelFadeTime time.Duration) (*crtRenderer, error) {
+	r := &crtRenderer{crt: crt, persistence: persistence{fadeTime: pixelFadeTime}}
+	var err error
+	if r.persistenceProgram, err = compileProgram(vertexShader, persistenceFragmentShader); err != nil {
+		return nil, err
+	}
+	if r.crtProgram, err = compileProgram(vertexShader, crtFragmentShader); err != nil {
+		return nil, err
+	}
+
+	r.screen = newTexture(gl.LUMINANCE, r.lit[:])
+	gl.GenFramebuffersEXT(2, &r.framebuffers[0])
+	for i := range r.phosphor {
+		r.phosphor[i] = newTexture(gl.RGBA, nil)
+		gl.BindFramebufferEXT(gl.FRAMEBUFFER_EXT, r.framebuffers[i])
+		gl.FramebufferTexture2DEXT(gl.FRAMEBUFFER_EXT, gl.COLOR_ATTACHMENT0_EXT, gl.TEXTURE_2D, r.phosphor[i], 0)
+		status := gl.CheckFramebufferStatusEXT(gl.FRAMEBUFFER_EXT)
+		gl.BindFramebufferEXT(gl.FRAMEBUFFER_EXT, 0)
+		if status != gl.FRAMEBUFFER_COMPLETE_EXT {
+			return nil, fmt.Errorf("Incomplete phosphor framebuffer: 0x%x", status)
+		}
+	}
+
+	vertices := []float32{-1, -1, 1, -1, -1, 1, 1, 1}
+	gl.GenBuffers(1, &r.quad)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.quad)
+	gl.BufferData(gl.ARRAY_BUFFER, 4*len(vertices), gl.Ptr(vertices), gl.STATIC_DRAW)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	return r, nil
+}
+
+// newTexture creates a screen sized texture with nearest filtering, from
+// data or black
+func newTexture(format uint32, data []uint8) uint32 {
+	if data == nil {
+		data = make([]uint8, 4*chip8.ScreenWidth*chip8.ScreenHeight)
+	}
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, int32(format), chip8.ScreenWidth, chip8.ScreenHeight, 0,
+		format, gl.UNSIGNED_BYTE, gl.Ptr(data))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return texture
+}
+
+// compileProgram compiles and links a vertex and fragment shader
+func compileProgram(vertexSource, fragmentSource string) (uint32, error) {
+	vertex, err := compileShader(vertexSource, gl.VERTEX_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(vertex)
+	fragment, err := compileShader(fragmentSource, gl.FRAGMENT_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(fragment)
+
+	program := gl.CreateProgram()
+	gl.AttachShader(program, vertex)
+	gl.AttachShader(program, fragment)
+	gl.BindAttribLocation(program, 0, gl.Str("position\x00"))
+	gl.LinkProgram(program)
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(program)
+		return 0, fmt.Errorf("Failed to link shaders: %s", strings.TrimRight(log, "\x00"))
+	}
+	return program, nil
+}
+
+func compileShader(source string, shaderType uint32) (uint32, error) {
+	shader := gl.CreateShader(shaderType)
+	csources, free := gl.Strs(source)
+	gl.ShaderSource(shader, 1, csources, nil)
+	free()
+	gl.CompileShader(shader)
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+		return 0, fmt.Errorf("Failed to compile shader: %s", strings.TrimRight(log, "\x00"))
+	}
+	return shader, nil
+}
+
+// draw uploads the dirty scan lines, decays the phosphor glow and draws it to
+// the viewport at (x, y) of size (w, h)
+func (r *crtRenderer) draw(now time.Time, videoMemory [chip8.ScreenHeight]uint64, dirty uint32, x, y, w, h int32) {
+	elapsed := time.Duration(0)
+	if r.lastFrame.IsZero() {
+		dirty = chip8.AllScanLines
+	} else {
+		elapsed = now.Sub(r.lastFrame)
+	}
+	r.lastFrame = now
+
+	for row, scanLine := range videoMemory {
+		if dirty&(1<<uint(row)) == 0 {
+			continue
+		}
+		for col := 0; col < chip8.ScreenWidth; col++ {
+			lit := uint8(0)
+			if scanLine&(0x8000000000000000>>uint(col)) > 0 {
+				lit = 255
+			}
+			r.lit[row*chip8.ScreenWidth+col] = lit
+		}
+	}
+	gl.BindTexture(gl.TEXTURE_2D, r.screen)
+	if first, last, ok := raster.ChangedRange(dirty); ok {
+		gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, int32(first), chip8.ScreenWidth, int32(last-first+1),
+			gl.LUMINANCE, gl.UNSIGNED_BYTE, gl.Ptr(r.lit[first*chip8.ScreenWidth:]))
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.quad)
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
+
+	// persistence pass, from the current phosphor texture to the next
+	next := 1 - r.current
+	gl.BindFramebufferEXT(gl.FRAMEBUFFER_EXT, r.framebuffers[next])
+	gl.Viewport(0, 0, chip8.ScreenWidth, chip8.ScreenHeight)
+	gl.UseProgram(r.persistenceProgram)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, r.screen)
+	gl.ActiveTexture(gl.TEXTURE1)
+	gl.BindTexture(gl.TEXTURE_2D, r.phosphor[r.current])
+	gl.Uniform1i(uniform(r.persistenceProgram, "screen"), 0)
+	gl.Uniform1i(uniform(r.persistenceProgram, "phosphor"), 1)
+	gl.Uniform1f(uniform(r.persistenceProgram, "decay"), float32(r.persistence.decay(elapsed)))
+	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+	gl.BindFramebufferEXT(gl.FRAMEBUFFER_EXT, 0)
+	r.current = next
+
+	// CRT pass, from the phosphor texture to the window
+	gl.Viewport(x, y, w, h)
+	gl.UseProgram(r.crtProgram)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, r.phosphor[r.current])
+	gl.Uniform1i(uniform(r.crtProgram, "phosphor"), 0)
+	gl.Uniform1f(uniform(r.crtProgram, "scanlines"), float32(r.crt.Scanlines))
+	gl.Uniform1f(uniform(r.crtProgram, "bloom"), float32(r.crt.Bloom))
+	gl.Uniform1f(uniform(r.crtProgram, "curvature"), float32(r.crt.Curvature))
+	fg, bg := r.crt.Foreground, r.crt.Background
+	gl.Uniform3f(uniform(r.crtProgram, "foreground"), float32(fg.R)/255, float32(fg.G)/255, float32(fg.B)/255)
+	gl.Uniform3f(uniform(r.crtProgram, "background"), float32(bg.R)/255, float32(bg.G)/255, float32(bg.B)/255)
+	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+
+	gl.UseProgram(0)
+	gl.DisableVertexAttribArray(0)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+func uniform(program uint32, name string) int32 {
+	return gl.GetUniformLocation(program, gl.Str(name+"\x00"))
+}
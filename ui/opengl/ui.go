@@ -1,13 +1,18 @@
 package opengl
 
 import (
+	"fmt"
 	"io/ioutil"
+	"log"
 	"runtime"
 	"time"
 
 	"github.com/go-gl/gl/v2.1/gl"
 	"github.com/go-gl/glfw/v3.1/glfw"
 	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/ui/osd"
+	"github.com/odsod/chip8/ui/raster"
+	"github.com/odsod/chip8/ui/settings"
 )
 
 type Options struct {
@@ -16,25 +21,170 @@ type Options struct {
 	TimerFrequencyHz int
 	Scale            int
 	PixelFadeTime    time.Duration
+	Tracer           chip8.Tracer
+	// Cheats are toggled with F1 - F9 in order
+	Cheats []*chip8.Cheat
+	// VIPTiming runs the VM with COSMAC VIP instruction timing instead of at
+	// CPUFrequencyHz and TimerFrequencyHz
+	VIPTiming bool
+	// DisplayWait makes DRW wait for the next timer tick
+	DisplayWait bool
+	// Filter upscales the screen when drawing without the CRT shaders
+	Filter raster.Filter
+	// CRT draws the screen with the CRT shaders if not nil, falling back to
+	// drawing without them if they are not supported
+	CRT *CRT
+	// ThemeName is the theme of the screen without the CRT shaders, black
+	// on white if not set
+	ThemeName string
+	// Sound is only kept for the settings, as there is no sound output
+	Sound bool
+	// KeyboardLayout is the name of one of KeyboardLayouts, qwer if not set
+	KeyboardLayout string
+	// SettingsPath is where the pause menu saves the settings
+	SettingsPath string
 }
 
 type UI struct {
 	vm      *chip8.VM
-	display *display
-	opts    Options
+	timing  *chip8.VIPTiming
+	display *raster.Display
+	osd     *osd.OSD
+	slots   osd.Slots
+	keyMap  map[glfw.Key]uint8
+	// menu is the pause menu while it is open
+	menu *settings.Menu
+	opts Options
 }
 
 func NewUI(opts Options) *UI {
-	rom, err := ioutil.ReadFile(opts.RomFile)
-	if err != nil {
-		panic(err)
+	if opts.ThemeName == "" {
+		opts.ThemeName = "paper"
+	}
+	theme, ok := raster.Themes[opts.ThemeName]
+	if !ok {
+		panic(fmt.Sprintf("Unsupported theme: %s", opts.ThemeName))
+	}
+	if opts.KeyboardLayout == "" {
+		opts.KeyboardLayout = "qwer"
+	}
+	keyMap, ok := KeyboardLayouts[opts.KeyboardLayout]
+	if !ok {
+		panic(fmt.Sprintf("Unsupported keyboard layout: %s", opts.KeyboardLayout))
 	}
 
-	return &UI{
-		vm:      chip8.New(rom),
-		display: newDisplay(opts.PixelFadeTime),
+	ui := &UI{
+		display: raster.NewDisplay(opts.PixelFadeTime),
+		osd:     osd.New(time.Now()),
+		keyMap:  keyMap,
 		opts:    opts,
 	}
+	ui.display.SetTheme(theme)
+	if err := ui.load(opts.RomFile); err != nil {
+		panic(err)
+	}
+	return ui
+}
+
+// load starts a ROM in a new VM, dropping the cheats and save states of
+// another ROM
+func (ui *UI) load(romFile string) error {
+	rom, err := ioutil.ReadFile(romFile)
+	if err != nil {
+		return err
+	}
+	vm, err := chip8.TryNew(rom)
+	if err != nil {
+		return err
+	}
+	if romFile != ui.opts.RomFile {
+		ui.opts.RomFile, ui.opts.Cheats, ui.slots = romFile, nil, osd.Slots{}
+	}
+	vm.SetTracer(ui.opts.Tracer)
+	vm.SetCheats(ui.opts.Cheats)
+	vm.DisplayWait = ui.opts.DisplayWait
+	// the screen of the previous VM is cleared
+	vm.DirtyScanLines = chip8.AllScanLines
+	ui.vm, ui.timing = vm, chip8.NewVIPTiming(vm)
+	return nil
+}
+
+// settings returns the settings the UI runs with
+func (ui *UI) settings() settings.Settings {
+	return settings.Settings{
+		CPUFrequencyHz:   ui.opts.CPUFrequencyHz,
+		TimerFrequencyHz: ui.opts.TimerFrequencyHz,
+		PixelFadeTimeMs:  int(ui.opts.PixelFadeTime / time.Millisecond),
+		Theme:            ui.opts.ThemeName,
+		VIPTiming:        ui.opts.VIPTiming,
+		DisplayWait:      ui.opts.DisplayWait,
+		Sound:            ui.opts.Sound,
+		KeyboardLayout:   ui.opts.KeyboardLayout,
+	}
+}
+
+// apply changes the settings the UI runs with
+func (ui *UI) apply(s settings.Settings) {
+	ui.opts.CPUFrequencyHz = s.CPUFrequencyHz
+	ui.opts.TimerFrequencyHz = s.TimerFrequencyHz
+	ui.opts.PixelFadeTime = time.Duration(s.PixelFadeTimeMs) * time.Millisecond
+	ui.display.SetPixelFadeTime(ui.opts.PixelFadeTime)
+	if theme, ok := raster.Themes[s.Theme]; ok && s.Theme != ui.opts.ThemeName {
+		ui.opts.ThemeName = s.Theme
+		ui.display.SetTheme(theme)
+	}
+	ui.opts.VIPTiming = s.VIPTiming
+	ui.opts.DisplayWait = s.DisplayWait
+	ui.vm.DisplayWait = s.DisplayWait
+	ui.opts.Sound = s.Sound
+	if keyMap, ok := KeyboardLayouts[s.KeyboardLayout]; ok {
+		ui.opts.KeyboardLayout = s.KeyboardLayout
+		ui.keyMap = keyMap
+	}
+}
+
+// menuPress handles a key press while the pause menu is open, and returns
+// whether the menu is still open and whether to quit
+func (ui *UI) menuPress(now time.Time, key glfw.Key) (open, quit bool) {
+	var action settings.Action
+	switch key {
+	case menuKey:
+		action = settings.ActionResume
+	case glfw.KeyUp:
+		ui.menu.Up()
+	case glfw.KeyDown:
+		ui.menu.Down()
+	case glfw.KeyLeft:
+		ui.menu.Left()
+	case glfw.KeyRight:
+		ui.menu.Right()
+	case glfw.KeyEnter:
+		action = ui.menu.Enter()
+	}
+	ui.apply(ui.menu.Settings)
+	switch action {
+	case settings.ActionResume:
+		return false, false
+	case settings.ActionReset, settings.ActionLoadROM:
+		romFile := ui.opts.RomFile
+		if action == settings.ActionLoadROM {
+			romFile = ui.menu.SelectedROM()
+		}
+		if err := ui.load(romFile); err != nil {
+			ui.osd.Message(now, "Error: "+err.Error())
+			return true, false
+		}
+		return false, false
+	case settings.ActionSave:
+		if err := ui.menu.Settings.Save(ui.opts.SettingsPath); err != nil {
+			ui.osd.Message(now, "Error: "+err.Error())
+		} else {
+			ui.osd.Message(now, "Settings saved")
+		}
+	case settings.ActionQuit:
+		return false, true
+	}
+	return true, false
 }
 
 func targetUpdates(runTime time.Duration, updateFrequencyHz int) int {
@@ -46,6 +196,12 @@ func (ui *UI) Run() {
 	// OpenGL needs to run on a single OS thread
 	runtime.LockOSThread()
 
+	lastTime := time.Now()
+	// runTime is the emulated time, which stops while paused
+	runTime := time.Duration(0)
+	timerCycles := 0
+	cpuCycles := 0
+
 	// Init OpenGL and GLFW
 	if err := glfw.Init(); err != nil {
 		panic(err)
@@ -65,6 +221,48 @@ func (ui *UI) Run() {
 		panic(err)
 	}
 	window.MakeContextCurrent()
+	window.SetKeyCallback(func(_ *glfw.Window, key glfw.Key, _ int, action glfw.Action, _ glfw.ModifierKey) {
+		if action != glfw.Press {
+			return
+		}
+		if ui.menu != nil {
+			open, quit := ui.menuPress(time.Now(), key)
+			if quit {
+				window.SetShouldClose(true)
+			}
+			if open {
+				ui.osd.Menu = ui.menu.Lines()
+				return
+			}
+			// the emulated time restarts at the frequencies changed
+			ui.menu, ui.osd.Menu = nil, nil
+			runTime, timerCycles, cpuCycles = 0, 0, 0
+			return
+		}
+		if key == menuKey {
+			ui.menu = settings.NewMenu(ui.settings(), settings.MenuOptions{
+				Themes:          raster.ThemeNames(),
+				KeyboardLayouts: keyboardLayoutNames(),
+			}, ui.opts.RomFile)
+			ui.osd.Menu = ui.menu.Lines()
+			return
+		}
+		if i, ok := cheatKeys[key]; ok {
+			ui.vm.ToggleCheat(i)
+		}
+		switch key {
+		case pauseKey:
+			ui.osd.TogglePause()
+		case fastForwardKey:
+			ui.osd.FastForward = !ui.osd.FastForward
+		case saveStateKey:
+			ui.osd.Message(time.Now(), ui.slots.Save(ui.vm))
+		case loadStateKey:
+			ui.osd.Message(time.Now(), ui.slots.Load(ui.vm))
+		case nextSlotKey:
+			ui.osd.Message(time.Now(), ui.slots.Next())
+		}
+	})
 	if err := gl.Init(); err != nil {
 		panic(err)
 	}
@@ -77,72 +275,128 @@ func (ui *UI) Run() {
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	ui.display.Update(time.Now(), ui.vm.VideoMemory, chip8.AllScanLines)
+	img := ui.opts.Filter.Apply(ui.display.Buffer)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGBA,
+		int32(img.Rect.Dx()),
+		int32(img.Rect.Dy()),
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(img.Pix))
 	gl.BindTexture(gl.TEXTURE_2D, 0)
 
-	rom, err := ioutil.ReadFile("roms/TETRIS")
-	if err != nil {
-		panic(err)
+	var crt *crtRenderer
+	if ui.opts.CRT != nil {
+		if !glfw.ExtensionSupported("GL_EXT_framebuffer_object") {
+			log.Print("CRT shaders disabled: GL_EXT_framebuffer_object is not supported")
+		} else if crt, err = newCRTRenderer(*ui.opts.CRT, ui.opts.PixelFadeTime); err != nil {
+			log.Printf("CRT shaders disabled: %v", err)
+		}
 	}
 
-	vm := chip8.New(rom)
-
-	startTime := time.Now()
-	timerCycles := 0
-	cpuCycles := 0
+	overlay := newOverlay()
 
 	for !window.ShouldClose() {
 		glfw.PollEvents()
 		gl.Clear(gl.COLOR_BUFFER_BIT)
-		vm.SetKeys(readKeys(window))
+		ui.vm.SetKeys(readKeys(window, ui.keyMap))
 
 		now := time.Now()
-		runTime := now.Sub(startTime)
+		if ui.menu == nil {
+			runTime += now.Sub(lastTime) * time.Duration(ui.osd.Speed())
+		}
+		lastTime = now
 
-		for i := timerCycles; i < targetUpdates(runTime, 60); i++ {
-			vm.TickTimers()
-			timerCycles++
+		steps := 0
+		if ui.opts.VIPTiming {
+			for i := timerCycles; i < targetUpdates(runTime, chip8.VIPFrameRateHz); i++ {
+				n, err := ui.timing.RunFrame()
+				steps += n
+				if err != nil {
+					ui.osd.SetError(err)
+					break
+				}
+				timerCycles++
+			}
+		} else {
+			for i := timerCycles; i < targetUpdates(runTime, ui.opts.TimerFrequencyHz); i++ {
+				ui.vm.TickTimers()
+				timerCycles++
+			}
+			for i := cpuCycles; i < targetUpdates(runTime, ui.opts.CPUFrequencyHz); i++ {
+				if ui.vm.IsWaitingForDisplay {
+					// the cycles until the next timer tick are spent waiting
+					cpuCycles = targetUpdates(runTime, ui.opts.CPUFrequencyHz)
+					break
+				}
+				if err := ui.vm.TryStep(); err != nil {
+					ui.osd.SetError(err)
+					break
+				}
+				cpuCycles++
+				steps++
+			}
 		}
-		for i := cpuCycles; i < targetUpdates(runTime, 500); i++ {
-			vm.Step()
-			cpuCycles++
+		ui.osd.Count(now, steps, 1)
+
+		w, h := window.GetFramebufferSize()
+		screen := raster.Letterbox(w, h)
+		x := float32(screen.Dx()) / float32(w)
+		y := float32(screen.Dy()) / float32(h)
+		if crt != nil {
+			crt.persistence.fadeTime = ui.opts.PixelFadeTime
+			crt.draw(now, ui.vm.VideoMemory, ui.vm.TakeDirtyScanLines(),
+				int32(screen.Min.X), int32(h-screen.Max.Y), int32(screen.Dx()), int32(screen.Dy()))
+			gl.Viewport(0, 0, int32(w), int32(h))
+			overlay.draw(ui.osd, now, x, y)
+			window.SwapBuffers()
+			continue
 		}
+		gl.Viewport(0, 0, int32(w), int32(h))
 
-		ui.display.update(now, vm.VideoMemory)
+		changed := ui.display.Update(now, ui.vm.VideoMemory, ui.vm.TakeDirtyScanLines())
 
-		// Draw the current display buffer to the screen
+		// Upload the changed scan lines and draw the display buffer to the screen
 		gl.BindTexture(gl.TEXTURE_2D, texture)
-		gl.TexImage2D(
-			gl.TEXTURE_2D,
-			0,
-			gl.RGBA,
-			chip8.ScreenWidth,
-			chip8.ScreenHeight,
-			0,
-			gl.RGBA,
-			gl.UNSIGNED_BYTE,
-			gl.Ptr(ui.display.buffer.Pix))
-		w, h := window.GetFramebufferSize()
-		s1 := float32(w) / chip8.ScreenWidth
-		s2 := float32(h) / chip8.ScreenHeight
-		var x, y float32
-		if s1 >= s2 {
-			x = s2 / s1
-			y = 1
-		} else {
-			x = 1
-			y = s1 / s2
+		if first, last, ok := raster.ChangedRange(changed); ok {
+			img := ui.opts.Filter.Apply(ui.display.Buffer)
+			if img != ui.display.Buffer {
+				// the filters spread the changes to the surrounding scan lines
+				first, last = 0, chip8.ScreenHeight-1
+			}
+			scale := img.Rect.Dy() / chip8.ScreenHeight
+			gl.TexSubImage2D(
+				gl.TEXTURE_2D,
+				0,
+				0,
+				int32(first*scale),
+				int32(img.Rect.Dx()),
+				int32((last-first+1)*scale),
+				gl.RGBA,
+				gl.UNSIGNED_BYTE,
+				gl.Ptr(img.Pix[first*scale*img.Stride:]))
 		}
-		gl.Begin(gl.QUADS)
-		gl.TexCoord2f(0, 1)
-		gl.Vertex2f(-x, -y)
-		gl.TexCoord2f(1, 1)
-		gl.Vertex2f(x, -y)
-		gl.TexCoord2f(1, 0)
-		gl.Vertex2f(x, y)
-		gl.TexCoord2f(0, 0)
-		gl.Vertex2f(-x, y)
-		gl.End()
+		drawQuad(x, y)
 		gl.BindTexture(gl.TEXTURE_2D, 0)
+		overlay.draw(ui.osd, now, x, y)
 		window.SwapBuffers()
 	}
 }
+
+// drawQuad draws the bound texture to (-x, -y) - (x, y)
+func drawQuad(x, y float32) {
+	gl.Begin(gl.QUADS)
+	gl.TexCoord2f(0, 1)
+	gl.Vertex2f(-x, -y)
+	gl.TexCoord2f(1, 1)
+	gl.Vertex2f(x, -y)
+	gl.TexCoord2f(1, 0)
+	gl.Vertex2f(x, y)
+	gl.TexCoord2f(0, 0)
+	gl.Vertex2f(-x, y)
+	gl.End()
+}
@@ -0,0 +1,114 @@
+package osd
+
+import (
+	"image"
+	"image/color"
+	"unicode"
+)
+
+const (
+	GlyphWidth  = 3
+	GlyphHeight = 5
+	// glyphAdvance is the glyph width and a pixel of spacing
+	glyphAdvance = GlyphWidth + 1
+)
+
+// glyphs are the 3x5 glyphs of ASCII 0x20 - 0x5F, a row per byte with bit 2
+// being the leftmost pixel. Lower case letters are drawn in upper case.
+var glyphs = [64][GlyphHeight]uint8{
+	{0, 0, 0, 0, 0}, // ' '
+	{2, 2, 2, 0, 2}, // '!'
+	{5, 5, 0, 0, 0}, // '"'
+	{5, 7, 5, 7, 5}, // '#'
+	{3, 6, 2, 3, 6}, // '$'
+	{5, 1, 2, 4, 5}, // '%'
+	{2, 5, 2, 5, 3}, // '&'
+	{2, 2, 0, 0, 0}, // '\''
+	{1, 2, 2, 2, 1}, // '('
+	{4, 2, 2, 2, 4}, // ')'
+	{0, 5, 2, 5, 0}, // '*'
+	{0, 2, 7, 2, 0}, // '+'
+	{0, 0, 0, 2, 4}, // ','
+	{0, 0, 7, 0, 0}, // '-'
+	{0, 0, 0, 0, 2}, // '.'
+	{1, 1, 2, 4, 4}, // '/'
+	{7, 5, 5, 5, 7}, // '0'
+	{2, 6, 2, 2, 7}, // '1'
+	{7, 1, 7, 4, 7}, // '2'
+	{7, 1, 3, 1, 7}, // '3'
+	{5, 5, 7, 1, 1}, // '4'
+	{7, 4, 7, 1, 7}, // '5'
+	{7, 4, 7, 5, 7}, // '6'
+	{7, 1, 1, 2, 2}, // '7'
+	{7, 5, 7, 5, 7}, // '8'
+	{7, 5, 7, 1, 7}, // '9'
+	{0, 2, 0, 2, 0}, // ':'
+	{0, 2, 0, 2, 4}, // ';'
+	{1, 2, 4, 2, 1}, // '<'
+	{0, 7, 0, 7, 0}, // '='
+	{4, 2, 1, 2, 4}, // '>'
+	{7, 1, 3, 0, 2}, // '?'
+	{2, 5, 7, 4, 3}, // '@'
+	{2, 5, 7, 5, 5}, // 'A'
+	{6, 5, 6, 5, 6}, // 'B'
+	{3, 4, 4, 4, 3}, // 'C'
+	{6, 5, 5, 5, 6}, // 'D'
+	{7, 4, 6, 4, 7}, // 'E'
+	{7, 4, 6, 4, 4}, // 'F'
+	{3, 4, 5, 5, 3}, // 'G'
+	{5, 5, 7, 5, 5}, // 'H'
+	{7, 2, 2, 2, 7}, // 'I'
+	{1, 1, 1, 5, 2}, // 'J'
+	{5, 5, 6, 5, 5}, // 'K'
+	{4, 4, 4, 4, 7}, // 'L'
+	{5, 7, 7, 5, 5}, // 'M'
+	{6, 5, 5, 5, 5}, // 'N'
+	{2, 5, 5, 5, 2}, // 'O'
+	{6, 5, 6, 4, 4}, // 'P'
+	{2, 5, 5, 6, 3}, // 'Q'
+	{6, 5, 6, 5, 5}, // 'R'
+	{3, 4, 2, 1, 6}, // 'S'
+	{7, 2, 2, 2, 2}, // 'T'
+	{5, 5, 5, 5, 7}, // 'U'
+	{5, 5, 5, 5, 2}, // 'V'
+	{5, 5, 7, 7, 5}, // 'W'
+	{5, 5, 2, 5, 5}, // 'X'
+	{5, 5, 2, 2, 2}, // 'Y'
+	{7, 1, 2, 4, 7}, // 'Z'
+	{3, 2, 2, 2, 3}, // '['
+	{4, 4, 2, 1, 1}, // '\\'
+	{6, 2, 2, 2, 6}, // ']'
+	{2, 5, 0, 0, 0}, // '^'
+	{0, 0, 0, 0, 7}, // '_'
+}
+
+// glyph returns the glyph of a rune, '?' for those without one
+func glyph(r rune) *[GlyphHeight]uint8 {
+	r = unicode.ToUpper(r)
+	if r < 0x20 || r > 0x5F {
+		r = '?'
+	}
+	return &glyphs[r-0x20]
+}
+
+// TextWidth returns the width of text in pixels, with a pixel of spacing
+// after each glyph
+func TextWidth(s string) int {
+	return len([]rune(s)) * glyphAdvance
+}
+
+// DrawText draws text with its top left corner at (x, y), clipped to the
+// image
+func DrawText(img *image.RGBA, x, y int, s string, c color.RGBA) {
+	for _, r := range s {
+		g := glyph(r)
+		for gy, row := range g {
+			for gx := 0; gx < GlyphWidth; gx++ {
+				if row&(4>>uint(gx)) != 0 && (image.Point{x + gx, y + gy}).In(img.Rect) {
+					img.SetRGBA(x+gx, y+gy, c)
+				}
+			}
+		}
+		x += glyphAdvance
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Package osd is the on-screen display shared by the terminal and OpenGL UIs:
+the effective CPU frequency and frame rate, whether the VM is paused or fast
+forwarded, short messages such as from the save slots, and VM errors.
+
+The terminal UI shows it as a status line, and the OpenGL UI draws it over
+the screen with a bitmap font.
+*/
+package osd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+)
+
+const (
+	// FastForwardSpeed is how many times faster than real time the VM runs
+	// when fast forwarded
+	FastForwardSpeed = 4
+	// MessageDuration is how long a message is shown
+	MessageDuration = 2 * time.Second
+	// rateWindow is how often the rates are measured
+	rateWindow = time.Second
+)
+
+type OSD struct {
+	Paused      bool
+	FastForward bool
+	// windowStart is the start of the current rate window, and cpuCycles and
+	// frames the counts in it
+	windowStart time.Time
+	cpuCycles   int
+	frames      int
+	cpuHz, fps  int
+	message     string
+	messageEnd  time.Time
+	err         error
+	// Menu is the lines of the pause menu, drawn centered over the screen
+	// while not nil
+	Menu []string
+	// drawn is the text last drawn by Draw
+	drawn string
+}
+
+func New(now time.Time) *OSD {
+	return &OSD{windowStart: now}
+}
+
+// Speed returns how many times faster than real time the VM runs, 0 when
+// paused
+func (o *OSD) Speed() int {
+	switch {
+	case o.Paused:
+		return 0
+	case o.FastForward:
+		return FastForwardSpeed
+	default:
+		return 1
+	}
+}
+
+// Count adds the CPU cycles run and frames drawn, updating the rates once
+// per second
+func (o *OSD) Count(now time.Time, cpuCycles, frames int) {
+	o.cpuCycles += cpuCycles
+	o.frames += frames
+	if elapsed := now.Sub(o.windowStart); elapsed >= rateWindow {
+		o.cpuHz = int(float64(o.cpuCycles)/elapsed.Seconds() + 0.5)
+		o.fps = int(float64(o.frames)/elapsed.Seconds() + 0.5)
+		o.windowStart, o.cpuCycles, o.frames = now, 0, 0
+	}
+}
+
+// Message shows a message until MessageDuration has passed
+func (o *OSD) Message(now time.Time, message string) {
+	o.message = message
+	o.messageEnd = now.Add(MessageDuration)
+}
+
+// SetError pauses the VM and shows an error until it is resumed
+func (o *OSD) SetError(err error) {
+	o.err = err
+	o.Paused = true
+}
+
+// TogglePause pauses or resumes the VM, clearing any error on resuming
+func (o *OSD) TogglePause() {
+	o.Paused = !o.Paused
+	if !o.Paused {
+		o.err = nil
+	}
+}
+
+// Status returns the rates and the paused or fast forward state
+func (o *OSD) Status() string {
+	status := fmt.Sprintf("%d Hz  %d fps", o.cpuHz, o.fps)
+	switch {
+	case o.Paused:
+		status += "  PAUSED"
+	case o.FastForward:
+		status += fmt.Sprintf("  FAST x%d", FastForwardSpeed)
+	}
+	return status
+}
+
+// Text returns the error or the current message, if any
+func (o *OSD) Text(now time.Time) string {
+	switch {
+	case o.err != nil:
+		return "Error: " + o.err.Error()
+	case now.Before(o.messageEnd):
+		return o.message
+	default:
+		return ""
+	}
+}
+
+// Line returns the status and text on one line, after a title
+func (o *OSD) Line(now time.Time, title string) string {
+	line := title + "  |  " + o.Status()
+	if text := o.Text(now); text != "" {
+		line += "  |  " + text
+	}
+	return line
+}
+
+var (
+	textColor  = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	errorColor = color.RGBA{0xFF, 0x60, 0x40, 0xFF}
+	boxColor   = color.RGBA{0x00, 0x00, 0x00, 0xA0}
+)
+
+/*
+Draw draws the status to the top left corner of a transparent image and the
+text to the bottom left corner, each on a translucent box, and returns false
+without drawing if they are unchanged since the last call.
+*/
+func (o *OSD) Draw(img *image.RGBA, now time.Time) bool {
+	status, text := o.Status(), o.Text(now)
+	drawn := status + "\n" + text + "\n" + strings.Join(o.Menu, "\n")
+	if drawn == o.drawn {
+		return false
+	}
+	o.drawn = drawn
+	for i := range img.Pix {
+		img.Pix[i] = 0
+	}
+	drawBox(img, img.Rect.Min.X, img.Rect.Min.Y, status, textColor)
+	if text != "" {
+		c := textColor
+		if o.err != nil {
+			c = errorColor
+		}
+		drawBox(img, img.Rect.Min.X, img.Rect.Max.Y-GlyphHeight-2, text, c)
+	}
+	if o.Menu != nil {
+		drawMenu(img, o.Menu)
+	}
+	return true
+}
+
+// drawMenu draws lines of text on a box centered in the image
+func drawMenu(img *image.RGBA, lines []string) {
+	width := 0
+	for _, line := range lines {
+		if w := TextWidth(line); w > width {
+			width = w
+		}
+	}
+	lineHeight := GlyphHeight + 2
+	x := img.Rect.Min.X + (img.Rect.Dx()-width)/2
+	y := img.Rect.Min.Y + (img.Rect.Dy()-len(lines)*lineHeight)/2
+	for i, line := range lines {
+		drawBox(img, x, y+i*lineHeight, line+strings.Repeat(" ", (width-TextWidth(line))/glyphAdvance), textColor)
+	}
+}
+
+// drawBox draws text on a box with a margin of one pixel at (x, y)
+func drawBox(img *image.RGBA, x, y int, s string, c color.RGBA) {
+	box := image.Rect(x, y, x+TextWidth(s)+1, y+GlyphHeight+2).Intersect(img.Rect)
+	for by := box.Min.Y; by < box.Max.Y; by++ {
+		for bx := box.Min.X; bx < box.Max.X; bx++ {
+			img.SetRGBA(bx, by, boxColor)
+		}
+	}
+	DrawText(img, x+1, y+1, s, c)
+}
@@ -0,0 +1,132 @@
+package osd
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+func TestOSD(t *testing.T) {
+	start := time.Unix(0, 0)
+	o := New(start)
+	o.Count(start.Add(500*time.Millisecond), 250, 30)
+	if actual := o.Status(); actual != "0 Hz  0 fps" {
+		t.Errorf("Expected no rates before a second, Actual %q", actual)
+	}
+	o.Count(start.Add(time.Second), 250, 30)
+	if actual := o.Status(); actual != "500 Hz  60 fps" {
+		t.Errorf("Expected the rates after a second, Actual %q", actual)
+	}
+
+	o.FastForward = true
+	if actual := o.Status(); !strings.HasSuffix(actual, "FAST x4") || o.Speed() != FastForwardSpeed {
+		t.Errorf("fast forward: Expected FAST x4, Actual %q, speed %d", actual, o.Speed())
+	}
+	o.TogglePause()
+	if actual := o.Status(); !strings.HasSuffix(actual, "PAUSED") || o.Speed() != 0 {
+		t.Errorf("paused: Expected PAUSED, Actual %q, speed %d", actual, o.Speed())
+	}
+	o.TogglePause()
+
+	o.Message(start, "Saved to slot 1")
+	if actual := o.Line(start.Add(time.Second), "CHIP-8: PONG"); actual != "CHIP-8: PONG  |  500 Hz  60 fps  FAST x4  |  Saved to slot 1" {
+		t.Errorf("Unexpected line %q", actual)
+	}
+	if actual := o.Text(start.Add(MessageDuration)); actual != "" {
+		t.Errorf("Expected the message to expire, Actual %q", actual)
+	}
+
+	o.SetError(errors.New("Unsupported op"))
+	if actual := o.Text(start); actual != "Error: Unsupported op" || !o.Paused {
+		t.Errorf("Expected a paused error, Actual %q, paused %v", actual, o.Paused)
+	}
+	o.TogglePause()
+	if actual := o.Text(start.Add(MessageDuration)); actual != "" {
+		t.Errorf("Expected the error cleared on resuming, Actual %q", actual)
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 5))
+	DrawText(img, 0, 0, "1a", color.RGBA{255, 255, 255, 255})
+	var b strings.Builder
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 8; x++ {
+			if img.RGBAAt(x, y).A > 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	expected := strings.TrimLeft(`
+.#...#..
+##..#.#.
+.#..###.
+.#..#.#.
+###.#.#.
+`, "\n")
+	if actual := b.String(); actual != expected {
+		t.Errorf("Expected\n%s\nActual\n%s", expected, actual)
+	}
+	if actual := TextWidth("1a"); actual != 8 {
+		t.Errorf("TextWidth: Expected 8, Actual %d", actual)
+	}
+}
+
+func TestDraw(t *testing.T) {
+	now := time.Unix(0, 0)
+	o := New(now)
+	img := image.NewRGBA(image.Rect(0, 0, 256, 128))
+	if !o.Draw(img, now) {
+		t.Error("Expected the first draw")
+	}
+	if img.RGBAAt(0, 0) != boxColor || img.RGBAAt(255, 127).A != 0 {
+		t.Errorf("Expected only the status box, Actual %v, %v", img.RGBAAt(0, 0), img.RGBAAt(255, 127))
+	}
+	if o.Draw(img, now) {
+		t.Error("Expected no draw when unchanged")
+	}
+	o.Message(now, "Hello")
+	if !o.Draw(img, now) || img.RGBAAt(0, 127) != boxColor {
+		t.Error("Expected the message box in the bottom left corner")
+	}
+	if img.RGBAAt(128, 64).A != 0 {
+		t.Error("Expected no menu")
+	}
+	o.Menu = []string{"> Resume", "  Quit"}
+	if !o.Draw(img, now) || img.RGBAAt(128, 64) != boxColor {
+		t.Error("Expected the menu box in the center")
+	}
+}
+
+func TestSlots(t *testing.T) {
+	var slots Slots
+	vm := chip8.New([]uint8{0x60, 0x05})
+	if actual := slots.Load(vm); actual != "Nothing saved in slot 1" {
+		t.Errorf("Unexpected message %q", actual)
+	}
+	if actual := slots.Save(vm); actual != "Saved to slot 1" {
+		t.Errorf("Unexpected message %q", actual)
+	}
+	vm.Step()
+	vm.TakeDirtyScanLines()
+	if actual := slots.Load(vm); actual != "Loaded from slot 1" {
+		t.Errorf("Unexpected message %q", actual)
+	}
+	if vm.V[0] != 0 || vm.PC != 0x200 || vm.DirtyScanLines == 0 {
+		t.Errorf("Expected the saved state with the screen dirty, Actual V0 %d, PC %#x", vm.V[0], vm.PC)
+	}
+	for i := 0; i < NumSlots; i++ {
+		slots.Next()
+	}
+	if actual := slots.Next(); actual != "Selected slot 2" {
+		t.Errorf("Unexpected message %q", actual)
+	}
+}
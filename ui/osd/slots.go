@@ -0,0 +1,43 @@
+package osd
+
+import "github.com/odsod/chip8"
+
+// NumSlots is the number of save slots
+const NumSlots = 4
+
+// Slots are in-memory save states of a VM, returning the message to show
+// for each action
+type Slots struct {
+	states [NumSlots]*chip8.VM
+	// Selected is the slot saved to and loaded from
+	Selected int
+}
+
+// Save saves the state of a VM to the selected slot
+func (s *Slots) Save(vm *chip8.VM) string {
+	state := *vm
+	s.states[s.Selected] = &state
+	return s.message("Saved to")
+}
+
+// Load restores the state of a VM from the selected slot, if it is not empty
+func (s *Slots) Load(vm *chip8.VM) string {
+	state := s.states[s.Selected]
+	if state == nil {
+		return s.message("Nothing saved in")
+	}
+	*vm = *state
+	// the screen is redrawn from the loaded video memory
+	vm.DirtyScanLines = chip8.AllScanLines
+	return s.message("Loaded from")
+}
+
+// Next selects the next slot
+func (s *Slots) Next() string {
+	s.Selected = (s.Selected + 1) % NumSlots
+	return s.message("Selected")
+}
+
+func (s *Slots) message(action string) string {
+	return action + " slot " + string(rune('1'+s.Selected))
+}
@@ -0,0 +1,106 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+// Display keeps the screen as an image, with lit pixels fading out
+type Display struct {
+	// Buffer is the screen, one pixel per CHIP-8 pixel
+	Buffer        *image.RGBA
+	pixelFadeTime time.Duration
+	pixelLastLit  [chip8.ScreenWidth][chip8.ScreenHeight]time.Time
+	videoMemory   [chip8.ScreenHeight]uint64
+	// fading has a bit set for each scan line with pixels fading out
+	fading uint32
+	theme  Theme
+	// redraw is set when the theme or fade time changed, redrawing every scan
+	// line on the next update
+	redraw bool
+}
+
+func NewDisplay(pixelFadeTime time.Duration) *Display {
+	return &Display{
+		Buffer:        image.NewRGBA(image.Rect(0, 0, chip8.ScreenWidth, chip8.ScreenHeight)),
+		pixelFadeTime: pixelFadeTime,
+		theme:         Themes["paper"],
+	}
+}
+
+// SetTheme changes the colors of the pixels, black on white by default
+func (d *Display) SetTheme(theme Theme) {
+	d.theme = theme
+	d.redraw = true
+}
+
+// SetPixelFadeTime changes the time for a lit pixel to fade out
+func (d *Display) SetPixelFadeTime(pixelFadeTime time.Duration) {
+	d.pixelFadeTime = pixelFadeTime
+	d.redraw = true
+}
+
+// pixelColor returns the color of an unlit pixel, fading from the foreground
+// to the background over fade since it was last lit
+func pixelColor(now, lastLit time.Time, fade time.Duration, theme Theme) color.RGBA {
+	timeSinceLit := now.Sub(lastLit)
+	if timeSinceLit >= fade {
+		return theme.Background
+	}
+	return theme.Blend(1 - float64(timeSinceLit)/float64(fade))
+}
+
+// Update updates the pixels of the dirty and fading scan lines, and returns
+// the scan lines with changed pixels
+func (d *Display) Update(now time.Time, videoMemory [chip8.ScreenHeight]uint64, dirty uint32) uint32 {
+	changed := uint32(0)
+	if d.redraw {
+		dirty, d.redraw = chip8.AllScanLines, false
+	}
+	for y, scanLine := range videoMemory {
+		line := uint32(1) << uint(y)
+		if (dirty|d.fading)&line == 0 {
+			continue
+		}
+		unlit := d.videoMemory[y] &^ scanLine
+		d.fading &^= line
+		for x := 0; x < 64; x++ {
+			pixel := uint64(0x8000000000000000) >> uint(x)
+			if scanLine&pixel > 0 || unlit&pixel > 0 {
+				d.pixelLastLit[x][y] = now
+			}
+			c := d.theme.Foreground
+			if scanLine&pixel == 0 {
+				c = pixelColor(now, d.pixelLastLit[x][y], d.pixelFadeTime, d.theme)
+				if now.Sub(d.pixelLastLit[x][y]) < d.pixelFadeTime {
+					d.fading |= line
+				}
+			}
+			if c != d.Buffer.RGBAAt(x, y) {
+				d.Buffer.SetRGBA(x, y, c)
+				changed |= line
+			}
+		}
+	}
+	d.videoMemory = videoMemory
+	return changed
+}
+
+// ChangedRange returns the first and last changed scan line, and false if
+// none changed
+func ChangedRange(changed uint32) (first, last int, ok bool) {
+	if changed == 0 {
+		return 0, 0, false
+	}
+	first, last = 0, chip8.ScreenHeight-1
+	for changed&(1<<uint(first)) == 0 {
+		first++
+	}
+	for changed&(1<<uint(last)) == 0 {
+		last--
+	}
+	return first, last, true
+}
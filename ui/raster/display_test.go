@@ -0,0 +1,86 @@
+package raster
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+func TestDisplayUpdate(t *testing.T) {
+	start := time.Unix(0, 0)
+	d := NewDisplay(100 * time.Millisecond)
+	var videoMemory [chip8.ScreenHeight]uint64
+	videoMemory[1] = 0x8000000000000000
+	if changed := d.Update(start, videoMemory, chip8.AllScanLines); changed != chip8.AllScanLines {
+		t.Errorf("first frame: Expected all scan lines changed, Actual %x", changed)
+	}
+	if c := d.Buffer.RGBAAt(0, 1); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("lit: Expected black, Actual %v", c)
+	}
+
+	videoMemory[1] = 0
+	if changed := d.Update(start.Add(10*time.Millisecond), videoMemory, 1<<1); changed != 0 {
+		t.Errorf("unlit: Expected no changes, Actual %x", changed)
+	}
+	if changed := d.Update(start.Add(60*time.Millisecond), videoMemory, 0); changed != 1<<1 {
+		t.Errorf("fading: Expected scan line 1 changed, Actual %x", changed)
+	}
+	if c := d.Buffer.RGBAAt(0, 1); c != (color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("fading: Expected gray, Actual %v", c)
+	}
+	d.Update(start.Add(110*time.Millisecond), videoMemory, 0)
+	if changed := d.Update(start.Add(200*time.Millisecond), videoMemory, 0); changed != 0 {
+		t.Errorf("faded: Expected no changes, Actual %x", changed)
+	}
+	if c := d.Buffer.RGBAAt(0, 1); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("faded: Expected white, Actual %v", c)
+	}
+}
+
+func TestDisplayNoFade(t *testing.T) {
+	start := time.Unix(0, 0)
+	d := NewDisplay(0)
+	d.SetTheme(Themes["white"])
+	var videoMemory [chip8.ScreenHeight]uint64
+	videoMemory[1] = 0x8000000000000000
+	d.Update(start, videoMemory, chip8.AllScanLines)
+	if c := d.Buffer.RGBAAt(0, 1); c != Themes["white"].Foreground {
+		t.Errorf("lit: Expected %v, Actual %v", Themes["white"].Foreground, c)
+	}
+	videoMemory[1] = 0
+	if changed := d.Update(start, videoMemory, 1<<1); changed != 1<<1 {
+		t.Errorf("unlit: Expected scan line 1 changed, Actual %x", changed)
+	}
+	if c := d.Buffer.RGBAAt(0, 1); c != Themes["white"].Background {
+		t.Errorf("unlit: Expected %v, Actual %v", Themes["white"].Background, c)
+	}
+}
+
+func TestDisplaySetTheme(t *testing.T) {
+	start := time.Unix(0, 0)
+	d := NewDisplay(100 * time.Millisecond)
+	var videoMemory [chip8.ScreenHeight]uint64
+	videoMemory[1] = 0x8000000000000000
+	d.Update(start, videoMemory, chip8.AllScanLines)
+	d.SetTheme(Themes["amber"])
+	if changed := d.Update(start, videoMemory, 0); changed != chip8.AllScanLines {
+		t.Errorf("Expected all scan lines redrawn, Actual %x", changed)
+	}
+	if c := d.Buffer.RGBAAt(0, 1); c != Themes["amber"].Foreground {
+		t.Errorf("lit: Expected %v, Actual %v", Themes["amber"].Foreground, c)
+	}
+	if c := d.Buffer.RGBAAt(1, 1); c != Themes["amber"].Background {
+		t.Errorf("unlit: Expected %v, Actual %v", Themes["amber"].Background, c)
+	}
+}
+
+func TestChangedRange(t *testing.T) {
+	if _, _, ok := ChangedRange(0); ok {
+		t.Error("Expected no range")
+	}
+	if first, last, ok := ChangedRange(0x0000F010); first != 4 || last != 15 || !ok {
+		t.Errorf("Expected 4 - 15, Actual %d - %d, %v", first, last, ok)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Package raster draws the screen in pure Go, for outputs without OpenGL:
+Display fades the pixels out like the OpenGL UI, and Render scales the result
+to any output size, letterboxed to keep the aspect ratio of the screen.
+*/
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/odsod/chip8"
+)
+
+// Filter is the upscaling filter
+type Filter string
+
+const (
+	// FilterNearest repeats each pixel
+	FilterNearest Filter = "nearest"
+	// FilterScale2x doubles the screen with Scale2x, rounding the corners of
+	// diagonal edges, before repeating the pixels
+	FilterScale2x Filter = "scale2x"
+	// FilterScale4x applies Scale2x twice
+	FilterScale4x Filter = "scale4x"
+)
+
+func ParseFilter(s string) (Filter, error) {
+	switch filter := Filter(s); filter {
+	case FilterNearest, FilterScale2x, FilterScale4x:
+		return filter, nil
+	}
+	return "", fmt.Errorf("Unsupported filter: %s", s)
+}
+
+// Apply returns an image upscaled by the filter, or the image itself for
+// FilterNearest
+func (f Filter) Apply(img *image.RGBA) *image.RGBA {
+	switch f {
+	case FilterScale2x:
+		return scale2x(img)
+	case FilterScale4x:
+		return scale2x(scale2x(img))
+	default:
+		return img
+	}
+}
+
+// Letterbox returns the largest rectangle with the aspect ratio of the screen
+// centered in an output of size (w, h)
+func Letterbox(w, h int) image.Rectangle {
+	if w*chip8.ScreenHeight >= h*chip8.ScreenWidth {
+		sw := h * chip8.ScreenWidth / chip8.ScreenHeight
+		x0 := (w - sw) / 2
+		return image.Rect(x0, 0, x0+sw, h)
+	}
+	sh := w * chip8.ScreenHeight / chip8.ScreenWidth
+	y0 := (h - sh) / 2
+	return image.Rect(0, y0, w, y0+sh)
+}
+
+// Render draws an image of the screen to the whole of dst through a filter,
+// letterboxed with black bars
+func Render(dst, src *image.RGBA, filter Filter) {
+	src = filter.Apply(src)
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	screen := Letterbox(w, h)
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	black := color.RGBA{0, 0, 0, 255}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := black
+			if (image.Point{x, y}).In(screen) {
+				sx := (x - screen.Min.X) * sw / screen.Dx()
+				sy := (y - screen.Min.Y) * sh / screen.Dy()
+				c = src.RGBAAt(src.Rect.Min.X+sx, src.Rect.Min.Y+sy)
+			}
+			dst.SetRGBA(dst.Rect.Min.X+x, dst.Rect.Min.Y+y, c)
+		}
+	}
+}
+
+/*
+scale2x returns an image twice the size with the Scale2x algorithm, which
+splits each pixel P into four from its neighbors A, B, C and D:
+
+	  A        1 2
+	C P B  ->  3 4
+	  D
+
+Each of 1 - 4 is P, unless its two adjacent neighbors are equal and the
+other two are not, in which case it is the color of the neighbors.
+*/
+func scale2x(src *image.RGBA) *image.RGBA {
+	r := src.Rect
+	dst := image.NewRGBA(image.Rect(0, 0, 2*r.Dx(), 2*r.Dy()))
+	at := func(x, y int) color.RGBA {
+		if x < r.Min.X {
+			x = r.Min.X
+		} else if x >= r.Max.X {
+			x = r.Max.X - 1
+		}
+		if y < r.Min.Y {
+			y = r.Min.Y
+		} else if y >= r.Max.Y {
+			y = r.Max.Y - 1
+		}
+		return src.RGBAAt(x, y)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := at(x, y)
+			a, b, c, d := at(x, y-1), at(x+1, y), at(x-1, y), at(x, y+1)
+			e1, e2, e3, e4 := p, p, p, p
+			if c == a && c != d && a != b {
+				e1 = a
+			}
+			if a == b && a != c && b != d {
+				e2 = b
+			}
+			if d == c && d != b && c != a {
+				e3 = c
+			}
+			if b == d && b != a && d != c {
+				e4 = d
+			}
+			dx, dy := 2*(x-r.Min.X), 2*(y-r.Min.Y)
+			dst.SetRGBA(dx, dy, e1)
+			dst.SetRGBA(dx+1, dy, e2)
+			dst.SetRGBA(dx, dy+1, e3)
+			dst.SetRGBA(dx+1, dy+1, e4)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,113 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	if filter, err := ParseFilter("scale2x"); filter != FilterScale2x || err != nil {
+		t.Errorf("ParseFilter(scale2x): Expected scale2x, Actual %v, %v", filter, err)
+	}
+	if _, err := ParseFilter("hq2x"); err == nil {
+		t.Error("ParseFilter(hq2x): Expected an error")
+	}
+}
+
+func TestLetterbox(t *testing.T) {
+	for _, testCase := range []struct {
+		w, h     int
+		expected image.Rectangle
+	}{
+		{64, 32, image.Rect(0, 0, 64, 32)},
+		{640, 320, image.Rect(0, 0, 640, 320)},
+		{800, 320, image.Rect(80, 0, 720, 320)},
+		{640, 480, image.Rect(0, 80, 640, 400)},
+		{65, 32, image.Rect(0, 0, 64, 32)},
+	} {
+		if actual := Letterbox(testCase.w, testCase.h); actual != testCase.expected {
+			t.Errorf("Letterbox(%d, %d): Expected %v, Actual %v", testCase.w, testCase.h, testCase.expected, actual)
+		}
+	}
+}
+
+// parseImage parses an image of '#' (white) and '.' (black) pixels
+func parseImage(s string) *image.RGBA {
+	lines := strings.Fields(s)
+	img := image.NewRGBA(image.Rect(0, 0, len(lines[0]), len(lines)))
+	for y, line := range lines {
+		for x, c := range line {
+			img.SetRGBA(x, y, color.RGBA{0, 0, 0, 255})
+			if c == '#' {
+				img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func formatImage(img *image.RGBA) string {
+	var b strings.Builder
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.RGBAAt(x, y).R > 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestScale2x(t *testing.T) {
+	src := parseImage(`
+		#..
+		.#.
+		..#
+	`)
+	expected := strings.TrimLeft(`
+##....
+#.#...
+.###..
+..###.
+...#.#
+....##
+`, "\n")
+	if actual := formatImage(scale2x(src)); actual != expected {
+		t.Errorf("Expected\n%s\nActual\n%s", expected, actual)
+	}
+	if actual := FilterScale4x.Apply(src).Rect; actual != image.Rect(0, 0, 12, 12) {
+		t.Errorf("scale4x: Expected 12x12, Actual %v", actual)
+	}
+	if actual := FilterNearest.Apply(src); actual != src {
+		t.Error("nearest: Expected the image itself")
+	}
+}
+
+func TestRender(t *testing.T) {
+	src := parseImage(`
+		#.
+		.#
+	`)
+	dst := image.NewRGBA(image.Rect(0, 0, 8, 6))
+	for i := range dst.Pix {
+		dst.Pix[i] = 0xFF
+	}
+	// a 2x2 image is letterboxed like the 2:1 screen, to 8x4
+	Render(dst, src, FilterNearest)
+	expected := strings.TrimLeft(`
+........
+####....
+####....
+....####
+....####
+........
+`, "\n")
+	if actual := formatImage(dst); actual != expected {
+		t.Errorf("Expected\n%s\nActual\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,46 @@
+package raster
+
+import (
+	"image/color"
+	"sort"
+)
+
+// Theme is the colors of lit and unlit pixels
+type Theme struct {
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// Themes are the named themes shared by the terminal and OpenGL UIs
+var Themes = map[string]Theme{
+	"white": {color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, color.RGBA{0x00, 0x00, 0x00, 0xFF}},
+	"paper": {color.RGBA{0x00, 0x00, 0x00, 0xFF}, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+	"green": {color.RGBA{0x33, 0xFF, 0x66, 0xFF}, color.RGBA{0x05, 0x14, 0x08, 0xFF}},
+	"amber": {color.RGBA{0xFF, 0xB0, 0x00, 0xFF}, color.RGBA{0x1A, 0x0E, 0x00, 0xFF}},
+	"lcd":   {color.RGBA{0x0F, 0x38, 0x0F, 0xFF}, color.RGBA{0x9B, 0xBC, 0x0F, 0xFF}},
+	"octo":  {color.RGBA{0xFF, 0xCC, 0x00, 0xFF}, color.RGBA{0x99, 0x66, 0x00, 0xFF}},
+}
+
+// ThemeNames returns the names of the themes in alphabetical order
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Blend returns the color of a pixel with an intensity from 0 (background) to
+// 1 (foreground)
+func (t Theme) Blend(intensity float64) color.RGBA {
+	mix := func(bg, fg uint8) uint8 {
+		return uint8(float64(bg) + (float64(fg)-float64(bg))*intensity + 0.5)
+	}
+	return color.RGBA{
+		mix(t.Background.R, t.Foreground.R),
+		mix(t.Background.G, t.Foreground.G),
+		mix(t.Background.B, t.Foreground.B),
+		0xFF,
+	}
+}
@@ -0,0 +1,207 @@
+package settings
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// Action is what the UI does when an item of the menu is chosen
+type Action int
+
+const (
+	ActionNone Action = iota
+	// ActionResume closes the menu
+	ActionResume
+	// ActionReset restarts the current ROM
+	ActionReset
+	// ActionLoadROM starts the selected ROM
+	ActionLoadROM
+	// ActionSave saves the settings to the user config file
+	ActionSave
+	// ActionQuit exits the UI
+	ActionQuit
+)
+
+// Menu is the pause menu, a list of items that are selected with Up and Down,
+// changed with Left and Right and chosen with Enter
+type Menu struct {
+	Settings Settings
+	opts     MenuOptions
+	// ROMs are the ROM files to switch to, ROM being the selected one
+	ROMs     []string
+	ROM      int
+	Selected int
+	items    []item
+}
+
+type item struct {
+	label  string
+	value  func() string
+	adjust func(delta int)
+	action Action
+}
+
+// MenuOptions are what a UI supports, the items of the others being left out
+type MenuOptions struct {
+	// Themes are the names of the themes to choose from
+	Themes []string
+	// KeyboardLayouts are the layouts to choose from
+	KeyboardLayouts []string
+	// Sound is whether the UI plays sounds
+	Sound bool
+}
+
+// NewMenu returns a menu changing settings, with romFile and the other files
+// of its directory as the ROMs to switch to
+func NewMenu(s Settings, opts MenuOptions, romFile string) *Menu {
+	m := &Menu{Settings: s, opts: opts}
+	m.ROMs, m.ROM = ListROMs(romFile)
+	m.items = []item{
+		{label: "Resume", action: ActionResume},
+		{label: "CPU frequency", value: func() string { return fmt.Sprintf("%d Hz", m.Settings.CPUFrequencyHz) },
+			adjust: func(d int) {
+				m.Settings.CPUFrequencyHz = clamp(m.Settings.CPUFrequencyHz+50*d, MinCPUFrequencyHz, MaxCPUFrequencyHz)
+			}},
+		{label: "Timer frequency", value: func() string { return fmt.Sprintf("%d Hz", m.Settings.TimerFrequencyHz) },
+			adjust: func(d int) {
+				m.Settings.TimerFrequencyHz = clamp(m.Settings.TimerFrequencyHz+10*d, MinTimerFrequencyHz, MaxTimerFrequencyHz)
+			}},
+		{label: "Pixel fade time", value: func() string { return fmt.Sprintf("%d ms", m.Settings.PixelFadeTimeMs) },
+			adjust: func(d int) {
+				m.Settings.PixelFadeTimeMs = clamp(m.Settings.PixelFadeTimeMs+10*d, 0, MaxPixelFadeTimeMs)
+			}},
+		{label: "Colors", value: func() string { return m.Settings.Theme },
+			adjust: func(d int) { m.Settings.Theme = cycle(m.opts.Themes, m.Settings.Theme, d) }},
+		{label: "Display wait", value: func() string { return onOff(m.Settings.DisplayWait) },
+			adjust: func(int) { m.Settings.DisplayWait = !m.Settings.DisplayWait }},
+		{label: "VIP timing", value: func() string { return onOff(m.Settings.VIPTiming) },
+			adjust: func(int) { m.Settings.VIPTiming = !m.Settings.VIPTiming }},
+	}
+	if opts.Sound {
+		m.items = append(m.items, item{label: "Sound", value: func() string { return onOff(m.Settings.Sound) },
+			adjust: func(int) { m.Settings.Sound = !m.Settings.Sound }})
+	}
+	if len(opts.KeyboardLayouts) > 0 {
+		m.items = append(m.items, item{label: "Keyboard", value: func() string { return m.Settings.KeyboardLayout },
+			adjust: func(d int) { m.Settings.KeyboardLayout = cycle(m.opts.KeyboardLayouts, m.Settings.KeyboardLayout, d) }})
+	}
+	m.items = append(m.items,
+		item{label: "ROM", value: func() string { return filepath.Base(m.ROMs[m.ROM]) },
+			adjust: func(d int) { m.ROM = (m.ROM + d + len(m.ROMs)) % len(m.ROMs) }, action: ActionLoadROM},
+		item{label: "Reset", action: ActionReset},
+		item{label: "Save settings", action: ActionSave},
+		item{label: "Quit", action: ActionQuit},
+	)
+	return m
+}
+
+// ListROMs returns the files in the directory of a ROM file in alphabetical
+// order, and the index of the ROM file in them
+func ListROMs(romFile string) ([]string, int) {
+	roms := []string{romFile}
+	dir := filepath.Dir(romFile)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return roms, 0
+	}
+	roms = roms[:0]
+	for _, f := range files {
+		if f.Mode().IsRegular() {
+			roms = append(roms, filepath.Join(dir, f.Name()))
+		}
+	}
+	i := sort.SearchStrings(roms, filepath.Clean(romFile))
+	if i == len(roms) || roms[i] != filepath.Clean(romFile) {
+		roms = append(roms[:i], append([]string{romFile}, roms[i:]...)...)
+	}
+	return roms, i
+}
+
+// SelectedROM returns the ROM file selected in the menu
+func (m *Menu) SelectedROM() string {
+	return m.ROMs[m.ROM]
+}
+
+func (m *Menu) Up() {
+	m.Selected = (m.Selected + len(m.items) - 1) % len(m.items)
+}
+
+func (m *Menu) Down() {
+	m.Selected = (m.Selected + 1) % len(m.items)
+}
+
+func (m *Menu) Left() {
+	m.adjust(-1)
+}
+
+func (m *Menu) Right() {
+	m.adjust(1)
+}
+
+func (m *Menu) adjust(delta int) {
+	if adjust := m.items[m.Selected].adjust; adjust != nil {
+		adjust(delta)
+	}
+}
+
+// Enter chooses the selected item and returns its action, changing it like
+// Right if it has none
+func (m *Menu) Enter() Action {
+	it := m.items[m.Selected]
+	if it.action == ActionNone && it.adjust != nil {
+		it.adjust(1)
+	}
+	return it.action
+}
+
+// Lines returns the items as text, the selected one marked with '>'
+func (m *Menu) Lines() []string {
+	lines := make([]string, len(m.items))
+	for i, it := range m.items {
+		line := "  " + it.label
+		if i == m.Selected {
+			line = "> " + it.label
+		}
+		if it.value != nil {
+			line += ": " + it.value()
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+func clamp(v, min, max int) int {
+	switch {
+	case v < min:
+		return min
+	case v > max:
+		return max
+	default:
+		return v
+	}
+}
+
+// cycle returns the choice delta steps from the current one, starting from
+// the first if current is not a choice
+func cycle(choices []string, current string, delta int) string {
+	if len(choices) == 0 {
+		return current
+	}
+	i := 0
+	for j, choice := range choices {
+		if choice == current {
+			i = (j + delta + len(choices)) % len(choices)
+			break
+		}
+	}
+	return choices[i]
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,106 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// selectItem selects the item with a label, failing if there is none
+func selectItem(t *testing.T, m *Menu, label string) {
+	for i, it := range m.items {
+		if it.label == label {
+			m.Selected = i
+			return
+		}
+	}
+	t.Fatalf("No item %q", label)
+}
+
+func TestMenuAdjust(t *testing.T) {
+	m := NewMenu(Default, MenuOptions{Themes: []string{"amber", "white"}, Sound: true}, "roms/TETRIS")
+	selectItem(t, m, "CPU frequency")
+	m.Right()
+	m.Right()
+	if m.Settings.CPUFrequencyHz != 600 {
+		t.Errorf("Expected 600 Hz, Actual %d Hz", m.Settings.CPUFrequencyHz)
+	}
+	m.Right()
+	m.Right()
+	m.Left()
+	if m.Settings.CPUFrequencyHz != 650 {
+		t.Errorf("Expected 650 Hz, Actual %d Hz", m.Settings.CPUFrequencyHz)
+	}
+	selectItem(t, m, "Sound")
+	m.Right()
+	if m.Settings.Sound {
+		t.Error("Expected the sound toggled off")
+	}
+	selectItem(t, m, "Colors")
+	m.Right()
+	if m.Settings.Theme != "amber" {
+		t.Errorf("Expected amber, Actual %s", m.Settings.Theme)
+	}
+	m.Left()
+	if m.Settings.Theme != "white" {
+		t.Errorf("Expected white, Actual %s", m.Settings.Theme)
+	}
+	selectItem(t, m, "Display wait")
+	if action := m.Enter(); action != ActionNone || !m.Settings.DisplayWait {
+		t.Errorf("Expected display wait toggled on without an action, Actual %v, %v", m.Settings.DisplayWait, action)
+	}
+}
+
+func TestMenuNavigation(t *testing.T) {
+	m := NewMenu(Default, MenuOptions{}, "roms/TETRIS")
+	if action := m.Enter(); action != ActionResume {
+		t.Errorf("Expected ActionResume, Actual %v", action)
+	}
+	m.Up()
+	if action := m.Enter(); action != ActionQuit {
+		t.Errorf("Expected ActionQuit after wrapping around, Actual %v", action)
+	}
+	m.Down()
+	m.Down()
+	lines := m.Lines()
+	if lines[1] != "> CPU frequency: 500 Hz" || lines[0] != "  Resume" {
+		t.Errorf("Unexpected lines %q", lines)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "Keyboard") || strings.Contains(line, "Sound") {
+			t.Errorf("Expected no keyboard and sound items when not supported, Actual %q", line)
+		}
+	}
+}
+
+func TestMenuROMs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"BRIX", "PONG", "TETRIS"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	roms, i := ListROMs(filepath.Join(dir, "PONG"))
+	expected := []string{filepath.Join(dir, "BRIX"), filepath.Join(dir, "PONG"), filepath.Join(dir, "TETRIS")}
+	if !reflect.DeepEqual(roms, expected) || i != 1 {
+		t.Errorf("Expected %v, 1, Actual %v, %d", expected, roms, i)
+	}
+
+	m := NewMenu(Default, MenuOptions{}, filepath.Join(dir, "PONG"))
+	selectItem(t, m, "ROM")
+	m.Right()
+	m.Right()
+	if action := m.Enter(); action != ActionLoadROM || m.SelectedROM() != expected[0] {
+		t.Errorf("Expected ActionLoadROM of %s, Actual %v of %s", expected[0], action, m.SelectedROM())
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Package settings is the user preferences shared by the terminal and OpenGL
+UIs, saved as JSON in the user config directory, and the pause menu changing
+them while a ROM runs.
+
+The UIs load the settings as the defaults of their flags, so flags given on
+the command line override them.
+*/
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/odsod/chip8/ui/raster"
+)
+
+type Settings struct {
+	CPUFrequencyHz   int `json:"cpuFrequencyHz"`
+	TimerFrequencyHz int `json:"timerFrequencyHz"`
+	PixelFadeTimeMs  int `json:"pixelFadeTimeMs"`
+	// Theme is the name of the colors of the screen
+	Theme       string `json:"theme"`
+	VIPTiming   bool   `json:"vipTiming"`
+	DisplayWait bool   `json:"displayWait"`
+	// Sound is whether sounds are played, only by the terminal UI as the
+	// terminal bell
+	Sound bool `json:"sound"`
+	// KeyboardLayout is the name of the mapping of keys to CHIP-8 keys
+	KeyboardLayout string `json:"keyboardLayout"`
+}
+
+// The ranges of the settings, as changed in the menu
+const (
+	MinCPUFrequencyHz   = 50
+	MaxCPUFrequencyHz   = 5000
+	MinTimerFrequencyHz = 10
+	MaxTimerFrequencyHz = 600
+	MaxPixelFadeTimeMs  = 1000
+)
+
+var Default = Settings{
+	CPUFrequencyHz:   500,
+	TimerFrequencyHz: 60,
+	PixelFadeTimeMs:  90,
+	Theme:            "white",
+	Sound:            true,
+	KeyboardLayout:   "qwer",
+}
+
+// DefaultPath returns the path of the settings in the user config directory,
+// or "" if there is none
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chip8", "settings.json")
+}
+
+// Load reads the settings from a file over the defaults, clamped to their
+// ranges and with an unknown theme replaced by the default one. It returns
+// the defaults if the path is "" or the file does not exist, and with an
+// error if the file cannot be read or parsed.
+func Load(path string, defaults Settings) (Settings, error) {
+	if path == "" {
+		return defaults, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return defaults, nil
+	}
+	if err != nil {
+		return defaults, err
+	}
+	s := defaults
+	if err := json.Unmarshal(data, &s); err != nil {
+		return defaults, err
+	}
+	if _, ok := raster.Themes[s.Theme]; !ok {
+		s.Theme = defaults.Theme
+	}
+	return s.Clamp(), nil
+}
+
+// Clamp returns the settings with the values out of their ranges clamped
+func (s Settings) Clamp() Settings {
+	s.CPUFrequencyHz = clamp(s.CPUFrequencyHz, MinCPUFrequencyHz, MaxCPUFrequencyHz)
+	s.TimerFrequencyHz = clamp(s.TimerFrequencyHz, MinTimerFrequencyHz, MaxTimerFrequencyHz)
+	s.PixelFadeTimeMs = clamp(s.PixelFadeTimeMs, 0, MaxPixelFadeTimeMs)
+	return s
+}
+
+// Save writes the settings to a file, creating its directory
+func (s Settings) Save(path string) error {
+	if path == "" {
+		return errors.New("No user config directory")
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,97 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := Load(filepath.Join(dir, "settings.json"), Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != Default {
+		t.Errorf("Expected %+v, Actual %+v", Default, s)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "chip8", "settings.json")
+	expected := Default
+	expected.CPUFrequencyHz = 1000
+	expected.Theme = "amber"
+	expected.DisplayWait = true
+	if err := expected.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := Load(path, Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != expected {
+		t.Errorf("Expected %+v, Actual %+v", expected, actual)
+	}
+}
+
+func TestLoadOverDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "settings.json")
+	if err := ioutil.WriteFile(path, []byte(`{"sound": false}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path, Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Default
+	expected.Sound = false
+	if s != expected {
+		t.Errorf("Expected %+v, Actual %+v", expected, s)
+	}
+	if err := ioutil.WriteFile(path, []byte(`{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := Load(path, Default); err == nil || s != Default {
+		t.Errorf("Expected the defaults and an error for invalid JSON, Actual %+v, %v", s, err)
+	}
+}
+
+func TestLoadInvalidValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "settings.json")
+	data := `{"cpuFrequencyHz": 0, "timerFrequencyHz": 100000, "pixelFadeTimeMs": -5, "theme": "blue"}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path, Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Default
+	expected.CPUFrequencyHz = MinCPUFrequencyHz
+	expected.TimerFrequencyHz = MaxTimerFrequencyHz
+	expected.PixelFadeTimeMs = 0
+	if s != expected {
+		t.Errorf("Expected %+v, Actual %+v", expected, s)
+	}
+}
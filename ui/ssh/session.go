@@ -0,0 +1,400 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/ui/terminal"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// errQuit ends a session when the player quits
+var errQuit = errors.New("quit")
+
+// session runs the ROM picker and a VM for a single SSH session channel
+type session struct {
+	ui      *UI
+	channel gossh.Channel
+	// started is closed when the client requests a shell
+	started chan struct{}
+	// input receives the parsed keyboard input, and is closed on disconnect
+	input chan []inputEvent
+	// resized is signalled when the window size changes
+	resized chan struct{}
+	// done is closed when the session ends
+	done chan struct{}
+
+	mu            sync.Mutex
+	term          string
+	width, height int
+}
+
+func newSession(ui *UI, channel gossh.Channel) *session {
+	return &session{
+		ui:      ui,
+		channel: channel,
+		started: make(chan struct{}),
+		input:   make(chan []inputEvent),
+		resized: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		width:   80,
+		height:  24,
+	}
+}
+
+// ptyRequest is the payload of a pty-req request, RFC 4254 section 6.2
+type ptyRequest struct {
+	Term          string
+	Columns, Rows uint32
+	Width, Height uint32
+	Modes         string
+}
+
+// windowChange is the payload of a window-change request, RFC 4254 section
+// 6.7
+type windowChange struct {
+	Columns, Rows uint32
+	Width, Height uint32
+}
+
+func (s *session) handleRequests(requests <-chan *gossh.Request) {
+	for req := range requests {
+		ok := false
+		switch req.Type {
+		case "pty-req":
+			var pty ptyRequest
+			if ok = gossh.Unmarshal(req.Payload, &pty) == nil; ok {
+				s.mu.Lock()
+				s.term = pty.Term
+				s.mu.Unlock()
+				s.resize(int(pty.Columns), int(pty.Rows))
+			}
+		case "window-change":
+			var change windowChange
+			if ok = gossh.Unmarshal(req.Payload, &change) == nil; ok {
+				s.resize(int(change.Columns), int(change.Rows))
+			}
+		case "shell":
+			ok = true
+			select {
+			case <-s.started:
+			default:
+				close(s.started)
+			}
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+func (s *session) resize(width, height int) {
+	s.mu.Lock()
+	s.width, s.height = width, height
+	s.mu.Unlock()
+	select {
+	case s.resized <- struct{}{}:
+	default:
+	}
+}
+
+func (s *session) size() (width, height int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.width, s.height
+}
+
+// colorMode selects the color mode from the client's terminal type
+func (s *session) colorMode() terminal.ColorMode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return terminal.ColorAuto.Select(func(name string) string {
+		if name == "TERM" {
+			return s.term
+		}
+		return ""
+	})
+}
+
+func (s *session) readInput() {
+	defer close(s.input)
+	buf := make([]byte, 256)
+	for {
+		n, err := s.channel.Read(buf)
+		if n > 0 {
+			select {
+			case s.input <- parseInput(buf[:n]):
+			case <-s.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// run shows the ROM picker and plays the picked ROMs until the player quits
+// or disconnects
+func (s *session) run() error {
+	go s.readInput()
+	select {
+	case <-s.started:
+	case _, ok := <-s.input:
+		if !ok {
+			return io.EOF
+		}
+	}
+	picked := 0
+	for {
+		var err error
+		if picked, err = s.pick(picked); err != nil {
+			return err
+		}
+		err = s.play(s.ui.roms[picked])
+		var romErr *romError
+		if errors.As(err, &romErr) {
+			err = s.showError(romErr.err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// close restores the client's terminal and ends the session
+func (s *session) close() {
+	close(s.done)
+	io.WriteString(s.channel, "\x1b[0m\x1b[2J\x1b[H\x1b[?25h")
+	s.channel.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{0}))
+	s.channel.Close()
+}
+
+// pick shows the ROM picker, starting at a ROM, and returns the picked ROM
+func (s *session) pick(selected int) (int, error) {
+	if len(s.ui.roms) == 0 {
+		io.WriteString(s.channel, "No ROMs to play\r\n")
+		return 0, errQuit
+	}
+	for {
+		if err := s.drawPicker(selected); err != nil {
+			return 0, err
+		}
+		select {
+		case events, ok := <-s.input:
+			if !ok {
+				return 0, io.EOF
+			}
+			for _, ev := range events {
+				switch {
+				case ev.kind == keyQuit || ev.kind == keyRune && ev.ch == 'q':
+					return 0, errQuit
+				case ev.kind == keyEnter:
+					return selected, nil
+				case (ev.kind == keyUp || ev.kind == keyRune && ev.ch == 'k') && selected > 0:
+					selected--
+				case (ev.kind == keyDown || ev.kind == keyRune && ev.ch == 'j') && selected < len(s.ui.roms)-1:
+					selected++
+				}
+			}
+		case <-s.resized:
+		}
+	}
+}
+
+func (s *session) drawPicker(selected int) error {
+	_, height := s.size()
+	// the ROMs scroll to keep the selected one visible between the header and
+	// footer
+	visible := height - 4
+	if visible < 1 {
+		visible = 1
+	}
+	first := 0
+	if selected >= visible {
+		first = selected - visible + 1
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[?25l\x1b[0m\x1b[2J\x1b[H")
+	b.WriteString("CHIP-8: pick a ROM\r\n\r\n")
+	for i := first; i < len(s.ui.roms) && i < first+visible; i++ {
+		if i == selected {
+			fmt.Fprintf(&b, "\x1b[7m> %s\x1b[0m\r\n", s.ui.roms[i])
+		} else {
+			fmt.Fprintf(&b, "  %s\r\n", s.ui.roms[i])
+		}
+	}
+	b.WriteString("\r\nUp/Down: select, Enter: play, q: quit")
+	_, err := io.WriteString(s.channel, b.String())
+	return err
+}
+
+// romError is an error of a ROM that cannot be played, shown before going
+// back to the picker instead of ending the session
+type romError struct {
+	err error
+}
+
+func (e *romError) Error() string {
+	return e.err.Error()
+}
+
+// showError shows an error until the player presses a key
+func (s *session) showError(err error) error {
+	msg := fmt.Sprintf("\x1b[0m\x1b[2J\x1b[HError: %v\r\n\r\nPress any key to go back to the ROMs", err)
+	if _, err := io.WriteString(s.channel, msg); err != nil {
+		return err
+	}
+	for {
+		select {
+		case events, ok := <-s.input:
+			if !ok {
+				return io.EOF
+			}
+			for _, ev := range events {
+				if ev.kind == keyQuit {
+					return errQuit
+				}
+			}
+			if len(events) > 0 {
+				return nil
+			}
+		case <-s.resized:
+		}
+	}
+}
+
+// play runs a ROM until the player presses Esc to go back to the picker, and
+// returns a *romError if the ROM cannot be played
+func (s *session) play(name string) error {
+	rom, err := ioutil.ReadFile(s.ui.romPath(name))
+	if err != nil {
+		return &romError{err}
+	}
+	vm, err := chip8.TryNew(rom)
+	if err != nil {
+		return &romError{err}
+	}
+	opts := s.ui.opts
+	keyboard := terminal.NewKeyboard(opts.KeyPressDuration, terminal.QWER)
+	display := terminal.NewANSIDisplay(s.channel, opts.Theme, s.colorMode(), opts.PixelFadeTime)
+	title := fmt.Sprintf("CHIP-8: %s (Esc: ROMs, Ctrl-C: quit)", name)
+
+	ticker := time.NewTicker(time.Second / time.Duration(opts.FrameRateHz))
+	defer ticker.Stop()
+
+	startTime := time.Now()
+	timerCycles := 0
+	cpuCycles := 0
+
+	for {
+		select {
+		case events, ok := <-s.input:
+			if !ok {
+				return io.EOF
+			}
+			now := time.Now()
+			for _, ev := range events {
+				switch ev.kind {
+				case keyQuit:
+					return errQuit
+				case keyEsc:
+					return nil
+				case keyRune:
+					keyboard.Press(ev.ch, now)
+				}
+			}
+		case now := <-ticker.C:
+			vm.SetKeys(keyboard.Keys(now))
+			runTime := now.Sub(startTime)
+			for i := timerCycles; i < targetUpdates(runTime, opts.TimerFrequencyHz); i++ {
+				vm.TickTimers()
+				timerCycles++
+			}
+			for i := cpuCycles; i < targetUpdates(runTime, opts.CPUFrequencyHz); i++ {
+				if err := vm.TryStep(); err != nil {
+					return &romError{err}
+				}
+				cpuCycles++
+			}
+			width, height := s.size()
+			if err := display.Render(vm, title, terminal.RenderAuto, width, height); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func targetUpdates(runTime time.Duration, updateFrequencyHz int) int {
+	updateInterval := time.Second / time.Duration(updateFrequencyHz)
+	return int(runTime / updateInterval)
+}
+
+type inputKind int
+
+const (
+	keyRune inputKind = iota
+	keyUp
+	keyDown
+	keyEnter
+	keyEsc
+	// keyQuit is Ctrl-C or Ctrl-D
+	keyQuit
+)
+
+type inputEvent struct {
+	kind inputKind
+	ch   rune
+}
+
+// parseInput parses the bytes read from the client's terminal into key
+// presses, ignoring any escape sequences other than the up and down arrows
+func parseInput(data []byte) []inputEvent {
+	var events []inputEvent
+	for len(data) > 0 {
+		switch c := data[0]; {
+		case c == 0x1b && len(data) == 1:
+			events = append(events, inputEvent{kind: keyEsc})
+			data = data[1:]
+		case c == 0x1b && (data[1] == '[' || data[1] == 'O'):
+			// a CSI or SS3 sequence ends with a byte in 0x40 - 0x7E
+			end := 2
+			for end < len(data) && (data[end] < 0x40 || data[end] > 0x7E) {
+				end++
+			}
+			if end < len(data) && end == 2 {
+				switch data[end] {
+				case 'A':
+					events = append(events, inputEvent{kind: keyUp})
+				case 'B':
+					events = append(events, inputEvent{kind: keyDown})
+				}
+			}
+			if end < len(data) {
+				end++
+			}
+			data = data[end:]
+		case c == 0x1b:
+			// Alt and a key
+			data = data[2:]
+		case c == '\r' || c == '\n':
+			events = append(events, inputEvent{kind: keyEnter})
+			data = data[1:]
+		case c == 0x03 || c == 0x04:
+			events = append(events, inputEvent{kind: keyQuit})
+			data = data[1:]
+		default:
+			r, size := utf8.DecodeRune(data)
+			events = append(events, inputEvent{kind: keyRune, ch: r})
+			data = data[size:]
+		}
+	}
+	return events
+}
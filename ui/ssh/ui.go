@@ -0,0 +1,181 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/odsod/chip8/ui/terminal"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+type Options struct {
+	Addr string
+	// HostKeyFile is the server's private key in PEM format, a key being
+	// generated on startup if empty
+	HostKeyFile string
+	// AuthorizedKeysFile lists the public keys of the clients allowed to
+	// connect in authorized_keys format, clients not being authenticated if
+	// empty
+	AuthorizedKeysFile string
+	// RomDir is the directory of ROMs to pick from
+	RomDir           string
+	CPUFrequencyHz   int
+	TimerFrequencyHz int
+	FrameRateHz      int
+	KeyPressDuration time.Duration
+	Theme            terminal.Theme
+	PixelFadeTime    time.Duration
+}
+
+// UI serves the terminal UI over SSH, with a ROM picker and a VM session per
+// connection
+type UI struct {
+	config *gossh.ServerConfig
+	roms   []string
+	opts   Options
+}
+
+func NewUI(opts Options) *UI {
+	files, err := ioutil.ReadDir(opts.RomDir)
+	if err != nil {
+		panic(err)
+	}
+	var roms []string
+	for _, f := range files {
+		if f.Mode().IsRegular() && !strings.HasPrefix(f.Name(), ".") {
+			roms = append(roms, f.Name())
+		}
+	}
+
+	if opts.Theme == (terminal.Theme{}) {
+		opts.Theme = terminal.Themes["white"]
+	}
+
+	hostKey, err := loadHostKey(opts.HostKeyFile)
+	if err != nil {
+		panic(err)
+	}
+	config := &gossh.ServerConfig{NoClientAuth: true}
+	if opts.AuthorizedKeysFile != "" {
+		authorized, err := loadAuthorizedKeys(opts.AuthorizedKeysFile)
+		if err != nil {
+			panic(err)
+		}
+		config.NoClientAuth = false
+		config.PublicKeyCallback = func(_ gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if !authorized[string(key.Marshal())] {
+				return nil, fmt.Errorf("Unauthorized key: %s", gossh.FingerprintSHA256(key))
+			}
+			return nil, nil
+		}
+	}
+	config.AddHostKey(hostKey)
+
+	return &UI{
+		config: config,
+		roms:   roms,
+		opts:   opts,
+	}
+}
+
+// loadHostKey reads a private key, or generates one if there is no file name
+func loadHostKey(name string) (gossh.Signer, error) {
+	if name == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return gossh.NewSignerFromKey(key)
+	}
+	pem, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return gossh.ParsePrivateKey(pem)
+}
+
+// loadAuthorizedKeys reads the public keys of an authorized_keys file, by
+// their wire format
+func loadAuthorizedKeys(name string) (map[string]bool, error) {
+	rest, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for len(strings.TrimSpace(string(rest))) > 0 {
+		key, _, _, next, err := gossh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		keys[string(key.Marshal())] = true
+		rest = next
+	}
+	return keys, nil
+}
+
+func (ui *UI) Run() {
+	l, err := net.Listen("tcp", ui.opts.Addr)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("Serving %d ROMs from %s on ssh://%s", len(ui.roms), ui.opts.RomDir, l.Addr())
+	if ui.config.NoClientAuth {
+		log.Print("Clients are not authenticated")
+	}
+	if err := ui.Serve(l); err != nil {
+		panic(err)
+	}
+}
+
+// Serve accepts connections until the listener is closed
+func (ui *UI) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go ui.serveConn(conn)
+	}
+}
+
+func (ui *UI) serveConn(conn net.Conn) {
+	serverConn, channels, requests, err := gossh.NewServerConn(conn, ui.config)
+	if err != nil {
+		log.Printf("%s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go gossh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			log.Printf("%s: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		s := newSession(ui, channel)
+		go s.handleRequests(channelRequests)
+		go func() {
+			log.Printf("%s: session started", conn.RemoteAddr())
+			err := s.run()
+			s.close()
+			log.Printf("%s: session ended: %v", conn.RemoteAddr(), err)
+		}()
+	}
+}
+
+// romPath returns the path of a ROM picked by name
+func (ui *UI) romPath(name string) string {
+	return filepath.Join(ui.opts.RomDir, name)
+}
@@ -0,0 +1,215 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestParseInput(t *testing.T) {
+	for _, testCase := range []struct {
+		data     string
+		expected []inputEvent
+	}{
+		{"qw", []inputEvent{{keyRune, 'q'}, {keyRune, 'w'}}},
+		{"\x1b", []inputEvent{{kind: keyEsc}}},
+		{"\x1b[A\x1b[B\x1bOA", []inputEvent{{kind: keyUp}, {kind: keyDown}, {kind: keyUp}}},
+		{"\x1b[15~x", []inputEvent{{keyRune, 'x'}}},
+		{"\x1bx", nil},
+		{"\r\x03", []inputEvent{{kind: keyEnter}, {kind: keyQuit}}},
+		{"ö", []inputEvent{{keyRune, 'ö'}}},
+	} {
+		actual := parseInput([]byte(testCase.data))
+		if fmt.Sprint(actual) != fmt.Sprint(testCase.expected) {
+			t.Errorf("parseInput(%q): Expected %v, Actual %v", testCase.data, testCase.expected, actual)
+		}
+	}
+}
+
+// output collects the output of an SSH session
+type output struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.b.Write(p)
+}
+
+// waitFor waits for the output to contain s, and discards the output up to
+// and including it
+func (o *output) waitFor(t *testing.T, s string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		o.mu.Lock()
+		i := strings.Index(o.b.String(), s)
+		if i >= 0 {
+			rest := o.b.String()[i+len(s):]
+			o.b.Reset()
+			o.b.WriteString(rest)
+		}
+		o.mu.Unlock()
+		if i >= 0 {
+			return
+		}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	t.Fatalf("Timed out waiting for %q in %q", s, o.b.String())
+}
+
+// serve serves the ROMs of a directory on a local port until the test ends
+func serve(t *testing.T, opts Options) string {
+	opts.CPUFrequencyHz = 500
+	opts.TimerFrequencyHz = 60
+	opts.FrameRateHz = 60
+	opts.KeyPressDuration = 100 * time.Millisecond
+	ui := NewUI(opts)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go ui.Serve(l)
+	return l.Addr().String()
+}
+
+// startShell starts a shell session in an xterm-256color terminal of 80x24,
+// returning its output and input
+func startShell(t *testing.T, addr string) (*gossh.Session, *output, io.Writer) {
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "player",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &output{}
+	session.Stdout = out
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.RequestPty("xterm-256color", 24, 80, gossh.TerminalModes{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Shell(); err != nil {
+		t.Fatal(err)
+	}
+	return session, out, stdin
+}
+
+// waitForExit waits for a session to end cleanly
+func waitForExit(t *testing.T, session *gossh.Session) {
+	done := make(chan error)
+	go func() { done <- session.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean exit, Actual %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the session to end")
+	}
+}
+
+func TestSession(t *testing.T) {
+	romDir := t.TempDir()
+	rom := []uint8{
+		0xD0, 0x15, // DRW V0, V1, 5
+		0x12, 0x02, // JP 0x202
+	}
+	if err := ioutil.WriteFile(filepath.Join(romDir, "LOOP"), rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	session, out, stdin := startShell(t, serve(t, Options{RomDir: romDir}))
+
+	out.waitFor(t, "> LOOP")
+	io.WriteString(stdin, "\r")
+	out.waitFor(t, "CHIP-8: LOOP")
+	// 256 colors for xterm-256color, and the sprite drawn in half blocks
+	out.waitFor(t, "\x1b[38;5;231m\x1b[48;5;231m▀")
+
+	if err := session.WindowChange(20, 40); err != nil {
+		t.Fatal(err)
+	}
+	out.waitFor(t, "╔"+strings.Repeat("═", 32)+"╗")
+
+	io.WriteString(stdin, "\x1b")
+	out.waitFor(t, "pick a ROM")
+	io.WriteString(stdin, "q")
+	waitForExit(t, session)
+}
+
+func TestSessionROMError(t *testing.T) {
+	romDir := t.TempDir()
+	// RET with an empty stack
+	if err := ioutil.WriteFile(filepath.Join(romDir, "BAD"), []uint8{0x00, 0xEE}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	session, out, stdin := startShell(t, serve(t, Options{RomDir: romDir}))
+
+	out.waitFor(t, "> BAD")
+	io.WriteString(stdin, "\r")
+	out.waitFor(t, "Error: Stack underflow")
+	io.WriteString(stdin, " ")
+	out.waitFor(t, "pick a ROM")
+	io.WriteString(stdin, "q")
+	waitForExit(t, session)
+}
+
+func TestAuthorizedKeys(t *testing.T) {
+	dir := t.TempDir()
+	signers := make([]gossh.Signer, 2)
+	for i := range signers {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signers[i], err = gossh.NewSignerFromKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	authorizedKeys := filepath.Join(dir, "authorized_keys")
+	if err := ioutil.WriteFile(authorizedKeys, gossh.MarshalAuthorizedKey(signers[0].PublicKey()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	addr := serve(t, Options{RomDir: dir, AuthorizedKeysFile: authorizedKeys})
+
+	for i, authorized := range []bool{true, false} {
+		client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+			User:            "player",
+			Auth:            []gossh.AuthMethod{gossh.PublicKeys(signers[i])},
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		})
+		if err == nil {
+			client.Close()
+		}
+		if (err == nil) != authorized {
+			t.Errorf("key %d: Expected authorized %v, Actual error %v", i, authorized, err)
+		}
+	}
+	if _, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "player",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	}); err == nil {
+		t.Error("Expected an error without a key")
+	}
+}
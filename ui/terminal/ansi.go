@@ -0,0 +1,140 @@
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+// moveCursor moves the cursor to (x, y), zero based
+func moveCursor(w io.Writer, x, y int) {
+	fmt.Fprintf(w, "\x1b[%d;%dH", y+1, x+1)
+}
+
+// setColors sets the foreground and background colors in a color mode, the
+// 8 basic colors of Color16 having the terminal's default background
+func setColors(w io.Writer, colorMode ColorMode, fg, bg color.RGBA) {
+	switch colorMode {
+	case ColorTrue:
+		fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm", fg.R, fg.G, fg.B, bg.R, bg.G, bg.B)
+	case Color256:
+		fmt.Fprintf(w, "\x1b[38;5;%dm\x1b[48;5;%dm", xterm256(fg), xterm256(bg))
+	default:
+		fmt.Fprintf(w, "\x1b[%dm\x1b[49m", 30+basicIndex(fg))
+	}
+}
+
+// resetColors resets the colors to the terminal's defaults
+func resetColors(w io.Writer) {
+	io.WriteString(w, "\x1b[0m")
+}
+
+// writeRows writes the changed rows of cells at (x0, y0)
+func writeRows(w io.Writer, s *shading, theme Theme, colorMode ColorMode, mode RenderMode, x0, y0 int, rows []bool) {
+	width, _ := mode.Size()
+	var videoMemory *[chip8.ScreenHeight]uint64
+	if colorMode != ColorTrue && colorMode != Color256 {
+		videoMemory = s.thresholded()
+	}
+	var fgColor, bgColor color.RGBA
+	isWritten := false
+	for y, isChanged := range rows {
+		if !isChanged {
+			continue
+		}
+		isWritten = true
+		moveCursor(w, x0, y0+y)
+		for x := 0; x < width; x++ {
+			var r rune
+			var fg, bg color.RGBA
+			if videoMemory != nil {
+				r, fg, bg = mode.Cell(videoMemory, x, y), theme.Foreground, theme.Background
+			} else {
+				var fgIntensity, bgIntensity float64
+				r, fgIntensity, bgIntensity = mode.Shade(&s.intensities, x, y)
+				fg, bg = theme.blend(fgIntensity), theme.blend(bgIntensity)
+			}
+			if x == 0 || fg != fgColor || bg != bgColor {
+				setColors(w, colorMode, fg, bg)
+				fgColor, bgColor = fg, bg
+			}
+			fmt.Fprintf(w, "%c", r)
+		}
+	}
+	if isWritten {
+		resetColors(w)
+	}
+}
+
+// writeText writes text at (x, y)
+func writeText(w io.Writer, x, y int, s string) {
+	moveCursor(w, x, y)
+	io.WriteString(w, s)
+}
+
+// writeBorder writes a double line border with its top left corner at (x0,
+// y0) and its bottom right corner at (x0+w, y0+h)
+func writeBorder(out io.Writer, x0, y0, w, h int) {
+	horizontal := strings.Repeat("═", w-1)
+	writeText(out, x0, y0, "╔"+horizontal+"╗")
+	for y := y0 + 1; y < y0+h; y++ {
+		writeText(out, x0, y, "║")
+		writeText(out, x0+w, y, "║")
+	}
+	writeText(out, x0, y0+h, "╚"+horizontal+"╝")
+}
+
+/*
+ANSIDisplay draws the screen to any terminal with ANSI escape codes, e.g. over
+a network connection, where Display needs the local terminal. Like Display it
+draws the title and border when the terminal size or render mode changes, and
+after that only the changed rows. The graphics render modes are not
+supported, and draw in RenderAuto.
+*/
+type ANSIDisplay struct {
+	shading
+	out       *bufio.Writer
+	theme     Theme
+	colorMode ColorMode
+	// mode, width and height are those of the last frame
+	mode          RenderMode
+	width, height int
+}
+
+// NewANSIDisplay returns a display writing to w in a theme and color mode,
+// with lit pixels fading out over pixelFadeTime. The color mode must not be
+// ColorAuto.
+func NewANSIDisplay(w io.Writer, theme Theme, colorMode ColorMode, pixelFadeTime time.Duration) *ANSIDisplay {
+	return &ANSIDisplay{
+		shading:   shading{pixelFadeTime: pixelFadeTime},
+		out:       bufio.NewWriter(w),
+		theme:     theme,
+		colorMode: colorMode,
+	}
+}
+
+// Render draws a frame for a terminal of a size, and returns any error
+// writing it
+func (d *ANSIDisplay) Render(vm *chip8.VM, title string, renderMode RenderMode, width, height int) error {
+	changed := d.update(time.Now(), vm.VideoMemory, vm.TakeDirtyScanLines())
+	if renderMode.IsGraphics() {
+		renderMode = RenderAuto
+	}
+	mode := renderMode.Select(width, height)
+	if mode != d.mode || width != d.width || height != d.height {
+		d.mode, d.width, d.height = mode, width, height
+		changed = chip8.AllScanLines
+		// hide the cursor and clear the screen
+		io.WriteString(d.out, "\x1b[?25l\x1b[0m\x1b[2J")
+		writeText(d.out, 0, 0, title)
+		w, h := mode.Size()
+		writeBorder(d.out, 0, 1, w+1, h+1)
+	}
+	writeRows(d.out, &d.shading, d.theme, d.colorMode, mode, 1, 2, changedRows(mode, changed))
+	return d.out.Flush()
+}
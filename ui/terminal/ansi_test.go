@@ -0,0 +1,55 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+func TestANSIDisplay(t *testing.T) {
+	vm := chip8.New([]uint8{
+		0xD0, 0x05, // DRW V0, V0, 5
+	})
+	var b strings.Builder
+	display := NewANSIDisplay(&b, Themes["white"], ColorTrue, 0)
+	if err := display.Render(vm, "CHIP-8: TEST", RenderAuto, 80, 24); err != nil {
+		t.Fatal(err)
+	}
+	first := b.String()
+	for _, expected := range []string{
+		"\x1b[2J",
+		"\x1b[1;1HCHIP-8: TEST",
+		"\x1b[2;1H╔" + strings.Repeat("═", 64) + "╗",
+		"\x1b[19;1H╚",
+		"\x1b[3;2H",
+	} {
+		if !strings.Contains(first, expected) {
+			t.Errorf("First frame: Expected %q in %q", expected, first)
+		}
+	}
+
+	b.Reset()
+	vm.Step()
+	if err := display.Render(vm, "CHIP-8: TEST", RenderAuto, 80, 24); err != nil {
+		t.Fatal(err)
+	}
+	second := b.String()
+	// the 0 sprite drawn at the top left changes the first three rows of cells
+	for _, expected := range []string{"\x1b[3;2H", "\x1b[4;2H", "\x1b[5;2H"} {
+		if !strings.Contains(second, expected) {
+			t.Errorf("Second frame: Expected %q in %q", expected, second)
+		}
+	}
+	if strings.Contains(second, "\x1b[6;2H") || strings.Contains(second, "\x1b[2J") {
+		t.Errorf("Second frame: Expected only the changed rows, Actual %q", second)
+	}
+
+	b.Reset()
+	if err := display.Render(vm, "CHIP-8: TEST", RenderAuto, 40, 24); err != nil {
+		t.Fatal(err)
+	}
+	if resized := b.String(); !strings.Contains(resized, "\x1b[2J") || !strings.Contains(resized, "\x1b[2;1H╔"+strings.Repeat("═", 32)+"╗") {
+		t.Errorf("Resized: Expected a redraw in quadrant mode, Actual %q", resized)
+	}
+}
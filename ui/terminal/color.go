@@ -0,0 +1,173 @@
+package terminal
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/odsod/chip8/ui/raster"
+)
+
+// Theme is the colors of lit and unlit pixels
+type Theme raster.Theme
+
+// Themes are the named themes
+var Themes = func() map[string]Theme {
+	themes := make(map[string]Theme, len(raster.Themes))
+	for name, theme := range raster.Themes {
+		themes[name] = Theme(theme)
+	}
+	return themes
+}()
+
+// ParseTheme returns a named theme, with the foreground and background
+// overridden by hex colors, e.g. #33FF66, unless empty
+func ParseTheme(name, fg, bg string) (Theme, error) {
+	theme, ok := Themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("Unsupported theme: %s", name)
+	}
+	var err error
+	if fg != "" {
+		if theme.Foreground, err = parseHexColor(fg); err != nil {
+			return Theme{}, err
+		}
+	}
+	if bg != "" {
+		if theme.Background, err = parseHexColor(bg); err != nil {
+			return Theme{}, err
+		}
+	}
+	return theme, nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	c := color.RGBA{A: 0xFF}
+	hex := strings.TrimPrefix(s, "#")
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || len(hex) != 6 {
+		return c, fmt.Errorf("Invalid color: %s", s)
+	}
+	return c, nil
+}
+
+// blend returns the color of a pixel with an intensity from 0 (background) to
+// 1 (foreground)
+func (t Theme) blend(intensity float64) color.RGBA {
+	return raster.Theme(t).Blend(intensity)
+}
+
+// ColorMode is how colors are output to the terminal
+type ColorMode string
+
+const (
+	// ColorAuto detects the color mode from the environment
+	ColorAuto ColorMode = "auto"
+	// Color16 uses the 8 basic colors, without fading
+	Color16 ColorMode = "16"
+	// Color256 uses the xterm 256 color palette
+	Color256 ColorMode = "256"
+	// ColorTrue uses 24-bit colors, written as raw ANSI escape codes
+	ColorTrue ColorMode = "truecolor"
+)
+
+// ParseColorMode returns the color mode with a name
+func ParseColorMode(s string) (ColorMode, error) {
+	switch mode := ColorMode(s); mode {
+	case ColorAuto, Color16, Color256, ColorTrue:
+		return mode, nil
+	}
+	return "", fmt.Errorf("Unsupported color mode: %s", s)
+}
+
+// Select resolves ColorAuto from COLORTERM and TERM, as set by the terminal
+func (m ColorMode) Select(getenv func(string) string) ColorMode {
+	if m != ColorAuto {
+		return m
+	}
+	switch colorTerm := getenv("COLORTERM"); {
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		return ColorTrue
+	case strings.Contains(getenv("TERM"), "256color"):
+		return Color256
+	}
+	return Color16
+}
+
+// basicColors are the 8 basic colors, in termbox attribute order
+var basicColors = []color.RGBA{
+	{0x00, 0x00, 0x00, 0xFF},
+	{0xCD, 0x00, 0x00, 0xFF},
+	{0x00, 0xCD, 0x00, 0xFF},
+	{0xCD, 0xCD, 0x00, 0xFF},
+	{0x00, 0x00, 0xEE, 0xFF},
+	{0xCD, 0x00, 0xCD, 0xFF},
+	{0x00, 0xCD, 0xCD, 0xFF},
+	{0xE5, 0xE5, 0xE5, 0xFF},
+}
+
+func distance(a, b color.RGBA) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+// basicIndex returns the index of the nearest basic color
+func basicIndex(c color.RGBA) int {
+	nearest := 0
+	for i, basic := range basicColors {
+		if distance(c, basic) < distance(c, basicColors[nearest]) {
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+// basicAttribute returns the termbox attribute of the nearest basic color
+func basicAttribute(c color.RGBA) termbox.Attribute {
+	return termbox.ColorBlack + termbox.Attribute(basicIndex(c))
+}
+
+// cubeLevels are the levels of the xterm 6x6x6 color cube
+var cubeLevels = []uint8{0x00, 0x5F, 0x87, 0xAF, 0xD7, 0xFF}
+
+func nearestLevel(v uint8) int {
+	nearest := 0
+	for i, level := range cubeLevels {
+		if absDiff(v, level) < absDiff(v, cubeLevels[nearest]) {
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// xterm256 returns the index of the nearest color in the color cube (16 -
+// 231) or the gray ramp (232 - 255) of the xterm palette
+func xterm256(c color.RGBA) int {
+	r, g, b := nearestLevel(c.R), nearestLevel(c.G), nearestLevel(c.B)
+	index := 16 + 36*r + 6*g + b
+	cube := color.RGBA{cubeLevels[r], cubeLevels[g], cubeLevels[b], 0xFF}
+	gray := (int(c.R) + int(c.G) + int(c.B)) / 3
+	step := (gray - 8 + 5) / 10
+	if step < 0 {
+		step = 0
+	} else if step > 23 {
+		step = 23
+	}
+	level := uint8(8 + 10*step)
+	if distance(c, color.RGBA{level, level, level, 0xFF}) < distance(c, cube) {
+		return 232 + step
+	}
+	return index
+}
+
+// attribute256 returns the termbox attribute of a color in Output256 mode
+func attribute256(c color.RGBA) termbox.Attribute {
+	return termbox.Attribute(xterm256(c) + 1)
+}
@@ -0,0 +1,100 @@
+package terminal
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestParseTheme(t *testing.T) {
+	theme, err := ParseTheme("amber", "", "#102030")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Theme{Themes["amber"].Foreground, color.RGBA{0x10, 0x20, 0x30, 0xFF}}
+	if theme != expected {
+		t.Errorf("Expected %v, Actual %v", expected, theme)
+	}
+	if _, err := ParseTheme("blue", "", ""); err == nil {
+		t.Error("Expected an error for an unsupported theme")
+	}
+	if _, err := ParseTheme("green", "#12345", ""); err == nil {
+		t.Error("Expected an error for an invalid color")
+	}
+}
+
+func TestThemeBlend(t *testing.T) {
+	theme := Theme{color.RGBA{0xFF, 0x80, 0x00, 0xFF}, color.RGBA{0x00, 0x00, 0x80, 0xFF}}
+	for _, testCase := range []struct {
+		intensity float64
+		expected  color.RGBA
+	}{
+		{0, theme.Background},
+		{1, theme.Foreground},
+		{0.5, color.RGBA{0x80, 0x40, 0x40, 0xFF}},
+	} {
+		if actual := theme.blend(testCase.intensity); actual != testCase.expected {
+			t.Errorf("blend(%v): Expected %v, Actual %v", testCase.intensity, testCase.expected, actual)
+		}
+	}
+}
+
+func TestColorModeSelect(t *testing.T) {
+	for _, testCase := range []struct {
+		mode      ColorMode
+		colorTerm string
+		term      string
+		expected  ColorMode
+	}{
+		{ColorAuto, "truecolor", "xterm-256color", ColorTrue},
+		{ColorAuto, "24bit", "xterm", ColorTrue},
+		{ColorAuto, "", "xterm-256color", Color256},
+		{ColorAuto, "", "xterm", Color16},
+		{Color16, "truecolor", "xterm-256color", Color16},
+	} {
+		env := map[string]string{"COLORTERM": testCase.colorTerm, "TERM": testCase.term}
+		getenv := func(key string) string { return env[key] }
+		if actual := testCase.mode.Select(getenv); actual != testCase.expected {
+			t.Errorf("%s.Select(%v): Expected %s, Actual %s", testCase.mode, env, testCase.expected, actual)
+		}
+	}
+}
+
+func TestXterm256(t *testing.T) {
+	for _, testCase := range []struct {
+		c        color.RGBA
+		expected int
+	}{
+		{color.RGBA{0x00, 0x00, 0x00, 0xFF}, 16},
+		{color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, 231},
+		{color.RGBA{0xFF, 0x00, 0x00, 0xFF}, 196},
+		{color.RGBA{0x80, 0x80, 0x80, 0xFF}, 244},
+		{color.RGBA{0xFF, 0xB0, 0x00, 0xFF}, 214},
+	} {
+		if actual := xterm256(testCase.c); actual != testCase.expected {
+			t.Errorf("xterm256(%v): Expected %d, Actual %d", testCase.c, testCase.expected, actual)
+		}
+	}
+}
+
+func TestPixelIntensity(t *testing.T) {
+	now := time.Now()
+	fade := 100 * time.Millisecond
+	for _, testCase := range []struct {
+		lastLit  time.Time
+		fade     time.Duration
+		expected float64
+	}{
+		{now, fade, 1},
+		{now.Add(-25 * time.Millisecond), fade, 0.75},
+		{now.Add(-fade), fade, 0},
+		{time.Time{}, fade, 0},
+		// no fade, even for a pixel unlit just now
+		{now, 0, 0},
+		{now.Add(-time.Millisecond), 0, 0},
+	} {
+		if actual := pixelIntensity(now, testCase.lastLit, testCase.fade); actual != testCase.expected {
+			t.Errorf("pixelIntensity(%v ago, %v): Expected %v, Actual %v", now.Sub(testCase.lastLit), testCase.fade, testCase.expected, actual)
+		}
+	}
+}
@@ -1,38 +1,155 @@
 package terminal
 
 import (
+	"bufio"
+	"os"
+	"time"
+
 	termbox "github.com/nsf/termbox-go"
 	"github.com/odsod/chip8"
 )
 
 type Display struct {
+	shading
+	theme     Theme
+	colorMode ColorMode
+	// mode, width and height are those of the last frame, any change
+	// redrawing the whole terminal
+	mode          RenderMode
+	width, height int
+	// out receives the raw escape codes of ColorTrue and the graphics modes
+	out *bufio.Writer
+	// menu is set while the pause menu is drawn over the screen, which the
+	// next Render then redraws
+	menu bool
 }
 
-func NewDisplay() *Display {
-	return &Display{}
+// NewDisplay returns a display drawing in a theme, with lit pixels fading
+// out over pixelFadeTime. The color mode must not be ColorAuto.
+func NewDisplay(theme Theme, colorMode ColorMode, pixelFadeTime time.Duration) *Display {
+	return &Display{
+		shading:   shading{pixelFadeTime: pixelFadeTime},
+		theme:     theme,
+		colorMode: colorMode,
+		out:       bufio.NewWriter(os.Stdout),
+	}
 }
 
-func (display *Display) Render(vm *chip8.VM, conf Conf) {
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
-	renderTitle(0, 0, "CHIP-8: "+conf.RomFile)
-	renderBorder(0, 1, 65, 33, termbox.ColorWhite)
-	for y, scanLine := range vm.VideoMemory {
-		for x := 0; x < 64; x++ {
-			pixel := scanLine&(0x8000000000000000>>uint(x)) > 0
-			var bg termbox.Attribute
-			if pixel {
-				bg = termbox.ColorWhite
-			} else {
-				bg = termbox.ColorDefault
+// SetTheme changes the colors of the screen
+func (display *Display) SetTheme(theme Theme) {
+	display.theme = theme
+}
+
+// SetPixelFadeTime changes the time for a lit pixel to fade out
+func (display *Display) SetPixelFadeTime(pixelFadeTime time.Duration) {
+	display.pixelFadeTime = pixelFadeTime
+}
+
+// Bell rings the terminal bell
+func (display *Display) Bell() {
+	display.out.WriteByte('\a')
+	display.out.Flush()
+}
+
+// Render draws the status line and the scan lines changed since the last
+// frame, or everything when the terminal or render mode changed
+func (display *Display) Render(vm *chip8.VM, conf Conf, status string) {
+	changed := display.update(time.Now(), vm.VideoMemory, vm.TakeDirtyScanLines())
+	width, height := termbox.Size()
+	mode := conf.RenderMode.Select(width, height)
+	if mode != display.mode || width != display.width || height != display.height || display.menu {
+		display.mode, display.width, display.height, display.menu = mode, width, height, false
+		changed = chip8.AllScanLines
+		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+		if !mode.IsGraphics() {
+			w, h := mode.Size()
+			renderBorder(0, 1, w+1, h+1, termbox.ColorWhite)
+		}
+	}
+	renderStatus(0, 0, width, status)
+	if mode.IsGraphics() {
+		termbox.Flush()
+		if changed != 0 {
+			display.renderGraphics(mode, conf.GraphicsScale)
+		}
+		return
+	}
+	rows := changedRows(mode, changed)
+	w, _ := mode.Size()
+	switch display.colorMode {
+	case ColorTrue:
+		// the cells are left empty for termbox, and drawn over after flushing
+		termbox.Flush()
+		writeRows(display.out, &display.shading, display.theme, ColorTrue, mode, 1, 2, rows)
+		display.out.Flush()
+		return
+	case Color256:
+		for y, isChanged := range rows {
+			for x := 0; isChanged && x < w; x++ {
+				r, fg, bg := mode.Shade(&display.intensities, x, y)
+				termbox.SetCell(1+x, 2+y, r,
+					attribute256(display.theme.blend(fg)), attribute256(display.theme.blend(bg)))
+			}
+		}
+	default:
+		videoMemory := display.thresholded()
+		fg := basicAttribute(display.theme.Foreground)
+		for y, isChanged := range rows {
+			for x := 0; isChanged && x < w; x++ {
+				termbox.SetCell(1+x, 2+y, mode.Cell(videoMemory, x, y), fg, termbox.ColorDefault)
 			}
-			termbox.SetCell(1+x, 2+y, ' ', termbox.ColorDefault, bg)
 		}
 	}
 	termbox.Flush()
 }
 
-func renderTitle(x0, y0 int, s string) {
-	for xi, c := range s {
+// RenderMenu draws the status line and the lines of the pause menu in a box
+// centered in the terminal, clearing the screen when the menu is opened
+func (display *Display) RenderMenu(status string, lines []string) {
+	width, height := termbox.Size()
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	renderStatus(0, 0, width, status)
+	w := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > w {
+			w = n
+		}
+	}
+	x0, y0 := (width-w-2)/2, (height-len(lines)-2)/2
+	renderBorder(x0, y0, w+1, len(lines)+1, termbox.ColorWhite)
+	for y, line := range lines {
+		renderStatus(x0+1, y0+1+y, w, line)
+	}
+	if display.menu {
+		termbox.Flush()
+		return
+	}
+	// the raw escape codes and images are not known to termbox
+	display.menu = true
+	termbox.Sync()
+}
+
+// renderGraphics draws the screen as an image below the title
+func (display *Display) renderGraphics(mode RenderMode, scale int) {
+	img := graphicsImage(&display.intensities, display.theme, scale)
+	moveCursor(display.out, 0, 1)
+	if mode == RenderKitty {
+		writeKitty(display.out, img)
+	} else {
+		writeSixel(display.out, img)
+	}
+	display.out.Flush()
+}
+
+// renderStatus draws a status line of a width, truncating or clearing the
+// rest of it
+func renderStatus(x0, y0, width int, s string) {
+	line := []rune(s)
+	for xi := 0; xi < width; xi++ {
+		c := ' '
+		if xi < len(line) {
+			c = line[xi]
+		}
 		termbox.SetCell(x0+xi, y0, c, termbox.ColorWhite, termbox.ColorDefault)
 	}
 }
@@ -0,0 +1,180 @@
+package terminal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/odsod/chip8"
+)
+
+// graphicsShades is the number of pixel intensities in the graphics palette
+const graphicsShades = 16
+
+// graphicsImage returns the screen as a paletted image scaled up by scale,
+// with the pixel intensities quantized to the palette of graphicsShades
+// shades from the background to the foreground
+func graphicsImage(intensities *[chip8.ScreenHeight][chip8.ScreenWidth]float64, theme Theme, scale int) *image.Paletted {
+	palette := make(color.Palette, graphicsShades)
+	for i := range palette {
+		palette[i] = theme.blend(float64(i) / (graphicsShades - 1))
+	}
+	img := image.NewPaletted(image.Rect(0, 0, chip8.ScreenWidth*scale, chip8.ScreenHeight*scale), palette)
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			shade := intensities[y/scale][x/scale]*(graphicsShades-1) + 0.5
+			img.Pix[y*img.Stride+x] = uint8(shade)
+		}
+	}
+	return img
+}
+
+// writeSixel writes an image as Sixel graphics, a band of six rows at a time
+// with a run-length encoded line per color used in the band
+func writeSixel(w io.Writer, img *image.Paletted) {
+	var b strings.Builder
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	fmt.Fprintf(&b, "\x1bP0;0;0q\"1;1;%d;%d", width, height)
+	for i, c := range img.Palette {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xFFFF, g*100/0xFFFF, bl*100/0xFFFF)
+	}
+	for y0 := 0; y0 < height; y0 += 6 {
+		var used [256]bool
+		for y := y0; y < y0+6 && y < height; y++ {
+			for _, index := range img.Pix[y*img.Stride : y*img.Stride+width] {
+				used[index] = true
+			}
+		}
+		first := true
+		for index := range img.Palette {
+			if !used[index] {
+				continue
+			}
+			if !first {
+				b.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&b, "#%d", index)
+			run, last := 0, byte(0)
+			for x := 0; x < width; x++ {
+				sixel := byte(0)
+				for dy := 0; dy < 6 && y0+dy < height; dy++ {
+					if img.Pix[(y0+dy)*img.Stride+x] == uint8(index) {
+						sixel |= 1 << uint(dy)
+					}
+				}
+				if x > 0 && sixel != last {
+					writeSixelRun(&b, run, last)
+					run = 0
+				}
+				run++
+				last = sixel
+			}
+			writeSixelRun(&b, run, last)
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+	io.WriteString(w, b.String())
+}
+
+func writeSixelRun(b *strings.Builder, run int, sixel byte) {
+	c := byte('?') + sixel
+	if run > 3 {
+		fmt.Fprintf(b, "!%d%c", run, c)
+		return
+	}
+	for i := 0; i < run; i++ {
+		b.WriteByte(c)
+	}
+}
+
+// kittyChunkSize is the maximum size of the base64 payload of a kitty
+// graphics escape code
+const kittyChunkSize = 4096
+
+// writeKitty writes an image with the kitty graphics protocol as 24-bit RGB
+// data, replacing the image of the previous frame, without moving the cursor
+func writeKitty(w io.Writer, img *image.Paletted) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	data := make([]byte, 0, 3*width*height)
+	for _, index := range img.Pix {
+		c := img.Palette[index].(color.RGBA)
+		data = append(data, c.R, c.G, c.B)
+	}
+	payload := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for i := 0; i < len(payload); i += kittyChunkSize {
+		end := i + kittyChunkSize
+		more := 1
+		if end >= len(payload) {
+			end, more = len(payload), 0
+		}
+		if i == 0 {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=24,s=%d,v=%d,i=1,p=1,q=2,C=1,m=%d;%s\x1b\\", width, height, more, payload[i:end])
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, payload[i:end])
+		}
+	}
+	io.WriteString(w, b.String())
+}
+
+// graphicsQuery asks for kitty graphics support with a query action, and for
+// the primary device attributes, which all terminals answer and which list
+// Sixel support as attribute 4
+const graphicsQuery = "\x1b_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\\x1b[c"
+
+var deviceAttributes = regexp.MustCompile(`\x1b\[\?([0-9;]*)c`)
+
+// parseGraphicsSupport parses the terminal's response to graphicsQuery, and
+// returns false for ok if it is incomplete
+func parseGraphicsSupport(response string) (kitty, sixel, ok bool) {
+	match := deviceAttributes.FindStringSubmatch(response)
+	if match == nil {
+		return false, false, false
+	}
+	kitty = strings.Contains(response, "\x1b_Gi=31;OK\x1b\\")
+	for _, attribute := range strings.Split(match[1], ";") {
+		if attribute == "4" {
+			sixel = true
+		}
+	}
+	return kitty, sixel, true
+}
+
+// detectGraphics queries the terminal for kitty graphics and Sixel support,
+// reading the response through termbox before the keyboard listens
+func detectGraphics(timeout time.Duration) (kitty, sixel bool) {
+	io.WriteString(os.Stdout, graphicsQuery)
+	timer := time.AfterFunc(timeout, termbox.Interrupt)
+	defer timer.Stop()
+	var response []byte
+	data := make([]byte, 64)
+	for {
+		event := termbox.PollRawEvent(data)
+		if event.Type != termbox.EventRaw {
+			return false, false
+		}
+		response = append(response, data[:event.N]...)
+		if kitty, sixel, ok := parseGraphicsSupport(string(response)); ok {
+			return kitty, sixel
+		}
+	}
+}
+
+// selectGraphics resolves RenderSixel and RenderKitty to RenderAuto if the
+// terminal does not support them
+func selectGraphics(mode RenderMode, kitty, sixel bool) RenderMode {
+	if mode == RenderSixel && !sixel || mode == RenderKitty && !kitty {
+		return RenderAuto
+	}
+	return mode
+}
@@ -0,0 +1,125 @@
+package terminal
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+func TestGraphicsImage(t *testing.T) {
+	var intensities [chip8.ScreenHeight][chip8.ScreenWidth]float64
+	intensities[0][1] = 1
+	intensities[1][0] = 0.5
+	img := graphicsImage(&intensities, Themes["white"], 2)
+	if size := img.Rect.Size(); size != image.Pt(128, 64) {
+		t.Fatalf("Expected size 128x64, Actual %v", size)
+	}
+	for _, testCase := range []struct {
+		x, y     int
+		expected uint8
+	}{
+		{0, 0, 0},
+		{2, 0, graphicsShades - 1},
+		{3, 1, graphicsShades - 1},
+		{1, 3, graphicsShades / 2},
+		{4, 0, 0},
+	} {
+		if actual := img.ColorIndexAt(testCase.x, testCase.y); actual != testCase.expected {
+			t.Errorf("(%d, %d): Expected shade %d, Actual %d", testCase.x, testCase.y, testCase.expected, actual)
+		}
+	}
+}
+
+func TestWriteSixel(t *testing.T) {
+	palette := color.Palette{color.RGBA{0, 0, 0, 0xFF}, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}}
+	for _, testCase := range []struct {
+		msg      string
+		width    int
+		pix      []uint8
+		expected string
+	}{
+		{
+			msg:      "colors",
+			width:    3,
+			pix:      []uint8{0, 1, 1, 0, 0, 1},
+			expected: "\x1bP0;0;0q\"1;1;3;2#0;2;0;0;0#1;2;100;100;100#0BA?$#1?@B-\x1b\\",
+		},
+		{
+			msg:      "run-length encoding",
+			width:    5,
+			pix:      []uint8{1, 1, 1, 1, 1},
+			expected: "\x1bP0;0;0q\"1;1;5;1#0;2;0;0;0#1;2;100;100;100#1!5@-\x1b\\",
+		},
+	} {
+		img := image.NewPaletted(image.Rect(0, 0, testCase.width, len(testCase.pix)/testCase.width), palette)
+		copy(img.Pix, testCase.pix)
+		var b strings.Builder
+		writeSixel(&b, img)
+		if actual := b.String(); actual != testCase.expected {
+			t.Errorf("%s: Expected %q, Actual %q", testCase.msg, testCase.expected, actual)
+		}
+	}
+}
+
+func TestWriteKitty(t *testing.T) {
+	palette := color.Palette{color.RGBA{0, 0, 0, 0xFF}, color.RGBA{0xFF, 0x00, 0x80, 0xFF}}
+	img := image.NewPaletted(image.Rect(0, 0, 2, 1), palette)
+	img.Pix[1] = 1
+	var b strings.Builder
+	writeKitty(&b, img)
+	expected := "\x1b_Ga=T,f=24,s=2,v=1,i=1,p=1,q=2,C=1,m=0;AAAA/wCA\x1b\\"
+	if actual := b.String(); actual != expected {
+		t.Errorf("Expected %q, Actual %q", expected, actual)
+	}
+
+	b.Reset()
+	writeKitty(&b, graphicsImage(&[chip8.ScreenHeight][chip8.ScreenWidth]float64{}, Themes["white"], 4))
+	chunks := strings.Count(b.String(), "\x1b_G")
+	// 256x128 pixels of 3 bytes is 131072 bytes of base64
+	if expected := 131072 / kittyChunkSize; chunks != expected {
+		t.Errorf("Expected %d chunks, Actual %d", expected, chunks)
+	}
+	if !strings.HasSuffix(b.String(), "\x1b_Gm=0;"+strings.Repeat("A", kittyChunkSize)+"\x1b\\") {
+		t.Error("Expected the last chunk to end the image")
+	}
+}
+
+func TestParseGraphicsSupport(t *testing.T) {
+	for _, testCase := range []struct {
+		response           string
+		kitty, sixel, isOK bool
+	}{
+		{"", false, false, false},
+		{"\x1b_Gi=31;OK\x1b\\", false, false, false},
+		{"\x1b_Gi=31;OK\x1b\\\x1b[?62;22c", true, false, true},
+		{"\x1b[?62;4;22c", false, true, true},
+		{"\x1b[?1;2c", false, false, true},
+		{"\x1b[?64;1;2;4;6;9;15;18;21;22c", false, true, true},
+	} {
+		kitty, sixel, ok := parseGraphicsSupport(testCase.response)
+		if kitty != testCase.kitty || sixel != testCase.sixel || ok != testCase.isOK {
+			t.Errorf("%q: Expected %v %v %v, Actual %v %v %v", testCase.response,
+				testCase.kitty, testCase.sixel, testCase.isOK, kitty, sixel, ok)
+		}
+	}
+}
+
+func TestSelectGraphics(t *testing.T) {
+	for _, testCase := range []struct {
+		mode         RenderMode
+		kitty, sixel bool
+		expected     RenderMode
+	}{
+		{RenderKitty, true, false, RenderKitty},
+		{RenderKitty, false, true, RenderAuto},
+		{RenderSixel, false, true, RenderSixel},
+		{RenderSixel, true, false, RenderAuto},
+	} {
+		if actual := selectGraphics(testCase.mode, testCase.kitty, testCase.sixel); actual != testCase.expected {
+			t.Errorf("selectGraphics(%s, %v, %v): Expected %s, Actual %s", testCase.mode, testCase.kitty, testCase.sixel, testCase.expected, actual)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package terminal
 
 import (
+	"sort"
 	"time"
 
 	termbox "github.com/nsf/termbox-go"
@@ -36,6 +37,53 @@ var QWER = map[rune]uint8{
 	'z': 0xA, 'x': 0x0, 'c': 0xB, 'v': 0xF,
 }
 
+// KeyboardLayouts are the keyboard layouts by name
+var KeyboardLayouts = map[string]map[rune]uint8{
+	"qwer":   QWER,
+	"dvorak": Dvorak,
+}
+
+// keyboardLayoutNames returns the names of the keyboard layouts in
+// alphabetical order
+func keyboardLayoutNames() []string {
+	names := make([]string, 0, len(KeyboardLayouts))
+	for name := range KeyboardLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Command is a key press controlling the emulator instead of the VM
+type Command int
+
+const (
+	CommandNone Command = iota
+	// CommandPause is Space, pausing or resuming the VM
+	CommandPause
+	// CommandFastForward is Tab, turning fast forward on or off
+	CommandFastForward
+	// CommandMenu is Esc, opening or closing the pause menu
+	CommandMenu
+	// CommandUp, CommandDown, CommandLeft, CommandRight and CommandEnter are
+	// the arrow keys and Enter, navigating the pause menu
+	CommandUp
+	CommandDown
+	CommandLeft
+	CommandRight
+	CommandEnter
+)
+
+// menuCommands are the keys navigating the pause menu
+var menuCommands = map[termbox.Key]Command{
+	termbox.KeyEsc:        CommandMenu,
+	termbox.KeyArrowUp:    CommandUp,
+	termbox.KeyArrowDown:  CommandDown,
+	termbox.KeyArrowLeft:  CommandLeft,
+	termbox.KeyArrowRight: CommandRight,
+	termbox.KeyEnter:      CommandEnter,
+}
+
 type Keyboard struct {
 	keyMap       map[rune]uint8
 	keyUpDelay   time.Duration
@@ -51,6 +99,11 @@ func NewKeyboard(keyUpDelay time.Duration, keyMap map[rune]uint8) *Keyboard {
 	}
 }
 
+// SetKeyMap changes the characters mapped to CHIP-8 keys
+func (kb *Keyboard) SetKeyMap(keyMap map[rune]uint8) {
+	kb.keyMap = keyMap
+}
+
 func (kb *Keyboard) Listen() {
 	go func() {
 		for {
@@ -59,19 +112,46 @@ func (kb *Keyboard) Listen() {
 	}()
 }
 
-// Check the keyboard state every emulation cycle for which keys are pressed
-func (kb *Keyboard) Check(now time.Time) (keys [16]bool, exit bool) {
+// Check the keyboard state every emulation cycle for which keys are pressed,
+// and which function key (1 - 12) or command was pressed if any. Ctrl-C
+// exits.
+func (kb *Keyboard) Check(now time.Time) (keys [16]bool, functionKey int, command Command, exit bool) {
 	select {
 	case ev := <-kb.eventChannel:
-		if key, ok := kb.keyMap[ev.Ch]; ok {
-			kb.keyUpTimes[key] = now.Add(kb.keyUpDelay)
-		} else if ev.Key == termbox.KeyEsc {
+		switch {
+		case kb.Press(ev.Ch, now):
+		case ev.Key == termbox.KeyCtrlC:
 			exit = true
+		case ev.Ch == 0 && menuCommands[ev.Key] != CommandNone:
+			command = menuCommands[ev.Key]
+		case ev.Ch == 0 && ev.Key == termbox.KeySpace:
+			command = CommandPause
+		case ev.Ch == 0 && ev.Key == termbox.KeyTab:
+			command = CommandFastForward
+		case ev.Ch == 0 && ev.Key <= termbox.KeyF1 && ev.Key >= termbox.KeyF12:
+			// termbox function keys are numbered downwards from F1
+			functionKey = int(termbox.KeyF1-ev.Key) + 1
 		}
 	default: // no events
 	}
+	return kb.Keys(now), functionKey, command, exit
+}
+
+// Press holds down the key mapped to a character for the key press duration,
+// since terminals only report key presses, and returns false if no key is
+// mapped to it
+func (kb *Keyboard) Press(ch rune, now time.Time) bool {
+	key, ok := kb.keyMap[ch]
+	if ok {
+		kb.keyUpTimes[key] = now.Add(kb.keyUpDelay)
+	}
+	return ok
+}
+
+// Keys returns which keys are held down
+func (kb *Keyboard) Keys(now time.Time) (keys [16]bool) {
 	for key := 0; key <= 0xf; key++ {
 		keys[key] = kb.keyUpTimes[key].After(now)
 	}
-	return
+	return keys
 }
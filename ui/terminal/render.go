@@ -0,0 +1,159 @@
+package terminal
+
+import (
+	"fmt"
+
+	"github.com/odsod/chip8"
+)
+
+// RenderMode is how the pixels of the screen are drawn with terminal cells
+type RenderMode string
+
+const (
+	// RenderAuto selects the largest mode fitting the terminal
+	RenderAuto RenderMode = "auto"
+	// RenderBlock draws a cell per pixel, 64x32 cells
+	RenderBlock RenderMode = "block"
+	// RenderHalf draws 1x2 pixels per cell with half blocks, 64x16 cells
+	RenderHalf RenderMode = "half"
+	// RenderQuadrant draws 2x2 pixels per cell with quadrant blocks, 32x16
+	// cells
+	RenderQuadrant RenderMode = "quadrant"
+	// RenderBraille draws 2x4 pixels per cell with braille patterns, 32x8
+	// cells
+	RenderBraille RenderMode = "braille"
+	// RenderSixel draws the screen as Sixel graphics
+	RenderSixel RenderMode = "sixel"
+	// RenderKitty draws the screen with the kitty graphics protocol
+	RenderKitty RenderMode = "kitty"
+)
+
+// autoRenderModes are tried by RenderAuto in order, the square half blocks
+// being preferred over the stretched blocks
+var autoRenderModes = []RenderMode{RenderHalf, RenderQuadrant, RenderBraille}
+
+// ParseRenderMode returns the render mode with a name
+func ParseRenderMode(s string) (RenderMode, error) {
+	switch mode := RenderMode(s); mode {
+	case RenderAuto, RenderBlock, RenderHalf, RenderQuadrant, RenderBraille, RenderSixel, RenderKitty:
+		return mode, nil
+	}
+	return "", fmt.Errorf("Unsupported render mode: %s", s)
+}
+
+// IsGraphics is true for the modes drawing images instead of cells
+func (m RenderMode) IsGraphics() bool {
+	return m == RenderSixel || m == RenderKitty
+}
+
+// cellSize returns the number of pixels per cell
+func (m RenderMode) cellSize() (w, h int) {
+	switch m {
+	case RenderHalf:
+		return 1, 2
+	case RenderQuadrant:
+		return 2, 2
+	case RenderBraille:
+		return 2, 4
+	default:
+		return 1, 1
+	}
+}
+
+// Size returns the number of cells needed for the screen
+func (m RenderMode) Size() (w, h int) {
+	cw, ch := m.cellSize()
+	return chip8.ScreenWidth / cw, chip8.ScreenHeight / ch
+}
+
+// Select resolves RenderAuto to the first mode fitting a terminal of a size,
+// with room for the title and border, or braille if none fits
+func (m RenderMode) Select(width, height int) RenderMode {
+	if m != RenderAuto {
+		return m
+	}
+	for _, mode := range autoRenderModes {
+		if w, h := mode.Size(); w+2 <= width && h+3 <= height {
+			return mode
+		}
+	}
+	return RenderBraille
+}
+
+// The block, half and quadrant runes are indexed by the pixel mask of a cell,
+// with the pixels numbered left to right, top to bottom
+var (
+	blockRunes    = []rune{' ', '█'}
+	halfRunes     = []rune{' ', '▀', '▄', '█'}
+	quadrantRunes = []rune{
+		' ', '▘', '▝', '▀', '▖', '▌', '▞', '▛',
+		'▗', '▚', '▐', '▜', '▄', '▙', '▟', '█',
+	}
+)
+
+// brailleDots are the bits of the braille pattern dots, indexed like the
+// pixel mask
+var brailleDots = []rune{0x01, 0x08, 0x02, 0x10, 0x04, 0x20, 0x40, 0x80}
+
+// mask returns the pixel mask of the cell at (x, y) in cells
+func (m RenderMode) mask(isLit func(x, y int) bool, x, y int) int {
+	cw, ch := m.cellSize()
+	mask := 0
+	for py := 0; py < ch; py++ {
+		for px := 0; px < cw; px++ {
+			if isLit(x*cw+px, y*ch+py) {
+				mask |= 1 << uint(py*cw+px)
+			}
+		}
+	}
+	return mask
+}
+
+// maskRune returns the rune drawing the pixels of a mask
+func (m RenderMode) maskRune(mask int) rune {
+	switch m {
+	case RenderHalf:
+		return halfRunes[mask]
+	case RenderQuadrant:
+		return quadrantRunes[mask]
+	case RenderBraille:
+		r := rune(0x2800)
+		for i, dot := range brailleDots {
+			if mask&(1<<uint(i)) != 0 {
+				r |= dot
+			}
+		}
+		return r
+	default:
+		return blockRunes[mask]
+	}
+}
+
+// Cell returns the rune drawing the cell at (x, y) in cells
+func (m RenderMode) Cell(videoMemory *[chip8.ScreenHeight]uint64, x, y int) rune {
+	return m.maskRune(m.mask(func(x, y int) bool {
+		return videoMemory[y]&(0x8000000000000000>>uint(x)) > 0
+	}, x, y))
+}
+
+// Shade returns the rune and its foreground and background intensities
+// drawing the cell at (x, y) in cells, from pixel intensities between 0
+// (unlit) and 1 (lit). Blocks and half blocks shade each pixel, while the
+// quadrants and braille patterns draw the pixels with any intensity in the
+// brightest one.
+func (m RenderMode) Shade(intensities *[chip8.ScreenHeight][chip8.ScreenWidth]float64, x, y int) (r rune, fg, bg float64) {
+	switch m {
+	case RenderHalf:
+		return '▀', intensities[2*y][x], intensities[2*y+1][x]
+	case RenderQuadrant, RenderBraille:
+		mask := m.mask(func(x, y int) bool {
+			if intensities[y][x] > fg {
+				fg = intensities[y][x]
+			}
+			return intensities[y][x] > 0
+		}, x, y)
+		return m.maskRune(mask), fg, 0
+	default:
+		return ' ', 0, intensities[y][x]
+	}
+}
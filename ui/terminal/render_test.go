@@ -0,0 +1,97 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/odsod/chip8"
+)
+
+func TestRenderModeSelect(t *testing.T) {
+	for _, testCase := range []struct {
+		mode          RenderMode
+		width, height int
+		expected      RenderMode
+	}{
+		{RenderAuto, 80, 24, RenderHalf},
+		{RenderAuto, 66, 19, RenderHalf},
+		{RenderAuto, 40, 19, RenderQuadrant},
+		{RenderAuto, 66, 18, RenderBraille},
+		{RenderAuto, 40, 12, RenderBraille},
+		{RenderAuto, 10, 5, RenderBraille},
+		{RenderBlock, 10, 5, RenderBlock},
+	} {
+		if actual := testCase.mode.Select(testCase.width, testCase.height); actual != testCase.expected {
+			t.Errorf("%s.Select(%d, %d): Expected %s, Actual %s", testCase.mode, testCase.width, testCase.height, testCase.expected, actual)
+		}
+	}
+}
+
+func render(mode RenderMode, videoMemory *[chip8.ScreenHeight]uint64, w, h int) string {
+	var b strings.Builder
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			b.WriteRune(mode.Cell(videoMemory, x, y))
+		}
+		b.WriteRune('\n')
+	}
+	return b.String()
+}
+
+func TestRenderModeCell(t *testing.T) {
+	var videoMemory [chip8.ScreenHeight]uint64
+	// a 4x4 pixel checkerboard of 2x2 squares, and a diagonal line
+	videoMemory[0] = 0xC000000000000000
+	videoMemory[1] = 0xC000000000000000
+	videoMemory[2] = 0x3000000000000000
+	videoMemory[3] = 0x3000000000000000
+	videoMemory[4] = 0x8000000000000000
+	videoMemory[5] = 0x4000000000000000
+	videoMemory[6] = 0x2000000000000000
+	videoMemory[7] = 0x1000000000000000
+	for _, testCase := range []struct {
+		mode     RenderMode
+		w, h     int
+		expected string
+	}{
+		{RenderBlock, 4, 8, "██  \n██  \n  ██\n  ██\n█   \n █  \n  █ \n   █\n"},
+		{RenderHalf, 4, 4, "██  \n  ██\n▀▄  \n  ▀▄\n"},
+		{RenderQuadrant, 2, 4, "█ \n █\n▚ \n ▚\n"},
+		{RenderBraille, 2, 2, "⠛⣤\n⠑⢄\n"},
+	} {
+		if actual := render(testCase.mode, &videoMemory, testCase.w, testCase.h); actual != testCase.expected {
+			t.Errorf("%s: Expected\n%sActual\n%s", testCase.mode, testCase.expected, actual)
+		}
+	}
+}
+
+func TestRenderModeShade(t *testing.T) {
+	var intensities [chip8.ScreenHeight][chip8.ScreenWidth]float64
+	intensities[0][0] = 1
+	intensities[1][0] = 0.25
+	intensities[0][1] = 0.5
+	for _, testCase := range []struct {
+		mode     RenderMode
+		expected string
+	}{
+		{RenderBlock, "' ' 0 1"},
+		{RenderHalf, "'▀' 1 0.25"},
+		{RenderQuadrant, "'▛' 1 0"},
+		{RenderBraille, "'⠋' 1 0"},
+	} {
+		r, fg, bg := testCase.mode.Shade(&intensities, 0, 0)
+		if actual := fmt.Sprintf("%q %v %v", r, fg, bg); actual != testCase.expected {
+			t.Errorf("%s: Expected %s, Actual %s", testCase.mode, testCase.expected, actual)
+		}
+	}
+}
+
+func TestParseRenderMode(t *testing.T) {
+	if mode, err := ParseRenderMode("braille"); err != nil || mode != RenderBraille {
+		t.Errorf("ParseRenderMode(braille): Expected %s, Actual %s, %v", RenderBraille, mode, err)
+	}
+	if _, err := ParseRenderMode("ascii"); err == nil {
+		t.Error("ParseRenderMode(ascii): Expected an error")
+	}
+}
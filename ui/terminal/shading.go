@@ -0,0 +1,90 @@
+package terminal
+
+import (
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+// shading tracks the intensities of the pixels, lit pixels fading out over
+// pixelFadeTime
+type shading struct {
+	pixelFadeTime time.Duration
+	videoMemory   [chip8.ScreenHeight]uint64
+	pixelLastLit  [chip8.ScreenHeight][chip8.ScreenWidth]time.Time
+	intensities   [chip8.ScreenHeight][chip8.ScreenWidth]float64
+	// fading has a bit set for each scan line with pixels fading out
+	fading uint32
+}
+
+// pixelIntensity returns the intensity of an unlit pixel, fading from 1 to 0
+// over fade since it was last lit. A fade of 0 turns the pixel off as soon as
+// it is unlit, since a scan line that is not fading is not updated again.
+func pixelIntensity(now, lastLit time.Time, fade time.Duration) float64 {
+	timeSinceLit := now.Sub(lastLit)
+	if timeSinceLit >= fade {
+		return 0
+	}
+	if timeSinceLit <= 0 {
+		return 1
+	}
+	return 1 - float64(timeSinceLit)/float64(fade)
+}
+
+// update updates the intensities of the dirty and fading scan lines, and
+// returns the scan lines with changed intensities
+func (s *shading) update(now time.Time, videoMemory [chip8.ScreenHeight]uint64, dirty uint32) uint32 {
+	changed := uint32(0)
+	for y, scanLine := range videoMemory {
+		line := uint32(1) << uint(y)
+		if (dirty|s.fading)&line == 0 {
+			continue
+		}
+		unlit := s.videoMemory[y] &^ scanLine
+		s.fading &^= line
+		for x := 0; x < chip8.ScreenWidth; x++ {
+			pixel := uint64(0x8000000000000000) >> uint(x)
+			if unlit&pixel > 0 {
+				s.pixelLastLit[y][x] = now
+			}
+			intensity := 1.0
+			if scanLine&pixel == 0 {
+				intensity = pixelIntensity(now, s.pixelLastLit[y][x], s.pixelFadeTime)
+				if intensity > 0 {
+					s.fading |= line
+				}
+			}
+			if intensity != s.intensities[y][x] {
+				s.intensities[y][x] = intensity
+				changed |= line
+			}
+		}
+	}
+	s.videoMemory = videoMemory
+	return changed
+}
+
+// thresholded returns the pixels at least half lit, for drawing without
+// shades
+func (s *shading) thresholded() *[chip8.ScreenHeight]uint64 {
+	var videoMemory [chip8.ScreenHeight]uint64
+	for y := range s.intensities {
+		for x, intensity := range s.intensities[y] {
+			if intensity >= 0.5 {
+				videoMemory[y] |= 0x8000000000000000 >> uint(x)
+			}
+		}
+	}
+	return &videoMemory
+}
+
+// changedRows returns the rows of cells of a mode drawing changed scan lines
+func changedRows(mode RenderMode, changed uint32) []bool {
+	_, ch := mode.cellSize()
+	_, h := mode.Size()
+	rows := make([]bool, h)
+	for y := range rows {
+		rows[y] = changed>>uint(y*ch)&(1<<uint(ch)-1) != 0
+	}
+	return rows
+}
@@ -0,0 +1,59 @@
+package terminal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+func TestShadingUpdate(t *testing.T) {
+	s := &shading{pixelFadeTime: 100 * time.Millisecond}
+	now := time.Now()
+	var videoMemory [chip8.ScreenHeight]uint64
+	videoMemory[2] = 0x8000000000000000
+	if changed := s.update(now, videoMemory, chip8.AllScanLines); changed != 1<<2 {
+		t.Errorf("Lit: Expected changed 0x%08X, Actual 0x%08X", 1<<2, changed)
+	}
+	if changed := s.update(now, videoMemory, 0); changed != 0 {
+		t.Errorf("Unchanged: Expected changed 0, Actual 0x%08X", changed)
+	}
+	videoMemory[2] = 0
+	if changed := s.update(now, videoMemory, 1<<2); changed != 0 || s.fading != 1<<2 {
+		t.Errorf("Unlit: Expected changed 0 and fading 0x%08X, Actual 0x%08X and 0x%08X", 1<<2, changed, s.fading)
+	}
+	now = now.Add(50 * time.Millisecond)
+	if changed := s.update(now, videoMemory, 0); changed != 1<<2 || s.intensities[2][0] != 0.5 {
+		t.Errorf("Fading: Expected changed 0x%08X and intensity 0.5, Actual 0x%08X and %v", 1<<2, changed, s.intensities[2][0])
+	}
+	now = now.Add(50 * time.Millisecond)
+	if changed := s.update(now, videoMemory, 0); changed != 1<<2 || s.fading != 0 {
+		t.Errorf("Faded: Expected changed 0x%08X and fading 0, Actual 0x%08X and 0x%08X", 1<<2, changed, s.fading)
+	}
+	if changed := s.update(now.Add(time.Second), videoMemory, 0); changed != 0 {
+		t.Errorf("Faded out: Expected changed 0, Actual 0x%08X", changed)
+	}
+}
+
+func TestChangedRows(t *testing.T) {
+	for _, testCase := range []struct {
+		mode     RenderMode
+		changed  uint32
+		expected []int
+	}{
+		{RenderBlock, 1<<0 | 1<<31, []int{0, 31}},
+		{RenderHalf, 1<<1 | 1<<2, []int{0, 1}},
+		{RenderBraille, 1<<3 | 1<<4 | 1<<31, []int{0, 1, 7}},
+	} {
+		var actual []int
+		for y, isChanged := range changedRows(testCase.mode, testCase.changed) {
+			if isChanged {
+				actual = append(actual, y)
+			}
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(testCase.expected) {
+			t.Errorf("%s: Expected rows %v, Actual %v", testCase.mode, testCase.expected, actual)
+		}
+	}
+}
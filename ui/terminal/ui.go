@@ -3,10 +3,14 @@ package terminal
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"time"
 
 	termbox "github.com/nsf/termbox-go"
 	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/ui/osd"
+	"github.com/odsod/chip8/ui/raster"
+	"github.com/odsod/chip8/ui/settings"
 )
 
 type Conf struct {
@@ -17,39 +21,180 @@ type Conf struct {
 	FrameRateHz         int
 	EmulatorFrequencyHz int
 	KeyPressDuration    time.Duration
+	Tracer              chip8.Tracer
+	// Cheats are toggled with F1 - F9 in order
+	Cheats []*chip8.Cheat
+	// VIPTiming runs the VM with COSMAC VIP instruction timing instead of at
+	// CPUFrequencyHz and TimerFrequencyHz
+	VIPTiming bool
+	// DisplayWait makes DRW wait for the next timer tick
+	DisplayWait bool
+	// RenderMode is how the screen is drawn, RenderAuto selecting it by the
+	// terminal size. RenderSixel and RenderKitty fall back to RenderAuto when
+	// the terminal does not support them.
+	RenderMode RenderMode
+	// GraphicsScale is the size of a pixel in RenderSixel and RenderKitty
+	GraphicsScale int
+	// Theme is the colors of the screen, white on black if not set
+	Theme Theme
+	// ColorMode is how colors are output, ColorAuto detecting it from the
+	// environment
+	ColorMode ColorMode
+	// PixelFadeTime is the time for a pixel to fade out, reducing flicker
+	PixelFadeTime time.Duration
+	// ThemeName is the name of the theme in the pause menu
+	ThemeName string
+	// Sound rings the terminal bell when the sound timer starts
+	Sound bool
+	// SettingsPath is where the pause menu saves the settings
+	SettingsPath string
 }
 
 type UI struct {
 	keyboard *Keyboard
 	display  *Display
 	vm       *chip8.VM
-	conf     Conf
+	timing   *chip8.VIPTiming
+	osd      *osd.OSD
+	slots    osd.Slots
+	// menu is the pause menu while it is open
+	menu *settings.Menu
+	conf Conf
 }
 
 func NewUI(conf Conf) *UI {
-	rom, err := ioutil.ReadFile(conf.RomFile)
-	if err != nil {
-		panic(err)
+	keyMap, ok := KeyboardLayouts[conf.KeyboardLayout]
+	if !ok {
+		panic(fmt.Sprintf("Unsupported keyboard layout: %s", conf.KeyboardLayout))
 	}
 
-	var keyMap map[rune]uint8
-	switch conf.KeyboardLayout {
-	case "qwer":
-		keyMap = QWER
-	case "dvorak":
-		keyMap = Dvorak
-	default:
-		panic(fmt.Sprintf("Unsupported keyboard layout: %s", conf.KeyboardLayout))
+	if conf.GraphicsScale < 1 {
+		conf.GraphicsScale = 1
 	}
 
-	return &UI{
+	theme := conf.Theme
+	if theme == (Theme{}) {
+		theme = Themes["white"]
+	}
+
+	ui := &UI{
 		keyboard: NewKeyboard(conf.KeyPressDuration, keyMap),
-		display:  NewDisplay(),
-		vm:       chip8.New(rom),
+		display:  NewDisplay(theme, conf.ColorMode.Select(os.Getenv), conf.PixelFadeTime),
+		osd:      osd.New(time.Now()),
 		conf:     conf,
 	}
+	if err := ui.load(conf.RomFile); err != nil {
+		panic(err)
+	}
+	return ui
+}
+
+// load starts a ROM in a new VM, dropping the cheats and save states of
+// another ROM
+func (ui *UI) load(romFile string) error {
+	rom, err := ioutil.ReadFile(romFile)
+	if err != nil {
+		return err
+	}
+	vm, err := chip8.TryNew(rom)
+	if err != nil {
+		return err
+	}
+	if romFile != ui.conf.RomFile {
+		ui.conf.RomFile, ui.conf.Cheats, ui.slots = romFile, nil, osd.Slots{}
+	}
+	vm.SetTracer(ui.conf.Tracer)
+	vm.SetCheats(ui.conf.Cheats)
+	vm.DisplayWait = ui.conf.DisplayWait
+	// the screen of the previous VM is cleared
+	vm.DirtyScanLines = chip8.AllScanLines
+	ui.vm, ui.timing = vm, chip8.NewVIPTiming(vm)
+	return nil
+}
+
+// settings returns the settings the UI runs with
+func (ui *UI) settings() settings.Settings {
+	return settings.Settings{
+		CPUFrequencyHz:   ui.conf.CPUFrequencyHz,
+		TimerFrequencyHz: ui.conf.TimerFrequencyHz,
+		PixelFadeTimeMs:  int(ui.conf.PixelFadeTime / time.Millisecond),
+		Theme:            ui.conf.ThemeName,
+		VIPTiming:        ui.conf.VIPTiming,
+		DisplayWait:      ui.conf.DisplayWait,
+		Sound:            ui.conf.Sound,
+		KeyboardLayout:   ui.conf.KeyboardLayout,
+	}
 }
 
+// apply changes the settings the UI runs with, keeping the colors overridden
+// by Theme until another theme is chosen
+func (ui *UI) apply(s settings.Settings) {
+	ui.conf.CPUFrequencyHz = s.CPUFrequencyHz
+	ui.conf.TimerFrequencyHz = s.TimerFrequencyHz
+	ui.conf.PixelFadeTime = time.Duration(s.PixelFadeTimeMs) * time.Millisecond
+	ui.display.SetPixelFadeTime(ui.conf.PixelFadeTime)
+	if theme, ok := Themes[s.Theme]; ok && s.Theme != ui.conf.ThemeName {
+		ui.conf.Theme, ui.conf.ThemeName = theme, s.Theme
+		ui.display.SetTheme(theme)
+	}
+	ui.conf.VIPTiming = s.VIPTiming
+	ui.conf.DisplayWait = s.DisplayWait
+	ui.vm.DisplayWait = s.DisplayWait
+	ui.conf.Sound = s.Sound
+	if keyMap, ok := KeyboardLayouts[s.KeyboardLayout]; ok {
+		ui.conf.KeyboardLayout = s.KeyboardLayout
+		ui.keyboard.SetKeyMap(keyMap)
+	}
+}
+
+// menuCommand handles a command while the pause menu is open, and returns
+// whether the menu is still open and whether to quit
+func (ui *UI) menuCommand(now time.Time, command Command) (open, quit bool) {
+	var action settings.Action
+	switch command {
+	case CommandMenu:
+		action = settings.ActionResume
+	case CommandUp:
+		ui.menu.Up()
+	case CommandDown:
+		ui.menu.Down()
+	case CommandLeft:
+		ui.menu.Left()
+	case CommandRight:
+		ui.menu.Right()
+	case CommandEnter:
+		action = ui.menu.Enter()
+	}
+	ui.apply(ui.menu.Settings)
+	switch action {
+	case settings.ActionResume:
+		return false, false
+	case settings.ActionReset, settings.ActionLoadROM:
+		romFile := ui.conf.RomFile
+		if action == settings.ActionLoadROM {
+			romFile = ui.menu.SelectedROM()
+		}
+		if err := ui.load(romFile); err != nil {
+			ui.osd.Message(now, "Error: "+err.Error())
+			return true, false
+		}
+		return false, false
+	case settings.ActionSave:
+		if err := ui.menu.Settings.Save(ui.conf.SettingsPath); err != nil {
+			ui.osd.Message(now, "Error: "+err.Error())
+		} else {
+			ui.osd.Message(now, "Settings saved")
+		}
+	case settings.ActionQuit:
+		return false, true
+	}
+	return true, false
+}
+
+// graphicsDetectTimeout is how long to wait for the terminal to answer the
+// graphics support query
+const graphicsDetectTimeout = 500 * time.Millisecond
+
 func targetUpdates(runTime time.Duration, updateFrequencyHz int) int {
 	updateInterval := time.Second / time.Duration(updateFrequencyHz)
 	return int(runTime / updateInterval)
@@ -61,34 +206,117 @@ func (ui *UI) Run() {
 		panic(err)
 	}
 	defer termbox.Close()
+	if ui.display.colorMode == Color256 {
+		termbox.SetOutputMode(termbox.Output256)
+	}
+	if ui.conf.RenderMode.IsGraphics() {
+		kitty, sixel := detectGraphics(graphicsDetectTimeout)
+		ui.conf.RenderMode = selectGraphics(ui.conf.RenderMode, kitty, sixel)
+	}
 
 	ui.keyboard.Listen()
 
 	startTime := time.Now()
+	lastTime := startTime
+	// runTime is the emulated time, which stops while paused
+	runTime := time.Duration(0)
 	timerCycles := 0
 	cpuCycles := 0
 	frames := 0
+	// sounding is whether the sound timer was running
+	sounding := false
 
 	for {
 		now := time.Now()
-		keys, quit := ui.keyboard.Check(now)
+		keys, functionKey, command, quit := ui.keyboard.Check(now)
 		if quit {
 			return
 		}
+		if ui.menu != nil {
+			open, quit := ui.menuCommand(now, command)
+			if quit {
+				return
+			}
+			if !open {
+				// the emulated time restarts at the frequencies changed
+				ui.menu = nil
+				runTime, timerCycles, cpuCycles = 0, 0, 0
+			}
+			command = CommandNone
+		} else if command == CommandMenu {
+			ui.menu = settings.NewMenu(ui.settings(), settings.MenuOptions{
+				Themes:          raster.ThemeNames(),
+				KeyboardLayouts: keyboardLayoutNames(),
+				Sound:           true,
+			}, ui.conf.RomFile)
+		}
+		switch {
+		case functionKey >= 1 && functionKey <= 9:
+			ui.vm.ToggleCheat(functionKey - 1)
+		case functionKey == 10:
+			ui.osd.Message(now, ui.slots.Save(ui.vm))
+		case functionKey == 11:
+			ui.osd.Message(now, ui.slots.Load(ui.vm))
+		case functionKey == 12:
+			ui.osd.Message(now, ui.slots.Next())
+		}
+		switch command {
+		case CommandPause:
+			ui.osd.TogglePause()
+		case CommandFastForward:
+			ui.osd.FastForward = !ui.osd.FastForward
+		}
 		ui.vm.SetKeys(keys)
-		runTime := now.Sub(startTime)
-		for i := timerCycles; i < targetUpdates(runTime, ui.conf.TimerFrequencyHz); i++ {
-			ui.vm.TickTimers()
-			timerCycles++
+		if ui.menu == nil {
+			runTime += now.Sub(lastTime) * time.Duration(ui.osd.Speed())
+		}
+		lastTime = now
+		steps := 0
+		if ui.conf.VIPTiming {
+			for i := timerCycles; i < targetUpdates(runTime, chip8.VIPFrameRateHz); i++ {
+				n, err := ui.timing.RunFrame()
+				steps += n
+				if err != nil {
+					ui.osd.SetError(err)
+					break
+				}
+				timerCycles++
+			}
+		} else {
+			for i := timerCycles; i < targetUpdates(runTime, ui.conf.TimerFrequencyHz); i++ {
+				ui.vm.TickTimers()
+				timerCycles++
+			}
+			for i := cpuCycles; i < targetUpdates(runTime, ui.conf.CPUFrequencyHz); i++ {
+				if ui.vm.IsWaitingForDisplay {
+					// the cycles until the next timer tick are spent waiting
+					cpuCycles = targetUpdates(runTime, ui.conf.CPUFrequencyHz)
+					break
+				}
+				if err := ui.vm.TryStep(); err != nil {
+					ui.osd.SetError(err)
+					break
+				}
+				cpuCycles++
+				steps++
+			}
 		}
-		for i := cpuCycles; i < targetUpdates(runTime, ui.conf.CPUFrequencyHz); i++ {
-			ui.vm.Step()
-			cpuCycles++
+		if ui.vm.ST > 0 && !sounding && ui.conf.Sound {
+			ui.display.Bell()
 		}
-		for i := frames; i < targetUpdates(runTime, ui.conf.FrameRateHz); i++ {
-			ui.display.Render(ui.vm, ui.conf)
+		sounding = ui.vm.ST > 0
+		rendered := 0
+		title := "CHIP-8: " + ui.conf.RomFile
+		for i := frames; i < targetUpdates(now.Sub(startTime), ui.conf.FrameRateHz); i++ {
+			if ui.menu != nil {
+				ui.display.RenderMenu(ui.osd.Line(now, title), ui.menu.Lines())
+			} else {
+				ui.display.Render(ui.vm, ui.conf, ui.osd.Line(now, title))
+			}
 			frames++
+			rendered++
 		}
+		ui.osd.Count(now, steps, rendered)
 		time.Sleep(time.Second / time.Duration(ui.conf.EmulatorFrequencyHz))
 	}
 }
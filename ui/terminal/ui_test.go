@@ -0,0 +1,83 @@
+package terminal
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+func TestUILoadClearsScreen(t *testing.T) {
+	rom, err := ioutil.TempFile("", "rom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(rom.Name())
+	// JP 0x200
+	if _, err := rom.Write([]byte{0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	rom.Close()
+
+	ui := NewUI(Conf{
+		RomFile:        rom.Name(),
+		KeyboardLayout: "qwer",
+		ColorMode:      Color256,
+		PixelFadeTime:  100 * time.Millisecond,
+	})
+	now := time.Now()
+	ui.vm.VideoMemory[0] = 0x8000000000000000
+	ui.vm.DirtyScanLines = 1
+	ui.display.update(now, ui.vm.VideoMemory, ui.vm.TakeDirtyScanLines())
+	if intensity := ui.display.intensities[0][0]; intensity != 1 {
+		t.Fatalf("Drawn: Expected intensity 1, Actual %v", intensity)
+	}
+	if err := ui.load(ui.conf.RomFile); err != nil {
+		t.Fatal(err)
+	}
+	// the pixels unlit by the reset fade out
+	ui.display.update(now.Add(time.Second), ui.vm.VideoMemory, ui.vm.TakeDirtyScanLines())
+	ui.display.update(now.Add(2*time.Second), ui.vm.VideoMemory, ui.vm.TakeDirtyScanLines())
+	if intensity := ui.display.intensities[0][0]; intensity != 0 {
+		t.Errorf("Reset: Expected intensity 0, Actual %v", intensity)
+	}
+}
+
+func TestUILoadKeepsPatchCheat(t *testing.T) {
+	rom, err := ioutil.TempFile("", "rom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(rom.Name())
+	// JP 0x200
+	if _, err := rom.Write([]byte{0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	rom.Close()
+
+	patch := &chip8.Cheat{
+		Name:   "Patch",
+		Kind:   chip8.CheatPatch,
+		Writes: []chip8.CheatWrite{{Location: chip8.MemoryLocation(0x300), Value: 0xFF}},
+	}
+	ui := NewUI(Conf{
+		RomFile:        rom.Name(),
+		KeyboardLayout: "qwer",
+		ColorMode:      Color256,
+		Cheats:         []*chip8.Cheat{patch},
+	})
+	ui.vm.Memory[0x300] = 0x11
+	ui.vm.ToggleCheat(0)
+	if err := ui.load(ui.conf.RomFile); err != nil {
+		t.Fatal(err)
+	}
+	if ui.vm.Memory[0x300] != 0xFF {
+		t.Fatalf("Expected the patch to be applied after the reset, Actual %#x", ui.vm.Memory[0x300])
+	}
+	ui.vm.ToggleCheat(0)
+	if ui.vm.Memory[0x300] != 0x00 {
+		t.Errorf("Expected the value of the reset ROM to be restored, Actual %#x", ui.vm.Memory[0x300])
+	}
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/odsod/chip8"
+)
+
+//go:embed assets
+var assets embed.FS
+
+type Options struct {
+	RomFile          string
+	Addr             string
+	CPUFrequencyHz   int
+	TimerFrequencyHz int
+	FrameRateHz      int
+}
+
+type UI struct {
+	rom  []uint8
+	opts Options
+}
+
+func NewUI(opts Options) *UI {
+	rom, err := ioutil.ReadFile(opts.RomFile)
+	if err != nil {
+		panic(err)
+	}
+
+	return &UI{
+		rom:  rom,
+		opts: opts,
+	}
+}
+
+// Handler serves the bundled page on / and a VM session per WebSocket
+// connection on /ws
+func (ui *UI) Handler() http.Handler {
+	static, err := fs.Sub(assets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.HandleFunc("/ws", ui.serveSession)
+	return mux
+}
+
+func (ui *UI) Run() {
+	log.Printf("Serving %s on http://%s", ui.opts.RomFile, ui.opts.Addr)
+	if err := http.ListenAndServe(ui.opts.Addr, ui.Handler()); err != nil {
+		panic(err)
+	}
+}
+
+func (ui *UI) serveSession(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrade(w, r)
+	if err != nil {
+		log.Printf("%s: %v", r.RemoteAddr, err)
+		return
+	}
+	defer conn.Close()
+	log.Printf("%s: session started", r.RemoteAddr)
+	vm, err := chip8.TryNew(ui.rom)
+	if err != nil {
+		conn.closeWithError(err)
+		log.Printf("%s: session ended: %v", r.RemoteAddr, err)
+		return
+	}
+	s := &session{vm: vm, conn: conn, opts: ui.opts}
+	if err := s.run(); err != nil {
+		// VM errors are shown on the page, the others being connection
+		// errors
+		var vmErr *chip8.Error
+		if errors.As(err, &vmErr) {
+			conn.closeWithError(err)
+		}
+		log.Printf("%s: session ended: %v", r.RemoteAddr, err)
+	}
+}
+
+func targetUpdates(runTime time.Duration, updateFrequencyHz int) int {
+	updateInterval := time.Second / time.Duration(updateFrequencyHz)
+	return int(runTime / updateInterval)
+}
+
+/*
+Frames sent to the browser are binary messages of 8 bytes per scan line,
+most significant byte first, followed by a single byte that is non-zero while
+the sound timer is active.
+
+Key events sent by the browser are binary messages of two bytes: the CHIP-8 key
+(0x0 - 0xF) and 1 for key down or 0 for key up.
+*/
+const frameSize = chip8.ScreenHeight*8 + 1
+
+// session runs a single VM for a single WebSocket connection
+type session struct {
+	mu   sync.Mutex
+	vm   *chip8.VM
+	conn *websocketConn
+	opts Options
+}
+
+func (s *session) readKeys(errs chan<- error) {
+	for {
+		msg, err := s.conn.readMessage()
+		if err != nil {
+			errs <- err
+			return
+		}
+		if len(msg) != 2 || msg[0] > 0xF {
+			continue
+		}
+		s.mu.Lock()
+		if msg[1] != 0 {
+			s.vm.SetKeyDown(msg[0])
+		} else {
+			s.vm.SetKeyUp(msg[0])
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *session) frame() []byte {
+	frame := make([]byte, 0, frameSize)
+	for _, scanLine := range s.vm.VideoMemory {
+		for shift := 56; shift >= 0; shift -= 8 {
+			frame = append(frame, uint8(scanLine>>uint(shift)))
+		}
+	}
+	if s.vm.ST > 0 {
+		frame = append(frame, 1)
+	} else {
+		frame = append(frame, 0)
+	}
+	return frame
+}
+
+func (s *session) run() error {
+	errs := make(chan error, 1)
+	go s.readKeys(errs)
+
+	ticker := time.NewTicker(time.Second / time.Duration(s.opts.FrameRateHz))
+	defer ticker.Stop()
+
+	startTime := time.Now()
+	timerCycles := 0
+	cpuCycles := 0
+
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case now := <-ticker.C:
+			runTime := now.Sub(startTime)
+			s.mu.Lock()
+			for i := timerCycles; i < targetUpdates(runTime, s.opts.TimerFrequencyHz); i++ {
+				s.vm.TickTimers()
+				timerCycles++
+			}
+			for i := cpuCycles; i < targetUpdates(runTime, s.opts.CPUFrequencyHz); i++ {
+				if err := s.vm.TryStep(); err != nil {
+					s.mu.Unlock()
+					return err
+				}
+				cpuCycles++
+			}
+			frame := s.frame()
+			s.mu.Unlock()
+			if err := s.conn.writeMessage(frame); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// example from RFC 6455 section 1.3
+	expected := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+	if actual := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); actual != expected {
+		t.Errorf("acceptKey(): Expected %s, Actual %s", expected, actual)
+	}
+}
+
+func writeMaskedFrame(w io.Writer, opcode byte, payload []byte) error {
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != 0x80|opBinary || header[1] != 126 {
+		return nil, fmt.Errorf("unexpected frame header: %#v", header)
+	}
+	frame := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	_, err := io.ReadFull(r, frame)
+	return frame, err
+}
+
+func TestSession(t *testing.T) {
+	ui := &UI{
+		rom: []uint8{
+			0xF0, 0x0A, // LD V0, K
+			0xF0, 0x29, // LD F, V0
+			0xD1, 0x15, // DRW V1, V1, 5
+			0x12, 0x06, // JP 0x206
+		},
+		opts: Options{CPUFrequencyHz: 500, TimerFrequencyHz: 60, FrameRateHz: 60},
+	}
+	server := httptest.NewServer(ui.Handler())
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status %d, Actual %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+
+	// wait for the VM to start waiting for a key press
+	if _, err := readFrame(reader); err != nil {
+		t.Fatal(err)
+	}
+	// press and release key 0x8
+	if err := writeMaskedFrame(conn, opBinary, []byte{0x8, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMaskedFrame(conn, opBinary, []byte{0x8, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the "8" digit sprite should eventually be drawn at (0, 0)
+	for i := 0; i < 60; i++ {
+		frame, err := readFrame(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frame) != frameSize {
+			t.Fatalf("Expected frame size %d, Actual %d", frameSize, len(frame))
+		}
+		if frame[0] == 0xF0 && frame[8] == 0x90 && frame[16] == 0xF0 {
+			return
+		}
+	}
+	t.Error("Expected the digit 8 to be drawn")
+}
+
+func TestSessionError(t *testing.T) {
+	ui := &UI{
+		rom:  []uint8{0x00, 0xEE}, // RET with an empty stack
+		opts: Options{CPUFrequencyHz: 500, TimerFrequencyHz: 60, FrameRateHz: 60},
+	}
+	server := httptest.NewServer(ui.Handler())
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	if _, err := http.ReadResponse(reader, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x80|opClose {
+		t.Fatalf("Expected a close frame, Actual header %#v", header)
+	}
+	payload := make([]byte, header[1])
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	if code := binary.BigEndian.Uint16(payload); code != closeInternalError {
+		t.Errorf("Expected close code %d, Actual %d", closeInternalError, code)
+	}
+	if reason := string(payload[2:]); !strings.Contains(reason, "Stack underflow") {
+		t.Errorf("Expected the VM error as reason, Actual %q", reason)
+	}
+}
+
+func TestCloseWithLongError(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	c := &websocketConn{conn: server}
+	// 3 byte runes after 2 bytes, the 41st of which straddles maxCloseReason
+	go c.closeWithError(errors.New("ab" + strings.Repeat("€", 100)))
+
+	reader := bufio.NewReader(client)
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1])
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	reason := payload[2:]
+	if !utf8.Valid(reason) || len(reason) > maxCloseReason {
+		t.Errorf("Expected a valid reason of at most %d bytes, Actual %q", maxCloseReason, reason)
+	}
+	if expected := "ab" + strings.Repeat("€", 40); string(reason) != expected {
+		t.Errorf("Expected %q, Actual %q", expected, reason)
+	}
+}
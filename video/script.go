@@ -0,0 +1,80 @@
+package video
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+Script is the input of a recording, read by ParseScript from lines of a frame
+number followed by the keys held down from that frame on, in hexadecimal, or
+"-" for none:
+
+	# start the game, then move right for a second
+	60  5
+	70  -
+	120 6
+	180 -
+
+The frames must be in increasing order, blank lines and lines starting with #
+being ignored.
+*/
+type Script []ScriptEvent
+
+// ScriptEvent holds down a set of keys from a frame on, with bit k set for
+// key k
+type ScriptEvent struct {
+	Frame int
+	Keys  uint16
+}
+
+func ParseScript(r io.Reader) (Script, error) {
+	var script Script
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Line %d: Expected a frame and keys", line)
+		}
+		frame, err := strconv.Atoi(fields[0])
+		if err != nil || frame < 0 {
+			return nil, fmt.Errorf("Line %d: Invalid frame: %s", line, fields[0])
+		}
+		if len(script) > 0 && frame <= script[len(script)-1].Frame {
+			return nil, fmt.Errorf("Line %d: Frame %d is not after frame %d", line, frame, script[len(script)-1].Frame)
+		}
+		event := ScriptEvent{Frame: frame}
+		for _, key := range fields[1:] {
+			if key == "-" {
+				continue
+			}
+			k, err := strconv.ParseUint(key, 16, 4)
+			if err != nil {
+				return nil, fmt.Errorf("Line %d: Invalid key: %s", line, key)
+			}
+			event.Keys |= 1 << k
+		}
+		script = append(script, event)
+	}
+	return script, scanner.Err()
+}
+
+// Keys returns the keys held down in a frame
+func (s Script) Keys(frame int) [16]bool {
+	var keys [16]bool
+	for _, event := range s {
+		if event.Frame > frame {
+			break
+		}
+		for k := range keys {
+			keys[k] = event.Keys&(1<<uint(k)) != 0
+		}
+	}
+	return keys
+}
@@ -0,0 +1,62 @@
+package video
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseScript(t *testing.T) {
+	script, err := ParseScript(strings.NewReader(`
+# start
+10 5
+20 5 a
+
+30 -
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Script{{10, 1 << 5}, {20, 1<<5 | 1<<0xA}, {30, 0}}
+	if len(script) != len(expected) {
+		t.Fatalf("Expected %v, Actual %v", expected, script)
+	}
+	for i := range expected {
+		if script[i] != expected[i] {
+			t.Errorf("Event %d: Expected %v, Actual %v", i, expected[i], script[i])
+		}
+	}
+	for _, testCase := range []struct {
+		frame    int
+		expected []int
+	}{
+		{0, nil},
+		{10, []int{5}},
+		{25, []int{5, 0xA}},
+		{100, nil},
+	} {
+		keys := script.Keys(testCase.frame)
+		var actual []int
+		for k, down := range keys {
+			if down {
+				actual = append(actual, k)
+			}
+		}
+		if len(actual) != len(testCase.expected) || len(actual) > 0 && actual[0] != testCase.expected[0] {
+			t.Errorf("Keys(%d): Expected %v, Actual %v", testCase.frame, testCase.expected, actual)
+		}
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	for _, s := range []string{
+		"10",
+		"x 5",
+		"-1 5",
+		"10 g",
+		"10 5\n10 6",
+	} {
+		if _, err := ParseScript(strings.NewReader(s)); err == nil {
+			t.Errorf("ParseScript(%q): Expected an error", s)
+		}
+	}
+}
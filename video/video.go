@@ -0,0 +1,128 @@
+/*
+Package video records a ROM to a video, deterministically and without a
+window: the VM runs in emulated time at 60 frames per second with the keys of
+an input Script, each frame being drawn like the OpenGL UI into a Y4M stream,
+and the sound timer played as a tone into a WAV track of the same length.
+
+	ffmpeg -i game.y4m -i game.wav -c:v libx264 -pix_fmt yuv420p game.mp4
+*/
+package video
+
+import (
+	"image"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/odsod/chip8"
+	"github.com/odsod/chip8/ui/raster"
+)
+
+// FrameRateHz is the frame rate of the recordings, and the timer frequency
+const FrameRateHz = 60
+
+type Options struct {
+	// Frames is the length of the recording
+	Frames         int
+	CPUFrequencyHz int
+	// Width and Height are the size of the video, which must be even
+	Width, Height int
+	Filter        raster.Filter
+	PixelFadeTime time.Duration
+	// Seed seeds the random source of the VM
+	Seed int64
+	// DisplayWait makes DRW wait for the next frame
+	DisplayWait bool
+}
+
+var DefaultOptions = Options{
+	Frames:         600,
+	CPUFrequencyHz: 500,
+	Width:          640,
+	Height:         320,
+	Filter:         raster.FilterNearest,
+	PixelFadeTime:  90 * time.Millisecond,
+	Seed:           1,
+}
+
+type seededRandom struct {
+	rand *rand.Rand
+}
+
+func (r seededRandom) Next() uint8 {
+	return uint8(r.rand.Uint32())
+}
+
+/*
+Record runs a ROM with the keys of a script, writing the frames to video and
+the sound to audio. Either may be nil to skip it. It returns the VM after the
+last frame, or an error if the VM cannot step or writing fails.
+*/
+func Record(rom []uint8, script Script, video io.Writer, audio io.WriteSeeker, opts Options) (*chip8.VM, error) {
+	vm, err := chip8.TryNew(rom)
+	if err != nil {
+		return nil, err
+	}
+	vm.SetRandom(seededRandom{rand.New(rand.NewSource(opts.Seed))})
+	vm.DisplayWait = opts.DisplayWait
+
+	var y4m *Y4MWriter
+	if video != nil {
+		if y4m, err = NewY4MWriter(video, opts.Width, opts.Height, FrameRateHz); err != nil {
+			return nil, err
+		}
+	}
+	var wav *WAVWriter
+	if audio != nil {
+		if wav, err = NewWAVWriter(audio); err != nil {
+			return nil, err
+		}
+	}
+	display := raster.NewDisplay(opts.PixelFadeTime)
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	// the emulated time of the fading, from the Unix epoch
+	start := time.Unix(0, 0)
+
+	cpuCycles := 0
+	for frame := 0; frame < opts.Frames; frame++ {
+		// the keys are pressed one by one to end any wait for a key press
+		for key, down := range script.Keys(frame) {
+			switch {
+			case down && !vm.Keys[key]:
+				vm.SetKeyDown(uint8(key))
+			case !down && vm.Keys[key]:
+				vm.SetKeyUp(uint8(key))
+			}
+		}
+		vm.TickTimers()
+		for ; cpuCycles < (frame+1)*opts.CPUFrequencyHz/FrameRateHz; cpuCycles++ {
+			if err := vm.TryStep(); err != nil {
+				return vm, err
+			}
+		}
+		if y4m != nil {
+			now := start.Add(time.Duration(frame) * time.Second / FrameRateHz)
+			display.Update(now, vm.VideoMemory, vm.TakeDirtyScanLines())
+			raster.Render(img, display.Buffer, opts.Filter)
+			if err := y4m.WriteFrame(img); err != nil {
+				return vm, err
+			}
+		}
+		if wav != nil {
+			if err := wav.WriteTone(SampleRateHz/FrameRateHz, vm.ST > 0); err != nil {
+				return vm, err
+			}
+		}
+	}
+	if y4m != nil {
+		if err := y4m.Flush(); err != nil {
+			return vm, err
+		}
+	}
+	if wav != nil {
+		if err := wav.Close(); err != nil {
+			return vm, err
+		}
+	}
+	return vm, nil
+}
@@ -0,0 +1,169 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/odsod/chip8/ui/raster"
+)
+
+func TestY4MWriter(t *testing.T) {
+	if _, err := NewY4MWriter(&bytes.Buffer{}, 3, 2, 60); err == nil {
+		t.Error("Expected an error for an odd width")
+	}
+	var b bytes.Buffer
+	y4m, err := NewY4MWriter(&b, 2, 2, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{255, 255, 255, 255})
+	img.SetRGBA(1, 1, color.RGBA{255, 255, 255, 255})
+	if err := y4m.WriteFrame(img); err != nil {
+		t.Fatal(err)
+	}
+	if err := y4m.WriteFrame(image.NewRGBA(image.Rect(0, 0, 4, 4))); err == nil {
+		t.Error("Expected an error for a frame of the wrong size")
+	}
+	y4m.Flush()
+	expected := "YUV4MPEG2 W2 H2 F60:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\nFRAME\n" +
+		"\xFF\x00\x00\xFF" + "\x80" + "\x80"
+	if actual := b.String(); actual != expected {
+		t.Errorf("Expected %q, Actual %q", expected, actual)
+	}
+}
+
+func TestWAVWriter(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	wav, err := NewWAVWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wav.WriteTone(60, true)
+	wav.WriteTone(20, false)
+	wav.WriteTone(40, true)
+	if err := wav.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != wavHeaderSize+2*120 {
+		t.Fatalf("Expected %d bytes, Actual %d", wavHeaderSize+2*120, len(data))
+	}
+	if riff := binary.LittleEndian.Uint32(data[4:]); riff != 36+240 {
+		t.Errorf("RIFF size: Expected %d, Actual %d", 36+240, riff)
+	}
+	if dataSize := binary.LittleEndian.Uint32(data[40:]); dataSize != 240 {
+		t.Errorf("data size: Expected 240, Actual %d", dataSize)
+	}
+	sample := func(i int) int16 {
+		return int16(binary.LittleEndian.Uint16(data[wavHeaderSize+2*i:]))
+	}
+	for _, testCase := range []struct {
+		i        int
+		expected int16
+	}{
+		{0, toneAmplitude},
+		{49, toneAmplitude},
+		{50, -toneAmplitude},
+		{70, 0},
+		// the tone keeps its phase through the silence
+		{80, -toneAmplitude},
+		{100, toneAmplitude},
+	} {
+		if actual := sample(testCase.i); actual != testCase.expected {
+			t.Errorf("Sample %d: Expected %d, Actual %d", testCase.i, testCase.expected, actual)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	rom := []uint8{
+		0x60, 0x05, // LD V0, 5
+		0xF0, 0x18, // LD ST, V0
+		0xD1, 0x15, // DRW V1, V1, 5
+		0xF2, 0x0A, // LD V2, K
+		0x00, 0xE0, // CLS
+		0x12, 0x0A, // JP 0x20A
+	}
+	script, err := ParseScript(strings.NewReader("10 5\n11 -"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Frames:         20,
+		CPUFrequencyHz: 600,
+		Width:          64,
+		Height:         32,
+		Filter:         raster.FilterNearest,
+		PixelFadeTime:  time.Millisecond,
+		Seed:           1,
+	}
+	dir := t.TempDir()
+	record := func() (video, audio []byte) {
+		audioFile, err := os.Create(filepath.Join(dir, "audio.wav"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer audioFile.Close()
+		var videoBuffer bytes.Buffer
+		vm, err := Record(rom, script, &videoBuffer, audioFile, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vm.V[2] != 5 {
+			t.Errorf("Expected key 5 pressed, Actual V2 = %d", vm.V[2])
+		}
+		audio, err = ioutil.ReadFile(audioFile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return videoBuffer.Bytes(), audio
+	}
+	video, audio := record()
+
+	header := "YUV4MPEG2 W64 H32 F60:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n"
+	frameSize := len("FRAME\n") + 64*32*3/2
+	if len(video) != len(header)+opts.Frames*frameSize {
+		t.Fatalf("Expected %d frames, Actual %d bytes", opts.Frames, len(video))
+	}
+	// the luma of the top left pixel, lit (black) until CLS after the key press
+	// in frame 10, and faded out in the next frame
+	luma := func(frame int) uint8 {
+		return video[len(header)+frame*frameSize+len("FRAME\n")]
+	}
+	if luma(10) != 0 || luma(11) != 255 {
+		t.Errorf("Expected the top left pixel lit until frame 11, Actual %d, %d", luma(10), luma(11))
+	}
+
+	samplesPerFrame := SampleRateHz / FrameRateHz
+	if len(audio) != wavHeaderSize+2*opts.Frames*samplesPerFrame {
+		t.Fatalf("Expected %d frames of audio, Actual %d bytes", opts.Frames, len(audio))
+	}
+	// ST is set to 5 in the first frame, and ticked down at the start of the
+	// following ones
+	isToneOn := func(frame int) bool {
+		return binary.LittleEndian.Uint16(audio[wavHeaderSize+2*frame*samplesPerFrame:]) != 0
+	}
+	if !isToneOn(0) || !isToneOn(4) || isToneOn(5) {
+		t.Errorf("Expected a tone in frames 0 - 4, Actual %v, %v, %v", isToneOn(0), isToneOn(4), isToneOn(5))
+	}
+
+	if video2, audio2 := record(); !bytes.Equal(video, video2) || !bytes.Equal(audio, audio2) {
+		t.Error("Expected a deterministic recording")
+	}
+}
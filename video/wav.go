@@ -0,0 +1,105 @@
+package video
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// SampleRateHz is the sample rate of the sound, a whole number of samples
+	// per 60 Hz frame
+	SampleRateHz = 44100
+	// ToneHz is the frequency of the square wave played while ST > 0, about
+	// an A4 with a whole number of samples per period
+	ToneHz = 441
+	// toneAmplitude is a quarter of full scale
+	toneAmplitude = 0x2000
+	// wavHeaderSize is the size of the RIFF header, format and data chunk
+	// headers
+	wavHeaderSize = 44
+)
+
+// WAVWriter writes the sound as 16-bit mono PCM, updating the sizes in the
+// header on Close
+type WAVWriter struct {
+	ws      io.WriteSeeker
+	w       *bufio.Writer
+	samples int
+	// phase is the sample within the period of the tone
+	phase int
+}
+
+func NewWAVWriter(ws io.WriteSeeker) (*WAVWriter, error) {
+	wav := &WAVWriter{ws: ws, w: bufio.NewWriter(ws)}
+	if err := wav.writeHeader(); err != nil {
+		return nil, err
+	}
+	return wav, nil
+}
+
+func (wav *WAVWriter) writeHeader() error {
+	dataSize := uint32(2 * wav.samples)
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(wavHeaderSize - 8 + dataSize),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),               // format chunk size
+		uint16(1),                // PCM
+		uint16(1),                // mono
+		uint32(SampleRateHz),     // sample rate
+		uint32(2 * SampleRateHz), // byte rate
+		uint16(2),                // block align
+		uint16(16),               // bits per sample
+		[4]byte{'d', 'a', 't', 'a'},
+		dataSize,
+	}
+	for _, field := range header {
+		if err := binary.Write(wav.w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTone writes samples of the tone if on, or silence if not, keeping the
+// phase of the tone between calls
+func (wav *WAVWriter) WriteTone(samples int, on bool) error {
+	period := SampleRateHz / ToneHz
+	var sample [2]byte
+	for i := 0; i < samples; i++ {
+		value := int16(0)
+		if on {
+			value = toneAmplitude
+			if wav.phase >= period/2 {
+				value = -toneAmplitude
+			}
+		}
+		wav.phase = (wav.phase + 1) % period
+		binary.LittleEndian.PutUint16(sample[:], uint16(value))
+		if _, err := wav.w.Write(sample[:]); err != nil {
+			return err
+		}
+	}
+	wav.samples += samples
+	return nil
+}
+
+// Close rewrites the header with the number of samples written
+func (wav *WAVWriter) Close() error {
+	if err := wav.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := wav.ws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := wav.writeHeader(); err != nil {
+		return err
+	}
+	if err := wav.w.Flush(); err != nil {
+		return err
+	}
+	_, err := wav.ws.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,71 @@
+package video
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Y4MWriter writes frames as a YUV4MPEG2 stream of full range 4:2:0 YCbCr,
+// which ffmpeg, mpv and other standard tools play and encode
+type Y4MWriter struct {
+	w             *bufio.Writer
+	width, height int
+	// y, cb and cr are the planes of a frame
+	y, cb, cr []uint8
+}
+
+// NewY4MWriter writes the stream header for frames of an even width and
+// height at a frame rate
+func NewY4MWriter(w io.Writer, width, height, frameRateHz int) (*Y4MWriter, error) {
+	if width <= 0 || height <= 0 || width%2 != 0 || height%2 != 0 {
+		return nil, fmt.Errorf("Unsupported frame size: %dx%d, must be even", width, height)
+	}
+	y4m := &Y4MWriter{
+		w:      bufio.NewWriter(w),
+		width:  width,
+		height: height,
+		y:      make([]uint8, width*height),
+		cb:     make([]uint8, width*height/4),
+		cr:     make([]uint8, width*height/4),
+	}
+	fmt.Fprintf(y4m.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n", width, height, frameRateHz)
+	return y4m, nil
+}
+
+// WriteFrame writes an image of the stream's size as a frame, the chroma
+// being the average of each 2x2 block of pixels
+func (y4m *Y4MWriter) WriteFrame(img *image.RGBA) error {
+	if img.Rect.Dx() != y4m.width || img.Rect.Dy() != y4m.height {
+		return fmt.Errorf("Unexpected frame size: %dx%d", img.Rect.Dx(), img.Rect.Dy())
+	}
+	for by := 0; by < y4m.height/2; by++ {
+		for bx := 0; bx < y4m.width/2; bx++ {
+			var cbSum, crSum int
+			for dy := 0; dy < 2; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					x, y := 2*bx+dx, 2*by+dy
+					c := img.RGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+					yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+					y4m.y[y*y4m.width+x] = yy
+					cbSum += int(cb)
+					crSum += int(cr)
+				}
+			}
+			y4m.cb[by*y4m.width/2+bx] = uint8((cbSum + 2) / 4)
+			y4m.cr[by*y4m.width/2+bx] = uint8((crSum + 2) / 4)
+		}
+	}
+	io.WriteString(y4m.w, "FRAME\n")
+	y4m.w.Write(y4m.y)
+	y4m.w.Write(y4m.cb)
+	_, err := y4m.w.Write(y4m.cr)
+	return err
+}
+
+// Flush writes any buffered frames
+func (y4m *Y4MWriter) Flush() error {
+	return y4m.w.Flush()
+}